@@ -9,6 +9,10 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/kinesis"
@@ -19,12 +23,18 @@ import (
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	ar "github.com/coinbase/step/aws"
 )
 
@@ -117,9 +127,24 @@ type SQSAPI = sqsiface.SQSAPI
 // KMSAPI KMS api
 type KMSAPI = kmsiface.KMSAPI
 
+// SSMAPI SSM api
+type SSMAPI = ssmiface.SSMAPI
+
+// SMAPI SecretsManager api
+type SMAPI = secretsmanageriface.SecretsManagerAPI
+
 // LambdaAPI aws api
 type LambdaAPI = lambdaiface.LambdaAPI
 
+// EBAPI EventBridge api
+type EBAPI = eventbridgeiface.EventBridgeAPI
+
+// ECRAPI container registry api
+type ECRAPI = ecriface.ECRAPI
+
+// STSAPI caller identity api
+type STSAPI = stsiface.STSAPI
+
 // DynamoDBAPI aws API
 type DynamoDBAPI = dynamodbiface.DynamoDBAPI
 
@@ -135,8 +160,13 @@ type Clients interface {
 	DDB(region *string, accountID *string, role *string) DDBAPI
 	SQS(region *string, accountID *string, role *string) SQSAPI
 	KMS(region *string, accountID *string, role *string) KMSAPI
+	SSM(region *string, accountID *string, role *string) SSMAPI
+	SM(region *string, accountID *string, role *string) SMAPI
 	Lambda(region *string, accountID *string, role *string) LambdaAPI
 	CWL(region *string, accountID *string, role *string) CWLAPI
+	EB(region *string, accountID *string, role *string) EBAPI
+	ECR(region *string, accountID *string, role *string) ECRAPI
+	STS(region *string, accountID *string, role *string) STSAPI
 	DynamoDBClient(region *string, accountID *string, role *string) DynamoDBAPI
 }
 
@@ -200,11 +230,36 @@ func (awsc *ClientsStr) KMS(region *string, accountID *string, role *string) KMS
 	return kms.New(awsc.Session(), awsc.Config(region, accountID, role))
 }
 
+// SSM returns client
+func (awsc *ClientsStr) SSM(region *string, accountID *string, role *string) SSMAPI {
+	return ssm.New(awsc.Session(), awsc.Config(region, accountID, role))
+}
+
+// SM returns SecretsManager client
+func (awsc *ClientsStr) SM(region *string, accountID *string, role *string) SMAPI {
+	return secretsmanager.New(awsc.Session(), awsc.Config(region, accountID, role))
+}
+
 // LAMBDA returns client
 func (awsc *ClientsStr) Lambda(region *string, accountID *string, role *string) LambdaAPI {
 	return lambda.New(awsc.Session(), awsc.Config(region, accountID, role))
 }
 
+// EB returns client
+func (awsc *ClientsStr) EB(region *string, accountID *string, role *string) EBAPI {
+	return eventbridge.New(awsc.Session(), awsc.Config(region, accountID, role))
+}
+
+// ECR returns client
+func (awsc *ClientsStr) ECR(region *string, accountID *string, role *string) ECRAPI {
+	return ecr.New(awsc.Session(), awsc.Config(region, accountID, role))
+}
+
+// STS returns client
+func (awsc *ClientsStr) STS(region *string, accountID *string, role *string) STSAPI {
+	return sts.New(awsc.Session(), awsc.Config(region, accountID, role))
+}
+
 // DynamoDBClient returns client for region account and role
 func (awsc *ClientsStr) DynamoDBClient(region, account_id, role *string) DynamoDBAPI {
 	return dynamodb.New(awsc.Session(), awsc.Config(region, account_id, role))
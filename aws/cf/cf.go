@@ -99,3 +99,25 @@ func CreateChangeSet(cfc aws.CFAPI, input *cloudformation.CreateChangeSetInput)
 	_, err := cfc.CreateChangeSet(input)
 	return err
 }
+
+// StackResources lists the resources in the stack, a stack that does not exist has none
+func StackResources(cfc aws.CFAPI, name *string) ([]*cloudformation.StackResourceSummary, error) {
+	resources := []*cloudformation.StackResourceSummary{}
+
+	err := cfc.ListStackResourcesPages(&cloudformation.ListStackResourcesInput{
+		StackName: name,
+	}, func(page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+		resources = append(resources, page.StackResourceSummaries...)
+		return true
+	})
+
+	if err != nil {
+		// Same hack as HasStack
+		if strings.Contains(err.Error(), fmt.Sprintf("Stack with id %s does not exist", *name)) {
+			return resources, nil
+		}
+		return nil, err
+	}
+
+	return resources, nil
+}
@@ -25,3 +25,23 @@ func ListLogGroupTags(cwlc aws.CWLAPI, name *string) (map[string]string, error)
 
 	return tags, nil
 }
+
+// LogGroupExists returns true if a log group named name exists
+func LogGroupExists(cwlc aws.CWLAPI, name *string) (bool, error) {
+	out, err := cwlc.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: name,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	// Groups are sorted by name so an exact match is first
+	for _, group := range out.LogGroups {
+		if group.LogGroupName != nil && *group.LogGroupName == *name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,49 @@
+package eb
+
+import (
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/coinbase/fenrir/aws"
+)
+
+// EventBus struct
+type EventBus struct {
+	Name string
+	Arn  string
+	Tags map[string]string
+}
+
+// FindEventBus describes the bus by name or ARN and fetches its tags
+func FindEventBus(ebc aws.EBAPI, name string) (*EventBus, error) {
+	desc, err := ebc.DescribeEventBus(&eventbridge.DescribeEventBusInput{
+		Name: &name,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	tagsout, err := ebc.ListTagsForResource(&eventbridge.ListTagsForResourceInput{
+		ResourceARN: desc.Arn,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	bus := EventBus{Tags: map[string]string{}}
+	if desc.Name != nil {
+		bus.Name = *desc.Name
+	}
+
+	if desc.Arn != nil {
+		bus.Arn = *desc.Arn
+	}
+
+	for _, tag := range tagsout.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			bus.Tags[*tag.Key] = *tag.Value
+		}
+	}
+
+	return &bus, nil
+}
@@ -0,0 +1,159 @@
+package ecr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// imageURIRegexp matches <account>.dkr.ecr.<region>.amazonaws.com/<repository>[:<tag>|@<digest>]
+var imageURIRegexp = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com/([a-z0-9][a-z0-9._/-]*)(:[A-Za-z0-9_][A-Za-z0-9_.-]*|@sha256:[0-9a-f]{64})?$`)
+
+// ImageURI is a parsed ECR image URI, the image is either a Tag or a Digest
+type ImageURI struct {
+	RegistryID string
+	Region     string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageURI parses <account>.dkr.ecr.<region>.amazonaws.com/<repository>[:<tag>|@sha256:<hex>]
+func ParseImageURI(uri string) (*ImageURI, error) {
+	match := imageURIRegexp.FindStringSubmatch(uri)
+	if match == nil {
+		return nil, fmt.Errorf("%q is not an ECR image URI", uri)
+	}
+
+	image := &ImageURI{RegistryID: match[1], Region: match[2], Repository: match[3]}
+	if strings.HasPrefix(match[4], "@") {
+		image.Digest = match[4][1:]
+	} else if match[4] != "" {
+		image.Tag = match[4][1:]
+	}
+
+	return image, nil
+}
+
+// RepositoryURI is the URI of the images repository
+func (i *ImageURI) RepositoryURI() string {
+	return fmt.Sprintf("%v.dkr.ecr.%v.amazonaws.com/%v", i.RegistryID, i.Region, i.Repository)
+}
+
+// String is the URI pinned by digest, or tag if it has no digest
+func (i *ImageURI) String() string {
+	if i.Digest != "" {
+		return fmt.Sprintf("%v@%v", i.RepositoryURI(), i.Digest)
+	}
+
+	if i.Tag != "" {
+		return fmt.Sprintf("%v:%v", i.RepositoryURI(), i.Tag)
+	}
+
+	return i.RepositoryURI()
+}
+
+// Image struct
+type Image struct {
+	URI  *ImageURI
+	Tags map[string]string // Tags of the repository, images do not have tags
+}
+
+// FindImage describes the image by tag or digest, returning it pinned by digest with its repository tags
+func FindImage(ecrc aws.ECRAPI, uri *ImageURI) (*Image, error) {
+	repos, err := ecrc.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RegistryId:      to.Strp(uri.RegistryID),
+		RepositoryNames: []*string{to.Strp(uri.Repository)},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(repos.Repositories) != 1 || repos.Repositories[0].RepositoryArn == nil {
+		return nil, fmt.Errorf("Cannot find repository %q", uri.Repository)
+	}
+
+	imageID := &ecr.ImageIdentifier{}
+	if uri.Digest != "" {
+		imageID.ImageDigest = to.Strp(uri.Digest)
+	} else if uri.Tag != "" {
+		imageID.ImageTag = to.Strp(uri.Tag)
+	} else {
+		return nil, fmt.Errorf("Image %v has no tag or digest", uri)
+	}
+
+	images, err := ecrc.DescribeImages(&ecr.DescribeImagesInput{
+		RegistryId:     to.Strp(uri.RegistryID),
+		RepositoryName: to.Strp(uri.Repository),
+		ImageIds:       []*ecr.ImageIdentifier{imageID},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(images.ImageDetails) != 1 || images.ImageDetails[0].ImageDigest == nil {
+		return nil, fmt.Errorf("Cannot find image %v", uri)
+	}
+
+	tagsout, err := ecrc.ListTagsForResource(&ecr.ListTagsForResourceInput{
+		ResourceArn: repos.Repositories[0].RepositoryArn,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	image := &Image{
+		URI: &ImageURI{
+			RegistryID: uri.RegistryID,
+			Region:     uri.Region,
+			Repository: uri.Repository,
+			Digest:     *images.ImageDetails[0].ImageDigest,
+		},
+		Tags: map[string]string{},
+	}
+
+	for _, tag := range tagsout.Tags {
+		if tag.Key == nil || tag.Value == nil {
+			continue
+		}
+		image.Tags[*tag.Key] = *tag.Value
+	}
+
+	return image, nil
+}
+
+// Login returns the docker username, password and registry endpoint for the registry
+func Login(ecrc aws.ECRAPI, registryID string) (string, string, string, error) {
+	out, err := ecrc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{
+		RegistryIds: []*string{to.Strp(registryID)},
+	})
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if len(out.AuthorizationData) != 1 || out.AuthorizationData[0].AuthorizationToken == nil {
+		return "", "", "", fmt.Errorf("Cannot get authorization token for %v", registryID)
+	}
+
+	// the token is base64 encoded user:password
+	token, err := base64.StdEncoding.DecodeString(*out.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	userPassword := strings.SplitN(string(token), ":", 2)
+	if len(userPassword) != 2 {
+		return "", "", "", fmt.Errorf("Authorization token incorrect")
+	}
+
+	return userPassword[0], userPassword[1], to.Strs(out.AuthorizationData[0].ProxyEndpoint), nil
+}
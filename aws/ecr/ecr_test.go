@@ -0,0 +1,48 @@
+package ecr
+
+import (
+	"testing"
+
+	"github.com/coinbase/fenrir/aws/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+var digest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func Test_ParseImageURI(t *testing.T) {
+	uri, err := ParseImageURI("000000000000.dkr.ecr.us-east-1.amazonaws.com/team/hello@" + digest)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImageURI{RegistryID: "000000000000", Region: "us-east-1", Repository: "team/hello", Digest: digest}, uri)
+	assert.Equal(t, "000000000000.dkr.ecr.us-east-1.amazonaws.com/team/hello@"+digest, uri.String())
+
+	uri, err = ParseImageURI("000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:v1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2", uri.Tag)
+	assert.Equal(t, "", uri.Digest)
+
+	for _, bad := range []string{
+		"hello",
+		"docker.io/hello:latest",
+		"000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:abc",
+		"000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:v1@" + digest,
+	} {
+		_, err := ParseImageURI(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func Test_FindImage(t *testing.T) {
+	ecrc := &mocks.ECRClient{}
+	uri, _ := ParseImageURI("000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:development")
+
+	_, err := FindImage(ecrc, uri)
+	assert.Error(t, err)
+
+	ecrc.AddRepository("hello", map[string]string{"ProjectName": "project"})
+	ecrc.AddImage("hello", "development", digest)
+
+	image, err := FindImage(ecrc, uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@"+digest, image.URI.String())
+	assert.Equal(t, map[string]string{"ProjectName": "project"}, image.Tags)
+}
@@ -0,0 +1,118 @@
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// deniedActions can never be granted by an inline policy
+var deniedActions = []string{
+	"iam:*",
+	"sts:AssumeRole",
+}
+
+// ParseStatements reads the Statement of a policy document which can be a
+// single statement or a list of statements
+func ParseStatements(statement interface{}) ([]StatementEntry, error) {
+	raw, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []StatementEntry
+	if err := json.Unmarshal(raw, &statements); err == nil {
+		return statements, nil
+	}
+
+	var single StatementEntry
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, fmt.Errorf("Statement invalid %v", err.Error())
+	}
+
+	return []StatementEntry{single}, nil
+}
+
+// Analyze returns why a statement is not least privilege. Resources are only
+// checked for "*", the caller must check that each is allowed
+func (s StatementEntry) Analyze() []error {
+	errs := []error{}
+
+	if s.NotAction != nil {
+		errs = append(errs, fmt.Errorf("NotAction not supported"))
+	}
+
+	if s.NotResource != nil {
+		errs = append(errs, fmt.Errorf("NotResource not supported"))
+	}
+
+	if s.Principal != nil {
+		errs = append(errs, fmt.Errorf("Principal not supported"))
+	}
+
+	switch s.Effect {
+	case "Deny":
+		// Deny statements only reduce privileges
+		return errs
+	case "Allow":
+	default:
+		return append(errs, fmt.Errorf("Effect must be Allow or Deny not %q", s.Effect))
+	}
+
+	actions := s.NormalizedAction()
+	if len(actions) == 0 {
+		errs = append(errs, fmt.Errorf("Action required"))
+	}
+
+	for _, action := range actions {
+		if err := analyzeAction(action); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	resources := s.NormalizedResource()
+	if len(resources) == 0 {
+		errs = append(errs, fmt.Errorf("Resource required"))
+	}
+
+	for _, resource := range resources {
+		if resource == "*" {
+			errs = append(errs, fmt.Errorf("Resource \"*\" not allowed"))
+		}
+	}
+
+	return errs
+}
+
+func analyzeAction(action string) error {
+	split := strings.SplitN(action, ":", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return fmt.Errorf("Action %q not allowed", action)
+	}
+
+	if strings.Contains(split[0], "*") || split[1] == "*" {
+		return fmt.Errorf("Action %q not allowed, wildcard service or action", action)
+	}
+
+	for _, denied := range deniedActions {
+		if actionsOverlap(action, denied) {
+			return fmt.Errorf("Action %q not allowed, matches %q", action, denied)
+		}
+	}
+
+	return nil
+}
+
+// actionsOverlap returns true if an action could match the same action as another.
+// Actions are case insensitive and * or ? match any characters in the action name
+func actionsOverlap(a, b string) bool {
+	return actionPattern(a).MatchString(b) || actionPattern(b).MatchString(a)
+}
+
+func actionPattern(action string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(strings.ToLower(action))
+	pattern = strings.Replace(pattern, `\*`, ".*", -1)
+	pattern = strings.Replace(pattern, `\?`, ".", -1)
+	return regexp.MustCompile("(?i)^" + pattern + "$")
+}
@@ -0,0 +1,52 @@
+package iam
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatements(t *testing.T) {
+	statements, err := ParseStatements(map[string]interface{}{
+		"Effect":   "Allow",
+		"Action":   "s3:GetObject",
+		"Resource": []interface{}{"arn:aws:s3:::bucket/*"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(statements))
+	assert.Equal(t, []string{"s3:GetObject"}, statements[0].NormalizedAction())
+	assert.Equal(t, []string{"arn:aws:s3:::bucket/*"}, statements[0].NormalizedResource())
+
+	statements, err = ParseStatements([]interface{}{
+		map[string]interface{}{"Effect": "Allow"},
+		map[string]interface{}{"Effect": "Deny"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(statements))
+
+	_, err = ParseStatements("Allow")
+	assert.Error(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	good := StatementEntry{Effect: "Allow", Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket/*"}
+	assert.Equal(t, 0, len(good.Analyze()))
+
+	deny := StatementEntry{Effect: "Deny", Action: "*", Resource: "*"}
+	assert.Equal(t, 0, len(deny.Analyze()))
+
+	for _, action := range []string{"*", "s3:*", "*:GetObject", "iam:PassRole", "IAM:passrole", "iam:*", "sts:AssumeRole", "sts:Assume*", "sts:*Role", "GetObject"} {
+		s := StatementEntry{Effect: "Allow", Action: action, Resource: "arn:aws:s3:::bucket"}
+		assert.Equal(t, 1, len(s.Analyze()), action)
+	}
+
+	for _, s := range []StatementEntry{
+		{Effect: "Allow", Action: "s3:GetObject", Resource: "*"},
+		{Effect: "Allow", Action: "s3:GetObject"},
+		{Effect: "Allow", Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket", Principal: &PrincipalEntry{AWS: "*"}},
+		{Effect: "Allow", Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket", NotResource: "arn:aws:s3:::other"},
+		{Effect: "Maybe", Action: "s3:GetObject", Resource: "arn:aws:s3:::bucket"},
+	} {
+		assert.Equal(t, 1, len(s.Analyze()))
+	}
+}
@@ -27,16 +27,25 @@ type PolicyDocument struct {
 }
 
 type StatementEntry struct {
-	Effect    string
-	Action    interface{}
-	Resource  string
-	Principal PrincipalEntry
+	Sid         string          `json:",omitempty"`
+	Effect      string          `json:",omitempty"`
+	Action      interface{}     `json:",omitempty"`
+	NotAction   interface{}     `json:",omitempty"`
+	Resource    interface{}     `json:",omitempty"`
+	NotResource interface{}     `json:",omitempty"`
+	Principal   *PrincipalEntry `json:",omitempty"`
+	Condition   interface{}     `json:",omitempty"`
 }
 
 func (s StatementEntry) NormalizedAction() []string {
 	return normalizeInterfaceToStringArr(s.Action)
 }
 
+// NormalizedResource normalizes the string or []string Resource
+func (s StatementEntry) NormalizedResource() []string {
+	return normalizeInterfaceToStringArr(s.Resource)
+}
+
 type PrincipalEntry struct {
 	AWS interface{}
 }
@@ -12,13 +12,18 @@ type MockClients struct {
 	CWLClient    *CWLClient
 	EC2Client    *EC2Client
 	IAMClient    *IAMClient
-	SFNClient    *mocks.MockSFNClient
+	SFNClient    *SFNClient
 	SNSClient    *SNSClient
 	KINClient    *KINClient
 	DDBClient    *DDBClient
 	SQSClient    *SQSClient
 	KMSClient    *KMSClient
+	SSMClient    *SSMClient
+	SMClient     *SMClient
 	LambdaClient *LambdaClient
+	EBClient     *EBClient
+	ECRClient    *ECRClient
+	STSClient    *STSClient
 	DynamoDB     *mocks.MockDynamoDBClient
 }
 
@@ -30,13 +35,18 @@ func MockAWS() *MockClients {
 		CWLClient:    &CWLClient{},
 		EC2Client:    &EC2Client{},
 		IAMClient:    &IAMClient{},
-		SFNClient:    &mocks.MockSFNClient{},
+		SFNClient:    &SFNClient{MockSFNClient: &mocks.MockSFNClient{}},
 		SNSClient:    &SNSClient{},
 		KINClient:    &KINClient{},
 		DDBClient:    &DDBClient{},
 		SQSClient:    &SQSClient{},
 		KMSClient:    &KMSClient{},
+		SSMClient:    &SSMClient{},
+		SMClient:     &SMClient{},
 		LambdaClient: &LambdaClient{},
+		EBClient:     &EBClient{},
+		ECRClient:    &ECRClient{},
+		STSClient:    &STSClient{Arn: "arn:aws:sts::000000000000:assumed-role/role/releaser"},
 		DynamoDB:     &mocks.MockDynamoDBClient{},
 	}
 }
@@ -90,10 +100,30 @@ func (a *MockClients) KMS(*string, *string, *string) aws.KMSAPI {
 	return a.KMSClient
 }
 
+func (a *MockClients) SSM(*string, *string, *string) aws.SSMAPI {
+	return a.SSMClient
+}
+
+func (a *MockClients) SM(*string, *string, *string) aws.SMAPI {
+	return a.SMClient
+}
+
 func (a *MockClients) Lambda(*string, *string, *string) aws.LambdaAPI {
 	return a.LambdaClient
 }
 
+func (a *MockClients) EB(*string, *string, *string) aws.EBAPI {
+	return a.EBClient
+}
+
+func (a *MockClients) ECR(*string, *string, *string) aws.ECRAPI {
+	return a.ECRClient
+}
+
+func (a *MockClients) STS(*string, *string, *string) aws.STSAPI {
+	return a.STSClient
+}
+
 func (a *MockClients) DynamoDBClient(*string, *string, *string) aws.DynamoDBAPI {
 	return a.DynamoDB
 }
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudformation"
@@ -14,6 +15,17 @@ type CFClient struct {
 	StackResp         *cloudformation.DescribeStacksOutput
 	ChangeSet         *cloudformation.DescribeChangeSetOutput
 	DeleteStackCalled bool
+
+	DeleteChangeSetCalled bool
+
+	// StackEvents overrides the default DescribeStackEvents
+	StackEvents []*cloudformation.StackEvent
+
+	// StackEventsPageSize pages StackEvents if set
+	StackEventsPageSize int
+
+	// StackResources are returned by ListStackResources
+	StackResources []*cloudformation.StackResourceSummary
 }
 
 func (m *CFClient) init() {
@@ -58,6 +70,12 @@ func (m *CFClient) DeleteStack(in *cloudformation.DeleteStackInput) (*cloudforma
 	return nil, nil
 }
 
+// DeleteChangeSet returns
+func (m *CFClient) DeleteChangeSet(in *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	m.DeleteChangeSetCalled = true
+	return nil, nil
+}
+
 // DescribeChangeSet returns
 func (m *CFClient) DescribeChangeSet(in *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
 	m.init()
@@ -65,6 +83,27 @@ func (m *CFClient) DescribeChangeSet(in *cloudformation.DescribeChangeSetInput)
 }
 
 func (m *CFClient) DescribeStackEvents(in *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+	if m.StackEvents != nil && m.StackEventsPageSize > 0 {
+		start := 0
+		if in.NextToken != nil {
+			start, _ = strconv.Atoi(*in.NextToken)
+		}
+
+		end := start + m.StackEventsPageSize
+		if end >= len(m.StackEvents) {
+			return &cloudformation.DescribeStackEventsOutput{StackEvents: m.StackEvents[start:]}, nil
+		}
+
+		return &cloudformation.DescribeStackEventsOutput{
+			StackEvents: m.StackEvents[start:end],
+			NextToken:   to.Strp(strconv.Itoa(end)),
+		}, nil
+	}
+
+	if m.StackEvents != nil {
+		return &cloudformation.DescribeStackEventsOutput{StackEvents: m.StackEvents}, nil
+	}
+
 	return &cloudformation.DescribeStackEventsOutput{
 		StackEvents: []*cloudformation.StackEvent{
 			&cloudformation.StackEvent{
@@ -77,3 +116,9 @@ func (m *CFClient) DescribeStackEvents(in *cloudformation.DescribeStackEventsInp
 		},
 	}, nil
 }
+
+// ListStackResourcesPages returns StackResources in a single page
+func (m *CFClient) ListStackResourcesPages(in *cloudformation.ListStackResourcesInput, fn func(*cloudformation.ListStackResourcesOutput, bool) bool) error {
+	fn(&cloudformation.ListStackResourcesOutput{StackResourceSummaries: m.StackResources}, true)
+	return nil
+}
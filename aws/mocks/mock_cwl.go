@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"strings"
+
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/coinbase/fenrir/aws"
 	"github.com/coinbase/step/utils/to"
@@ -8,6 +10,28 @@ import (
 
 type CWLClient struct {
 	aws.CWLAPI
+
+	// LogGroups are the names of log groups that exist
+	LogGroups map[string]bool
+}
+
+// AddLogGroup makes a log group exist
+func (m *CWLClient) AddLogGroup(name string) {
+	if m.LogGroups == nil {
+		m.LogGroups = map[string]bool{}
+	}
+	m.LogGroups[name] = true
+}
+
+// DescribeLogGroups returns the added log groups with the prefix
+func (m *CWLClient) DescribeLogGroups(in *cloudwatchlogs.DescribeLogGroupsInput) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: []*cloudwatchlogs.LogGroup{}}
+	for name := range m.LogGroups {
+		if strings.HasPrefix(name, to.Strs(in.LogGroupNamePrefix)) {
+			out.LogGroups = append(out.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: to.Strp(name)})
+		}
+	}
+	return out, nil
 }
 
 // ListTagsLogGroup returns
@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+type EBClient struct {
+	aws.EBAPI
+	EventBuses map[string]*eventbridge.DescribeEventBusOutput
+	Tags       map[string]map[string]string
+}
+
+func (m *EBClient) init() {
+	if m.EventBuses == nil {
+		m.EventBuses = map[string]*eventbridge.DescribeEventBusOutput{}
+	}
+
+	if m.Tags == nil {
+		m.Tags = map[string]map[string]string{}
+	}
+}
+
+// AddEventBus can be described by name or ARN
+func (m *EBClient) AddEventBus(name, arn string, tags map[string]string) {
+	m.init()
+	out := &eventbridge.DescribeEventBusOutput{Name: to.Strp(name), Arn: to.Strp(arn)}
+	m.EventBuses[name] = out
+	m.EventBuses[arn] = out
+	m.Tags[arn] = tags
+}
+
+func (m *EBClient) DescribeEventBus(in *eventbridge.DescribeEventBusInput) (*eventbridge.DescribeEventBusOutput, error) {
+	m.init()
+	out, ok := m.EventBuses[to.Strs(in.Name)]
+	if !ok {
+		return nil, fmt.Errorf("ResourceNotFoundException: Event bus %v does not exist", to.Strs(in.Name))
+	}
+	return out, nil
+}
+
+func (m *EBClient) ListTagsForResource(in *eventbridge.ListTagsForResourceInput) (*eventbridge.ListTagsForResourceOutput, error) {
+	m.init()
+	tags := []*eventbridge.Tag{}
+	for key, value := range m.Tags[to.Strs(in.ResourceARN)] {
+		tags = append(tags, &eventbridge.Tag{Key: to.Strp(key), Value: to.Strp(value)})
+	}
+	return &eventbridge.ListTagsForResourceOutput{Tags: tags}, nil
+}
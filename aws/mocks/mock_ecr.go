@@ -0,0 +1,95 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+type ECRClient struct {
+	aws.ECRAPI
+	Repositories map[string]*ecr.Repository
+	Images       map[string][]*ecr.ImageDetail
+	Tags         map[string]map[string]string
+}
+
+func (m *ECRClient) init() {
+	if m.Repositories == nil {
+		m.Repositories = map[string]*ecr.Repository{}
+	}
+
+	if m.Images == nil {
+		m.Images = map[string][]*ecr.ImageDetail{}
+	}
+
+	if m.Tags == nil {
+		m.Tags = map[string]map[string]string{}
+	}
+}
+
+// AddRepository adds the repository in the 000000000000 us-east-1 registry with tags
+func (m *ECRClient) AddRepository(name string, tags map[string]string) {
+	m.init()
+	arn := fmt.Sprintf("arn:aws:ecr:us-east-1:000000000000:repository/%v", name)
+	m.Repositories[name] = &ecr.Repository{
+		RegistryId:     to.Strp("000000000000"),
+		RepositoryArn:  to.Strp(arn),
+		RepositoryName: to.Strp(name),
+		RepositoryUri:  to.Strp(fmt.Sprintf("000000000000.dkr.ecr.us-east-1.amazonaws.com/%v", name)),
+	}
+	m.Tags[arn] = tags
+}
+
+// AddImage adds an image with digest and tag to the repository
+func (m *ECRClient) AddImage(repository, tag, digest string) {
+	m.init()
+	m.Images[repository] = append(m.Images[repository], &ecr.ImageDetail{
+		RepositoryName: to.Strp(repository),
+		ImageDigest:    to.Strp(digest),
+		ImageTags:      []*string{to.Strp(tag)},
+	})
+}
+
+func (m *ECRClient) DescribeRepositories(in *ecr.DescribeRepositoriesInput) (*ecr.DescribeRepositoriesOutput, error) {
+	m.init()
+	repos := []*ecr.Repository{}
+	for _, name := range in.RepositoryNames {
+		repo, ok := m.Repositories[to.Strs(name)]
+		if !ok {
+			return nil, fmt.Errorf("RepositoryNotFoundException: The repository with name '%v' does not exist", to.Strs(name))
+		}
+		repos = append(repos, repo)
+	}
+	return &ecr.DescribeRepositoriesOutput{Repositories: repos}, nil
+}
+
+func (m *ECRClient) DescribeImages(in *ecr.DescribeImagesInput) (*ecr.DescribeImagesOutput, error) {
+	m.init()
+	details := []*ecr.ImageDetail{}
+	for _, id := range in.ImageIds {
+		found := false
+		for _, image := range m.Images[to.Strs(in.RepositoryName)] {
+			if id.ImageDigest != nil && *id.ImageDigest == to.Strs(image.ImageDigest) ||
+				id.ImageTag != nil && *id.ImageTag == to.Strs(image.ImageTags[0]) {
+				details = append(details, image)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("ImageNotFoundException: The image requested does not exist in the repository '%v'", to.Strs(in.RepositoryName))
+		}
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: details}, nil
+}
+
+func (m *ECRClient) ListTagsForResource(in *ecr.ListTagsForResourceInput) (*ecr.ListTagsForResourceOutput, error) {
+	m.init()
+	tags := []*ecr.Tag{}
+	for key, value := range m.Tags[to.Strs(in.ResourceArn)] {
+		tags = append(tags, &ecr.Tag{Key: to.Strp(key), Value: to.Strp(value)})
+	}
+	return &ecr.ListTagsForResourceOutput{Tags: tags}, nil
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/coinbase/step/aws/mocks"
+	"github.com/coinbase/step/utils/to"
+)
+
+type SFNClient struct {
+	*mocks.MockSFNClient
+	StateMachineTags map[string]map[string]string
+}
+
+// SetStateMachineTags overrides the default project tags of a state machine ARN
+func (m *SFNClient) SetStateMachineTags(arn string, tags map[string]string) {
+	if m.StateMachineTags == nil {
+		m.StateMachineTags = map[string]map[string]string{}
+	}
+	m.StateMachineTags[arn] = tags
+}
+
+func (m *SFNClient) ListTagsForResource(in *sfn.ListTagsForResourceInput) (*sfn.ListTagsForResourceOutput, error) {
+	tags, ok := m.StateMachineTags[to.Strs(in.ResourceArn)]
+	if !ok {
+		tags = map[string]string{"ProjectName": "project", "ConfigName": "development"}
+	}
+
+	out := &sfn.ListTagsForResourceOutput{Tags: []*sfn.Tag{}}
+	for key, value := range tags {
+		out.Tags = append(out.Tags, &sfn.Tag{Key: to.Strp(key), Value: to.Strp(value)})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,27 @@
+package mocks
+
+import (
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+type SMClient struct {
+	aws.SMAPI
+}
+
+func (m *SMClient) DescribeSecret(in *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	return &secretsmanager.DescribeSecretOutput{
+		ARN: in.SecretId,
+		Tags: []*secretsmanager.Tag{
+			&secretsmanager.Tag{
+				Key:   to.Strp("ProjectName"),
+				Value: to.Strp("project"),
+			},
+			&secretsmanager.Tag{
+				Key:   to.Strp("ConfigName"),
+				Value: to.Strp("development"),
+			},
+		},
+	}, nil
+}
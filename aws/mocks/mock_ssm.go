@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+type SSMClient struct {
+	aws.SSMAPI
+	ParameterTags map[string]map[string]string
+}
+
+// SetParameterTags overrides the default project tags of a parameter
+func (m *SSMClient) SetParameterTags(name string, tags map[string]string) {
+	if m.ParameterTags == nil {
+		m.ParameterTags = map[string]map[string]string{}
+	}
+	m.ParameterTags[name] = tags
+}
+
+func (m *SSMClient) ListTagsForResource(in *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	tags, ok := m.ParameterTags[to.Strs(in.ResourceId)]
+	if !ok {
+		tags = map[string]string{"ProjectName": "project", "ConfigName": "development"}
+	}
+
+	out := &ssm.ListTagsForResourceOutput{TagList: []*ssm.Tag{}}
+	for key, value := range tags {
+		out.TagList = append(out.TagList, &ssm.Tag{Key: to.Strp(key), Value: to.Strp(value)})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// STSClient returns Arn as the caller identity
+type STSClient struct {
+	aws.STSAPI
+	Arn string
+}
+
+func (m *STSClient) GetCallerIdentity(in *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Arn: to.Strp(m.Arn)}, nil
+}
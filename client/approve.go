@@ -0,0 +1,60 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Approve approves the changeset of a release waiting for approval
+func Approve(releaseID *string, releaseFile *string) error {
+	return approve(releaseID, releaseFile, true)
+}
+
+// Reject rejects the changeset of a release waiting for approval
+func Reject(releaseID *string, releaseFile *string) error {
+	return approve(releaseID, releaseFile, false)
+}
+
+func approve(releaseID *string, releaseFile *string, approved bool) error {
+	region, accountID := to.RegionAccount()
+
+	if is.EmptyStr(region) || is.EmptyStr(accountID) {
+		return fmt.Errorf("AWS_REGION and AWS_ACCOUNT_ID envars, maybe use assume-role")
+	}
+
+	if is.EmptyStr(releaseID) {
+		return fmt.Errorf("release-id required")
+	}
+
+	release, _, err := parseRelease(*releaseFile)
+	if err != nil {
+		return err
+	}
+
+	prepareRelease(release, region, accountID)
+	release.ReleaseID = releaseID
+
+	return writeApproval(&aws.ClientsStr{}, release, &deployer.Approval{Approved: approved})
+}
+
+// writeApproval records the caller identity as the approver, the deployer takes the approval time from S3
+func writeApproval(awsc aws.Clients, release *deployer.Release, approval *deployer.Approval) error {
+	user, err := callerArn(awsc)
+	if err != nil {
+		return err
+	}
+
+	approval.User = user
+
+	if err := s3.PutStruct(awsc.S3(nil, nil, nil), release.Bucket, release.ApprovalPath(), approval); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote approved=%v for %v\n", approval.Approved, *release.ReleaseID)
+	return nil
+}
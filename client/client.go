@@ -3,16 +3,26 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
-	goformation "github.com/awslabs/goformation/v4"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
 	"github.com/awslabs/goformation/v4/intrinsics"
+	"github.com/coinbase/fenrir/aws"
 
 	"github.com/coinbase/step/utils/is"
 
 	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
 	"github.com/coinbase/step/bifrost"
 	"github.com/coinbase/step/execution"
 	"github.com/coinbase/step/utils/to"
@@ -27,10 +37,26 @@ func prepareRelease(release *deployer.Release, region *string, accountID *string
 	release.SetDefaults(region, accountID)
 }
 
+// callerArn is the STS identity ARN of the caller, recorded as who made or approved a release
+func callerArn(awsc aws.Clients) (string, error) {
+	out, err := awsc.STS(nil, nil, nil).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	if out.Arn == nil {
+		return "", fmt.Errorf("Caller identity has no ARN")
+	}
+
+	return *out.Arn, nil
+}
+
 type ProjectConfig struct {
 	ProjectName  *string `json:"ProjectName"`
 	ConfigName   *string `json:"ConfigName"`
 	AwsAccountID *string `json:"AwsAccountID"`
+
+	AllowReplacements []string `json:"AllowReplacements"`
 }
 
 func parseRelease(releaseFile string) (*deployer.Release, string, error) {
@@ -48,6 +74,7 @@ func parseRelease(releaseFile string) (*deployer.Release, string, error) {
 	release.ProjectName = projectConfig.ProjectName
 	release.ConfigName = projectConfig.ConfigName
 	release.AwsAccountID = projectConfig.AwsAccountID
+	release.AllowReplacements = projectConfig.AllowReplacements
 
 	if is.EmptyStr(release.ProjectName) || is.EmptyStr(release.ConfigName) {
 		return nil, "", fmt.Errorf("ProjectName or ConfigName is nil")
@@ -56,31 +83,246 @@ func parseRelease(releaseFile string) (*deployer.Release, string, error) {
 	return &release, string(rawSAM), nil
 }
 
-func parseTemplate(rawSAM string) (*cloudformation.Template, error) {
-	// process Globals
-	// Dont process intrinsics
-	template, err := goformation.ParseYAMLWithOptions([]byte(rawSAM), &intrinsics.ProcessorOptions{
-		IntrinsicHandlerOverrides: cloudformation.EncoderIntrinsics,
-	})
+func parseTemplate(rawSAM string, values template.StaticValues) (*cloudformation.Template, error) {
+	// Resolve Mappings, Conditions and Parameters before parsing so Fn::If and !Ref can be used in any property
+	rawJSON, err := intrinsics.ProcessYAML([]byte(rawSAM), &intrinsics.ProcessorOptions{NoProcess: true})
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := template.ResolveConditions(rawJSON, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parseableResourceTypes(resolved); err != nil {
+		return nil, err
+	}
 
+	tmpl, err := template.ParseTemplate(resolved)
 	if err != nil {
 		return nil, err
 	}
 
-	y, err := template.JSON()
+	y, err := tmpl.JSON()
 	if err != nil {
 		return nil, err
 	}
 	fmt.Printf("%s\n", string(y))
 
 	// validate
-	return template, nil
+	return tmpl, nil
+}
+
+// unparseableProperties are properties goformation silently drops when parsing the resource type,
+// e.g. EphemeralStorage is not in its AWS::Serverless::Function. Architectures and container image properties are passed through
+var unparseableProperties = map[string][]string{
+	"AWS::Serverless::Function": {"EphemeralStorage", "FunctionUrlConfig", "LoggingConfig", "RuntimeManagementConfig", "SnapStart"},
+}
+
+// parseableResourceTypes errors for resource types goformation cannot parse, e.g. AWS::Serverless::Connector,
+// as it would otherwise fail with an unhelpful json.Unmarshal(nil) error. Passthrough types are parsed as custom resources
+func parseableResourceTypes(rawJSON []byte) error {
+	var raw struct {
+		Resources map[string]struct {
+			Type       string
+			Properties map[string]interface{}
+		}
+	}
+
+	if err := json.Unmarshal(rawJSON, &raw); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range raw.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	allResources := cloudformation.AllResources()
+	for _, name := range names {
+		resourceType := raw.Resources[name].Type
+		if strings.HasPrefix(resourceType, "Custom::") || template.IsPassthroughType(resourceType) {
+			continue
+		}
+
+		if _, ok := allResources[resourceType]; !ok {
+			return fmt.Errorf("Resource %v: Type %q is not supported", name, resourceType)
+		}
+
+		for _, property := range unparseableProperties[resourceType] {
+			if _, ok := raw.Resources[name].Properties[property]; ok {
+				return fmt.Errorf("Resource %v: Property %q is not supported", name, property)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parametersFilePath is parameters/<ConfigName>.yml beside the release file
+func parametersFilePath(releaseFile string, configName string) string {
+	return filepath.Join(filepath.Dir(releaseFile), "parameters", fmt.Sprintf("%v.yml", configName))
+}
+
+// parseParameters reads the parameter values for the config, if the file exists.
+// Numbers and booleans are converted to strings and lists are comma delimited
+func parseParameters(releaseFile string, configName string) (map[string]string, error) {
+	paramsFile := parametersFilePath(releaseFile, configName)
+
+	raw, err := ioutil.ReadFile(paramsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("%v: %v", paramsFile, err)
+	}
+
+	params := map[string]string{}
+	for name, value := range values {
+		if items, ok := value.([]interface{}); ok {
+			strs := []string{}
+			for _, item := range items {
+				str, err := parameterString(item)
+				if err != nil {
+					return nil, fmt.Errorf("%v: %v %v", paramsFile, name, err.Error())
+				}
+				strs = append(strs, str)
+			}
+			params[name] = strings.Join(strs, ",")
+			continue
+		}
+
+		str, err := parameterString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v %v", paramsFile, name, err.Error())
+		}
+		params[name] = str
+	}
+
+	return params, nil
+}
+
+func parameterString(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+
+	return "", fmt.Errorf("must be a string, number, boolean or list")
 }
 
 func extractedFilePath(releaseFile string, name string) string {
 	return fmt.Sprintf("%v.%v", releaseFile, name)
 }
 
+// artifact is a local file uploaded to S3 and referenced by the template
+type artifact struct {
+	file      string             // name of the file in the release S3 path
+	localPath string             // path of the file to upload
+	setURI    func(s3URI string) // rewrites the template to reference the S3 URI
+}
+
+// releaseArtifacts lists the zip function and layer zips extracted by fenrir package
+// and the Api definition files, sorted by file name
+func releaseArtifacts(release *deployer.Release, releaseFile string) []*artifact {
+	artifacts := []*artifact{}
+
+	for name, res := range release.Template.GetAllServerlessFunctionResources() {
+		res := res
+		// container images are pushed to ECR by fenrir package
+		if template.IsImageFunction(res) {
+			continue
+		}
+
+		file := fmt.Sprintf("%v.zip", name)
+		artifacts = append(artifacts, &artifact{
+			file:      file,
+			localPath: extractedFilePath(releaseFile, file),
+			setURI: func(s3URI string) {
+				res.CodeUri = &serverless.Function_CodeUri{String: &s3URI}
+			},
+		})
+	}
+
+	for name, res := range release.Template.GetAllServerlessLayerVersionResources() {
+		res := res
+		file := fmt.Sprintf("%v.zip", name)
+		artifacts = append(artifacts, &artifact{
+			file:      file,
+			localPath: extractedFilePath(releaseFile, file),
+			setURI:    func(s3URI string) { res.ContentUri = &serverless.LayerVersion_ContentUri{String: &s3URI} },
+		})
+	}
+
+	// DefinitionUri is a file path relative to the release file
+	for name, res := range release.Template.GetAllServerlessApiResources() {
+		res := res
+		if res.DefinitionUri == nil || res.DefinitionUri.String == nil {
+			continue
+		}
+
+		localPath := filepath.Join(filepath.Dir(releaseFile), *res.DefinitionUri.String)
+		artifacts = append(artifacts, &artifact{
+			file:      fmt.Sprintf("%v%v", name, filepath.Ext(localPath)),
+			localPath: localPath,
+			setURI: func(s3URI string) {
+				res.DefinitionUri = &serverless.Api_DefinitionUri{String: &s3URI}
+			},
+		})
+	}
+
+	for name, res := range release.Template.Resources {
+		res, ok := res.(*cloudformation.CustomResource)
+		if !ok || res.Type != template.HttpApiType {
+			continue
+		}
+
+		definitionUri, ok := res.Properties["DefinitionUri"].(string)
+		if !ok {
+			continue
+		}
+
+		localPath := filepath.Join(filepath.Dir(releaseFile), definitionUri)
+		artifacts = append(artifacts, &artifact{
+			file:      fmt.Sprintf("%v%v", name, filepath.Ext(localPath)),
+			localPath: localPath,
+			setURI: func(s3URI string) {
+				res.Properties["DefinitionUri"] = s3URI
+			},
+		})
+	}
+
+	for name, res := range release.Template.GetAllServerlessStateMachineResources() {
+		res := res
+		if res.DefinitionUri == nil || res.DefinitionUri.String == nil {
+			continue
+		}
+
+		localPath := filepath.Join(filepath.Dir(releaseFile), *res.DefinitionUri.String)
+		artifacts = append(artifacts, &artifact{
+			file:      fmt.Sprintf("%v%v", name, filepath.Ext(localPath)),
+			localPath: localPath,
+			setURI: func(s3URI string) {
+				res.DefinitionUri = &serverless.StateMachine_DefinitionUri{String: &s3URI}
+			},
+		})
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].file < artifacts[j].file })
+
+	return artifacts
+}
+
 func s3FilePath(release *deployer.Release, name string) string {
 	return fmt.Sprintf("%v/%v", *release.ReleasePath(), name)
 }
@@ -103,8 +345,15 @@ func releaseFromFile(releaseFile *string, region *string, accountID *string) (*d
 
 	rawSAM = prepareTemplate(release, rawSAM)
 
+	params, err := parseParameters(*releaseFile, *release.ConfigName)
+	if err != nil {
+		return nil, err
+	}
+
+	release.Parameters = params
+
 	// Set all Service LambdaSHA values
-	template, err := parseTemplate(rawSAM)
+	template, err := parseTemplate(rawSAM, release.StaticValues())
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +373,9 @@ func waiter(ed *execution.Execution, sd *execution.StateDetails, err error) erro
 	}
 
 	var releaseError struct {
-		Error *bifrost.ReleaseError `json:"error,omitempty"`
+		Error          *bifrost.ReleaseError `json:"error,omitempty"`
+		ReleaseID      *string               `json:"release_id,omitempty"`
+		ApprovalStatus string                `json:"approval_status,omitempty"`
 	}
 
 	if sd != nil && sd.LastOutput != nil {
@@ -132,9 +383,27 @@ func waiter(ed *execution.Execution, sd *execution.StateDetails, err error) erro
 	}
 
 	fmt.Printf("\rExecution: %v", *ed.Status)
+	if releaseError.ApprovalStatus == "PENDING" {
+		fmt.Printf(" awaiting approval, run: fenrir approve %v", to.Strs(releaseError.ReleaseID))
+	}
 	if releaseError.Error != nil {
-		fmt.Printf("\nError: %v\nCause: %v\n", to.Strs(releaseError.Error.Error), to.Strs(releaseError.Error.Cause))
+		verrs := template.DecodeValidationErrors(to.Strs(releaseError.Error.Cause))
+		if len(verrs) == 0 {
+			fmt.Printf("\nError: %v\nCause: %v\n", to.Strs(releaseError.Error.Error), to.Strs(releaseError.Error.Cause))
+		} else {
+			fmt.Printf("\nError: %v\n", to.Strs(releaseError.Error.Error))
+			printValidationErrors(os.Stdout, verrs)
+		}
 	}
 
 	return nil
 }
+
+func printValidationErrors(out io.Writer, verrs []*template.ValidationError) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tTYPE\tPATH\tRULE\tMESSAGE")
+	for _, verr := range verrs {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", verr.ResourceName, verr.ResourceType, verr.Path, verr.Rule, verr.Message)
+	}
+	w.Flush()
+}
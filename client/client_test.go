@@ -1,8 +1,23 @@
 package client
 
 import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/s3"
+	goformation "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/mocks"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
+	stepmocks "github.com/coinbase/step/aws/mocks"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -18,3 +33,801 @@ func Test_Successful_Client_Parsing(t *testing.T) {
 	assert.Equal(t, *release.AwsAccountID, "000")
 	assert.Equal(t, *release.AwsRegion, "region")
 }
+
+func Test_Client_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fenrir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	releaseFile := filepath.Join(dir, "template.yml")
+	assert.NoError(t, ioutil.WriteFile(releaseFile, []byte(`
+ProjectName: project
+ConfigName: development
+AWSTemplateFormatVersion: "2010-09-09"
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: hello/
+      Handler: hello.lambda
+      Runtime: provided.al2023
+      Role: role_bad
+  goodbye:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: goodbye/
+      Handler: goodbye.lambda
+      Runtime: provided.al2023
+      Role: role_correct
+      VpcConfig:
+        SecurityGroupIds:
+          - sg_correct
+        SubnetIds:
+          - subnet_bad
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(releaseFile+".hello.zip", []byte("zip"), 0644))
+
+	release, err := releaseFromFile(&releaseFile, to.Strp("region"), to.Strp("00000000"))
+	assert.NoError(t, err)
+
+	awsc := mocks.MockAWS()
+	awsc.EC2Client.AddSecurityGroup("sg_correct", "project", "development", "goodbye", nil)
+	awsc.EC2Client.AddSubnet("subnet_bad", "subnet-2", false)
+	awsc.IAMClient.AddGetRole("role_correct", "project", "development", "_all")
+	awsc.IAMClient.AddGetRole("role_bad", "bad", "development", "hello")
+
+	errs := validate(awsc, release, &releaseFile)
+	assert.Equal(t, 3, len(errs))
+	assert.Regexp(t, "template.yml.goodbye.zip not found", errs[0].Error())
+	assert.Regexp(t, "AWS::Serverless::Function#goodbye: VpcConfig Validate Subnet Error", errs[1].Error())
+	assert.Regexp(t, "AWS::Serverless::Function#hello: Incorrect ProjectName for Role", errs[2].Error())
+}
+
+func Test_Client_PrintChanges(t *testing.T) {
+	var out bytes.Buffer
+	printChanges(&out, []*deployer.ChangeSetChange{
+		&deployer.ChangeSetChange{
+			Action:            "Modify",
+			LogicalResourceID: "hello",
+			ResourceType:      "AWS::Lambda::Function",
+			Replacement:       "False",
+			Scope:             []string{"Properties", "Tags"},
+		},
+	})
+
+	assert.Regexp(t, "Modify +hello +AWS::Lambda::Function +False +Properties,Tags", out.String())
+}
+
+func Test_Client_RollbackRelease(t *testing.T) {
+	awsc := mocks.MockAWS()
+
+	awsc.S3Client.AddGetObject("000/project/development/deployed_releases", `[
+		{"release_id": "release-1"},
+		{"release_id": "release-2"}
+	]`, nil)
+
+	awsc.S3Client.AddGetObject("000/project/development/release-1/release", `{
+		"release_id": "release-1",
+		"project_name": "project",
+		"config_name": "development",
+		"aws_account_id": "000",
+		"template": {"Resources": {}},
+		"s3_uris_sha256s": {
+			"s3://bucket/path.zip": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		}
+	}`, nil)
+
+	awsc.S3Client.AddGetObject("path.zip", "", nil)
+
+	release := mockConfigRelease()
+	err := rollbackRelease(awsc, release, to.Strp(""))
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, "release-1", *release.ReleaseID)
+	assert.Equal(t, map[string]string{
+		"s3://bucket/path.zip": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}, release.S3URISHA256s)
+
+	assert.Equal(t, "release-1", release.RollbackOf)
+
+	err = rollbackRelease(awsc, mockConfigRelease(), to.Strp("release-3"))
+	assert.Error(t, err)
+}
+
+func Test_Client_RollbackRelease_Image(t *testing.T) {
+	awsc := mocks.MockAWS()
+
+	awsc.S3Client.AddGetObject("000/project/development/deployed_releases", `[
+		{"release_id": "release-1"},
+		{"release_id": "release-2"}
+	]`, nil)
+
+	pinned := "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	awsc.S3Client.AddGetObject("000/project/development/release-1/release", `{
+		"release_id": "release-1",
+		"project_name": "project",
+		"config_name": "development",
+		"aws_account_id": "000",
+		"template": {"Resources": {"hello": {
+			"Type": "AWS::Serverless::Function",
+			"Metadata": {"FenrirPassthrough": {"PackageType": "Image", "ImageUri": "`+pinned+`"}}
+		}}},
+		"image_uris_digests": {
+			"`+pinned+`": "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		}
+	}`, nil)
+
+	release := mockConfigRelease()
+	err := rollbackRelease(awsc, release, to.Strp(""))
+	assert.NoError(t, err)
+
+	// The image is redeployed by the digest it was pinned to
+	assert.Equal(t, map[string]string{
+		pinned: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}, release.ImageURIDigests)
+	assert.NoError(t, release.ValidateImageDigests())
+}
+
+func Test_Client_PreviousRelease(t *testing.T) {
+	deployed := func(ids ...string) []*deployer.DeployedRelease {
+		releases := []*deployer.DeployedRelease{}
+		for _, id := range ids {
+			// id:rollback_of
+			parts := strings.SplitN(id, ":", 2)
+			d := &deployer.DeployedRelease{ReleaseID: parts[0]}
+			if len(parts) == 2 {
+				d.RollbackOf = parts[1]
+			}
+			releases = append(releases, d)
+		}
+		return releases
+	}
+
+	previous, err := previousRelease(deployed("r1", "r2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", previous)
+
+	// Rolling back again goes before the release that was rolled back to, never to the broken r3
+	previous, err = previousRelease(deployed("r1", "r2", "r3", "r4:r2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", previous)
+
+	previous, err = previousRelease(deployed("r1", "r2", "r3:r1", "r4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", previous)
+
+	_, err = previousRelease(deployed("r1", "r2", "r3:r1"))
+	assert.EqualError(t, err, "No previous deployed release to rollback to")
+
+	_, err = previousRelease(deployed("r1"))
+	assert.Error(t, err)
+
+	_, err = previousRelease(deployed("r2", "r3:r1"))
+	assert.EqualError(t, err, "Cannot find r1 rolled back to by r3")
+}
+
+func mockConfigRelease() *deployer.Release {
+	release := &deployer.Release{}
+	release.ProjectName = to.Strp("project")
+	release.ConfigName = to.Strp("development")
+	prepareRelease(release, to.Strp("region"), to.Strp("000"))
+	return release
+}
+
+// listS3Client returns CommonPrefixes for ListObjects
+type listS3Client struct {
+	*stepmocks.MockS3Client
+	prefixes []string
+}
+
+func (m *listS3Client) ListObjects(in *s3.ListObjectsInput) (*s3.ListObjectsOutput, error) {
+	output := &s3.ListObjectsOutput{}
+	for _, p := range m.prefixes {
+		output.CommonPrefixes = append(output.CommonPrefixes, &s3.CommonPrefix{Prefix: to.Strp(*in.Prefix + p + "/")})
+	}
+	return output, nil
+}
+
+func Test_Client_History(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.S3Client.AddGetObject("000/project/development/release-2020-01-01/result", `{
+		"success": true,
+		"finished_at": "2020-01-01T00:00:00Z",
+		"stack_status": "UPDATE_COMPLETE",
+		"outputs": {"ApiUrl": "https://api"}
+	}`, nil)
+	awsc.S3Client.AddGetObject("000/project/development/release-2020-01-02/result", `{
+		"success": false,
+		"finished_at": "2020-01-02T00:00:00Z",
+		"stack_status": "UPDATE_ROLLBACK_COMPLETE",
+		"error": {"Error": "Failed", "Cause": "stack: broke"}
+	}`, nil)
+
+	s3c := &listS3Client{awsc.S3Client, []string{"release-2020-01-01", "release-2020-01-02", "release-2020-01-03"}}
+	clients := &mockS3Clients{awsc, s3c}
+
+	results, err := history(clients, mockConfigRelease())
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "release-2020-01-03", *results[0].ReleaseID)
+	assert.Nil(t, results[0].FinishedAt)
+	assert.Equal(t, "UPDATE_ROLLBACK_COMPLETE", results[1].StackStatus)
+	assert.True(t, results[2].Success)
+
+	var out bytes.Buffer
+	printHistory(&out, results)
+	assert.Regexp(t, "release-2020-01-03 +- ", out.String())
+	assert.Regexp(t, "release-2020-01-02 +false +UPDATE_ROLLBACK_COMPLETE +stack: broke", out.String())
+	assert.Regexp(t, `release-2020-01-01 +true +UPDATE_COMPLETE +{"ApiUrl":"https://api"}`, out.String())
+}
+
+// mockS3Clients overrides the S3 client
+type mockS3Clients struct {
+	*mocks.MockClients
+	s3c aws.S3API
+}
+
+func (m *mockS3Clients) S3(*string, *string, *string) aws.S3API {
+	return m.s3c
+}
+
+func Test_Client_WriteApproval(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.STSClient.Arn = "arn:aws:sts::000:assumed-role/role/approver"
+
+	release := &deployer.Release{}
+	release.AwsAccountID = to.Strp("000")
+	release.ProjectName = to.Strp("project")
+	release.ConfigName = to.Strp("development")
+	release.ReleaseID = to.Strp("release-1")
+	release.Bucket = to.Strp("bucket")
+
+	assert.NoError(t, writeApproval(awsc, release, &deployer.Approval{Approved: true}))
+
+	// The approver is the caller identity, not an envar
+	raw := awsc.S3Client.GetObjectResp["000/project/development/release-1/approval"].Body
+	assert.JSONEq(t, `{"approved": true, "user": "arn:aws:sts::000:assumed-role/role/approver"}`, raw)
+}
+
+func Test_Client_Confirm(t *testing.T) {
+	assert.True(t, confirm(strings.NewReader("sam-project-development\n"), "sam-project-development"))
+	assert.False(t, confirm(strings.NewReader("sam-project\n"), "sam-project-development"))
+	assert.False(t, confirm(strings.NewReader(""), "sam-project-development"))
+}
+
+func Test_Client_EventStreamer(t *testing.T) {
+	cfc := &mocks.CFClient{}
+	out := &bytes.Buffer{}
+	es := newEventStreamer(cfc, out)
+
+	cfc.StackEvents = []*cloudformation.StackEvent{
+		&cloudformation.StackEvent{
+			EventId:            to.Strp("2"),
+			ClientRequestToken: to.Strp("changeset1"),
+			Timestamp:          to.Timep(time.Now()),
+			ResourceStatus:     to.Strp("CREATE_IN_PROGRESS"),
+			ResourceType:       to.Strp("AWS::Lambda::Function"),
+			LogicalResourceId:  to.Strp("hello"),
+		},
+		&cloudformation.StackEvent{
+			EventId:            to.Strp("1"),
+			ClientRequestToken: to.Strp("changeset0"),
+			Timestamp:          to.Timep(time.Now()),
+			ResourceStatus:     to.Strp("UPDATE_COMPLETE"),
+			LogicalResourceId:  to.Strp("old"),
+		},
+	}
+
+	es.poll(to.Strp("stack"), to.Strp("changeset1"))
+	assert.Regexp(t, "CREATE_IN_PROGRESS.*AWS::Lambda::Function hello", out.String())
+	assert.NotRegexp(t, "old", out.String())
+
+	// Only new events are printed, oldest first
+	cfc.StackEvents = append([]*cloudformation.StackEvent{
+		&cloudformation.StackEvent{
+			EventId:            to.Strp("4"),
+			ClientRequestToken: to.Strp("changeset1"),
+			Timestamp:          to.Timep(time.Now()),
+			ResourceStatus:     to.Strp("UPDATE_ROLLBACK_IN_PROGRESS"),
+			LogicalResourceId:  to.Strp("stack"),
+		},
+		&cloudformation.StackEvent{
+			EventId:              to.Strp("3"),
+			ClientRequestToken:   to.Strp("changeset1"),
+			Timestamp:            to.Timep(time.Now()),
+			ResourceStatus:       to.Strp("CREATE_FAILED"),
+			ResourceType:         to.Strp("AWS::Lambda::Function"),
+			LogicalResourceId:    to.Strp("hello"),
+			ResourceStatusReason: to.Strp("bad runtime"),
+		},
+	}, cfc.StackEvents...)
+
+	out.Reset()
+	es.poll(to.Strp("stack"), to.Strp("changeset1"))
+	assert.NotRegexp(t, "CREATE_IN_PROGRESS", out.String())
+	assert.Regexp(t, "(?s)CREATE_FAILED.*UPDATE_ROLLBACK_IN_PROGRESS", out.String())
+
+	out.Reset()
+	es.summary()
+	assert.Regexp(t, "Failed Resources:", out.String())
+	assert.Regexp(t, "AWS::Lambda::Function hello: bad runtime", out.String())
+
+	// Output that is not a terminal has no colors
+	assert.NotContains(t, out.String(), "\033[")
+
+	// New events are paged through until one already seen
+	cfc.StackEventsPageSize = 1
+	cfc.StackEvents = append([]*cloudformation.StackEvent{
+		&cloudformation.StackEvent{
+			EventId:            to.Strp("6"),
+			ClientRequestToken: to.Strp("changeset1"),
+			ResourceStatus:     to.Strp("UPDATE_ROLLBACK_COMPLETE"),
+			LogicalResourceId:  to.Strp("stack"),
+		},
+		&cloudformation.StackEvent{
+			EventId:            to.Strp("5"),
+			ClientRequestToken: to.Strp("changeset1"),
+			ResourceStatus:     to.Strp("DELETE_COMPLETE"),
+			LogicalResourceId:  to.Strp("hello"),
+		},
+	}, cfc.StackEvents...)
+
+	out.Reset()
+	es.poll(to.Strp("stack"), to.Strp("changeset1"))
+	assert.Regexp(t, "(?s)^\n DELETE_COMPLETE  hello \n UPDATE_ROLLBACK_COMPLETE  stack \n$", out.String())
+}
+
+func Test_Client_PackageNative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fenrir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod":  "module hello\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+		"template.yml": `
+ProjectName: "hello"
+ConfigName: "development"
+AWSTemplateFormatVersion: "2010-09-09"
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: .
+      Handler: hello.lambda
+      Runtime: go1.x
+  bootstrap:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: .
+      Handler: unused
+      Runtime: provided.al2
+  layer:
+    Type: AWS::Serverless::LayerVersion
+    Properties:
+      ContentUri: layer/
+`,
+		"layer/bin/tool": "tool",
+	}
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "layer", "bin"), 0755))
+
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	releaseFile := filepath.Join(dir, "template.yml")
+	assert.NoError(t, Package(&releaseFile, false))
+
+	sha, err := to.SHA256File(releaseFile + ".hello.zip")
+	assert.NoError(t, err)
+
+	r, err := zip.OpenReader(releaseFile + ".bootstrap.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, "bootstrap", r.File[0].Name)
+	assert.Equal(t, os.FileMode(0755), r.File[0].Mode().Perm())
+	r.Close()
+
+	r, err = zip.OpenReader(releaseFile + ".layer.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, "bin/tool", r.File[0].Name)
+	assert.Equal(t, os.FileMode(0644), r.File[0].Mode().Perm())
+	r.Close()
+
+	// Packaging again produces the same zip
+	assert.NoError(t, Package(&releaseFile, false))
+
+	sha2, err := to.SHA256File(releaseFile + ".hello.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, sha, sha2)
+}
+
+func Test_Client_PackageNative_Runtime(t *testing.T) {
+	_, err := binaryName("python3.8", "hello.handler")
+	assert.Regexp(t, "--docker", err)
+
+	name, err := binaryName("go1.x", "hello.lambda")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello.lambda", name)
+}
+
+func Test_Client_Validate_Artifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fenrir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	releaseFile := filepath.Join(dir, "template.yml")
+	assert.NoError(t, ioutil.WriteFile(releaseFile, []byte(`
+ProjectName: project
+ConfigName: development
+AWSTemplateFormatVersion: "2010-09-09"
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  layer:
+    Type: AWS::Serverless::LayerVersion
+    Properties:
+      ContentUri: layer/
+  api:
+    Type: AWS::Serverless::Api
+    Properties:
+      StageName: dev
+      DefinitionUri: swagger.yml
+  httpApi:
+    Type: AWS::Serverless::HttpApi
+    Properties:
+      StageName: dev
+      DefinitionUri: openapi.yml
+  missing:
+    Type: AWS::Serverless::Api
+    Properties:
+      StageName: dev
+      DefinitionUri: missing.yml
+  machine:
+    Type: AWS::Serverless::StateMachine
+    Properties:
+      DefinitionUri: definition.json
+      Role: role_correct
+`), 0644))
+
+	assert.NoError(t, ioutil.WriteFile(releaseFile+".layer.zip", []byte("zip"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "swagger.yml"), []byte("swagger: 2.0"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "openapi.yml"), []byte("openapi: 3.0.1"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "definition.json"), []byte(`{"StartAt": "Done", "States": {"Done": {"Type": "Succeed"}}}`), 0644))
+
+	release, err := releaseFromFile(&releaseFile, to.Strp("region"), to.Strp("00000000"))
+	assert.NoError(t, err)
+
+	awsc := mocks.MockAWS()
+	awsc.IAMClient.AddGetRole("role_correct", "project", "development", "_all")
+
+	errs := validate(awsc, release, &releaseFile)
+	assert.Equal(t, 1, len(errs))
+	assert.Regexp(t, "missing.yml not found", errs[0].Error())
+
+	layer := release.Template.GetAllServerlessLayerVersionResources()["layer"]
+	assert.Regexp(t, "^s3://.*/layer.zip$", *layer.ContentUri.String)
+	assert.Contains(t, release.S3URISHA256s, *layer.ContentUri.String)
+
+	api := release.Template.GetAllServerlessApiResources()["api"]
+	assert.Regexp(t, "^s3://.*/api.yml$", *api.DefinitionUri.String)
+	assert.Contains(t, release.S3URISHA256s, *api.DefinitionUri.String)
+
+	httpApi := release.Template.Resources["httpApi"].(*goformation.CustomResource)
+	assert.Regexp(t, "^s3://.*/httpApi.yml$", httpApi.Properties["DefinitionUri"])
+	assert.Contains(t, release.S3URISHA256s, httpApi.Properties["DefinitionUri"])
+
+	machine := release.Template.GetAllServerlessStateMachineResources()["machine"]
+	assert.Regexp(t, "^s3://.*/machine.json$", *machine.DefinitionUri.String)
+	assert.Contains(t, release.S3URISHA256s, *machine.DefinitionUri.String)
+}
+
+func Test_Client_ParseParameters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fenrir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	releaseFile := filepath.Join(dir, "template.yml")
+
+	// No file means no values
+	params, err := parseParameters(releaseFile, "development")
+	assert.NoError(t, err)
+	assert.Nil(t, params)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "parameters"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "parameters", "development.yml"), []byte(`
+Stage: dev
+Memory: 1024
+Large: 10000000
+Enabled: true
+Domains:
+  - a.example.com
+  - b.example.com
+`), 0644))
+
+	params, err = parseParameters(releaseFile, "development")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Stage":   "dev",
+		"Memory":  "1024",
+		"Large":   "10000000",
+		"Enabled": "true",
+		"Domains": "a.example.com,b.example.com",
+	}, params)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "parameters", "production.yml"), []byte(`
+Nested:
+  Key: value
+`), 0644))
+
+	_, err = parseParameters(releaseFile, "production")
+	assert.Regexp(t, "Nested must be a string, number, boolean or list", err)
+}
+
+func Test_Client_ParseTemplate_Conditions(t *testing.T) {
+	rawSAM := `
+Conditions:
+  IsDevelopment: !Equals [!Ref "Fenrir::ConfigName", development]
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: s3://bucket/path.zip
+      Handler: hello-world
+      Runtime: go1.x
+      MemorySize: !If [IsDevelopment, 128, 1024]
+  prodHello:
+    Type: AWS::Serverless::Function
+    Condition: IsDevelopment
+    Properties:
+      CodeUri: s3://bucket/path.zip
+      Handler: hello-world
+      Runtime: go1.x
+`
+
+	tmpl, err := parseTemplate(rawSAM, template.StaticValues{ConfigName: "development"})
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl.Conditions)
+	assert.Contains(t, tmpl.Resources, "prodHello")
+	assert.Equal(t, 128, tmpl.GetAllServerlessFunctionResources()["hello"].MemorySize)
+
+	tmpl, err = parseTemplate(rawSAM, template.StaticValues{ConfigName: "production"})
+	assert.NoError(t, err)
+	assert.NotContains(t, tmpl.Resources, "prodHello")
+	assert.Equal(t, 1024, tmpl.GetAllServerlessFunctionResources()["hello"].MemorySize)
+}
+
+func Test_Client_ParseTemplate_Parameters(t *testing.T) {
+	rawSAM := `
+Parameters:
+  Memory:
+    Type: Number
+    Default: 128
+  Threshold:
+    Type: Number
+    Default: 1.5
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: s3://bucket/path.zip
+      Handler: hello-world
+      Runtime: provided.al2023
+      MemorySize: !Ref Memory
+      Timeout: !Ref Memory
+  alarm:
+    Type: AWS::CloudWatch::Alarm
+    Properties:
+      ComparisonOperator: GreaterThanThreshold
+      EvaluationPeriods: 1
+      Threshold: !Ref Threshold
+`
+
+	tmpl, err := parseTemplate(rawSAM, template.StaticValues{Parameters: map[string]string{"Memory": "1024"}})
+	assert.NoError(t, err)
+
+	hello := tmpl.GetAllServerlessFunctionResources()["hello"]
+	assert.Equal(t, 1024, hello.MemorySize)
+	assert.Equal(t, 1024, hello.Timeout)
+
+	alarm, err := tmpl.GetCloudWatchAlarmWithName("alarm")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, alarm.Threshold)
+}
+
+func Test_Client_ParseTemplate_UnsupportedType(t *testing.T) {
+	_, err := parseTemplate(`
+Resources:
+  connector:
+    Type: AWS::Serverless::Connector
+    Properties:
+      Permissions:
+        - Read
+`, template.StaticValues{})
+
+	assert.EqualError(t, err, `Resource connector: Type "AWS::Serverless::Connector" is not supported`)
+}
+
+func Test_Client_ParseTemplate_UnsupportedProperty(t *testing.T) {
+	_, err := parseTemplate(`
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      Runtime: provided.al2023
+      EphemeralStorage:
+        Size: 1024
+`, template.StaticValues{})
+
+	assert.EqualError(t, err, `Resource hello: Property "EphemeralStorage" is not supported`)
+}
+
+func Test_Client_ParseTemplate_Passthrough(t *testing.T) {
+	tmpl, err := parseTemplate(`
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      Runtime: provided.al2023
+      Policies:
+        - SSMParameterReadPolicy:
+            ParameterName: parameter
+`, template.StaticValues{})
+	assert.NoError(t, err)
+
+	fn, err := tmpl.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.Nil(t, fn.Policies)
+
+	passthrough, err := template.GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "parameter", passthrough.Policies[0].SSMParameterReadPolicy.ParameterName)
+
+	// Container image properties are in the Metadata
+	tmpl, err = parseTemplate(`
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      PackageType: Image
+      ImageUri: hello
+      ImageConfig:
+        Command: ["bootstrap"]
+`, template.StaticValues{})
+	assert.NoError(t, err)
+
+	fn, err = tmpl.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.True(t, template.IsImageFunction(fn))
+
+	passthrough, err = template.GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", passthrough.ImageUri)
+	assert.Equal(t, []string{"bootstrap"}, passthrough.ImageConfig.Command)
+	assert.Equal(t, "x86_64", template.FunctionArchitecture(fn))
+
+	// Architectures are in the Metadata
+	tmpl, err = parseTemplate(`
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      Runtime: provided.al2023
+      Architectures:
+        - arm64
+`, template.StaticValues{})
+	assert.NoError(t, err)
+
+	fn, err = tmpl.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "arm64", template.FunctionArchitecture(fn))
+	assert.Equal(t, "arm64", goArch(template.FunctionArchitecture(fn)))
+
+	// The Metadata key is reserved
+	_, err = parseTemplate(`
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Metadata:
+      FenrirPassthrough: {}
+    Properties:
+      Runtime: provided.al2023
+`, template.StaticValues{})
+	assert.EqualError(t, err, "Resource hello: Metadata FenrirPassthrough is reserved")
+
+	// HttpApi resources are custom resources and their events are in the Metadata
+	tmpl, err = parseTemplate(`
+Resources:
+  api:
+    Type: AWS::Serverless::HttpApi
+    Properties:
+      StageName: dev
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      Runtime: provided.al2023
+      Events:
+        Get:
+          Type: HttpApi
+          Properties:
+            ApiId: !Ref api
+            Method: GET
+            Path: /hello
+`, template.StaticValues{})
+	assert.NoError(t, err)
+	assert.Equal(t, template.HttpApiType, tmpl.Resources["api"].AWSCloudFormationType())
+
+	fn, err = tmpl.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.Empty(t, fn.Events)
+
+	passthrough, err = template.GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "/hello", passthrough.Events["Get"].Properties.Path)
+
+	// The custom resource types are reserved
+	_, err = parseTemplate(`
+Resources:
+  api:
+    Type: Custom::FenrirHttpApi
+`, template.StaticValues{})
+	assert.EqualError(t, err, `Resource api: Type "Custom::FenrirHttpApi" is reserved`)
+}
+
+func Test_Client_ResolveImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fenrir")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	releaseFile := filepath.Join(dir, "template.yml")
+	assert.NoError(t, ioutil.WriteFile(releaseFile, []byte(`
+ProjectName: project
+ConfigName: development
+AWSTemplateFormatVersion: "2010-09-09"
+Transform: AWS::Serverless-2016-10-31
+Resources:
+  hello:
+    Type: AWS::Serverless::Function
+    Properties:
+      PackageType: Image
+      ImageUri: hello
+      Role: role_correct
+  pinned:
+    Type: AWS::Serverless::Function
+    Properties:
+      PackageType: Image
+      ImageUri: 000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:v1
+      Role: role_correct
+`), 0644))
+
+	release, err := releaseFromFile(&releaseFile, to.Strp("us-east-1"), to.Strp("000000000000"))
+	assert.NoError(t, err)
+
+	// Image functions have no zip
+	assert.Empty(t, releaseArtifacts(release, releaseFile))
+
+	digest := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	awsc := mocks.MockAWS()
+	awsc.ECRClient.AddRepository("hello", map[string]string{"ProjectName": "project", "ConfigName": "development"})
+	awsc.ECRClient.AddImage("hello", "development", digest)
+
+	assert.Regexp(t, "pinned: ImageUri .*/hello:v1 ImageNotFoundException", resolveImages(awsc.ECR(nil, nil, nil), release))
+
+	awsc.ECRClient.AddImage("hello", "v1", "sha256:"+strings.Repeat("1", 64))
+	assert.NoError(t, resolveImages(awsc.ECR(nil, nil, nil), release))
+
+	hello := "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@" + digest
+	assert.Equal(t, map[string]string{
+		hello: digest,
+		"000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:" + strings.Repeat("1", 64): "sha256:" + strings.Repeat("1", 64),
+	}, release.ImageURIDigests)
+
+	fn := release.Template.GetAllServerlessFunctionResources()["hello"]
+	passthrough, err := template.GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Equal(t, hello, passthrough.ImageUri)
+}
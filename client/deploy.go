@@ -3,6 +3,7 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/aws/aws-sdk-go/service/sfn/sfniface"
 	"github.com/coinbase/fenrir/aws"
@@ -31,20 +32,19 @@ func Deploy(step_fn *string, releaseFile *string) error {
 	return deploy(&aws.ClientsStr{}, release, deployerARN, releaseFile)
 }
 
-func uploadFile(awsc aws.Clients, file string, releaseFile string, release *deployer.Release) (string, string, error) {
-	filePath := extractedFilePath(releaseFile, file)
-	s3URI := s3FileURI(release, file)
+func uploadFile(awsc aws.Clients, art *artifact, release *deployer.Release) (string, string, error) {
+	s3URI := s3FileURI(release, art.file)
 
-	fileSHA, err := to.SHA256File(filePath)
+	fileSHA, err := to.SHA256File(art.localPath)
 	if err != nil {
 		return "", "", err
 	}
 
 	err = s3.PutFile(
 		awsc.S3(nil, nil, nil),
-		to.Strp(filePath),
+		to.Strp(art.localPath),
 		release.Bucket,
-		to.Strp(s3FilePath(release, file)),
+		to.Strp(s3FilePath(release, art.file)),
 	)
 
 	if err != nil {
@@ -58,21 +58,37 @@ func deploy(awsc aws.Clients, release *deployer.Release, deployerARN *string, re
 
 	release.S3URISHA256s = map[string]string{}
 
-	// replace CodeURI with s3 path to uploaded zip
-	// Also write fileSHA
-	for name, res := range release.Template.GetAllServerlessFunctionResources() {
-		file := fmt.Sprintf("%v.zip", name)
+	// pin container images by the digest of their tag
+	if err := resolveImages(awsc.ECR(nil, nil, nil), release); err != nil {
+		return err
+	}
 
-		s3URI, fileSHA, err := uploadFile(awsc, file, *releaseFile, release)
+	// replace function CodeUri, layer ContentUri and Api and StateMachine DefinitionUri
+	// with the s3 path to the uploaded file. Also write fileSHA
+	for _, art := range releaseArtifacts(release, *releaseFile) {
+		s3URI, fileSHA, err := uploadFile(awsc, art, release)
 
 		if err != nil {
 			return err
 		}
 
-		res.CodeUri.String = &s3URI
+		art.setURI(s3URI)
 		release.S3URISHA256s[s3URI] = fileSHA
 	}
 
+	return start(awsc, release, deployerARN)
+}
+
+// start uploads the release and executes the deployer, waiting for it to finish
+func start(awsc aws.Clients, release *deployer.Release, deployerARN *string) error {
+	// The caller cannot approve its own release
+	user, err := callerArn(awsc)
+	if err != nil {
+		return err
+	}
+
+	release.User = user
+
 	// Uploading the Release to S3 to match SHAs
 	if err := s3.PutStruct(awsc.S3(nil, nil, nil), release.Bucket, release.ReleasePath(), release); err != nil {
 		return err
@@ -83,8 +99,9 @@ func deploy(awsc aws.Clients, release *deployer.Release, deployerARN *string, re
 		return err
 	}
 
-	// Execute every second
-	exec.WaitForExecution(awsc.SFN(nil, nil, nil), 1, waiter)
+	// Execute every second, printing stack events as they arrive
+	events := newEventStreamer(awsc.CF(nil, nil, nil), os.Stdout)
+	exec.WaitForExecution(awsc.SFN(nil, nil, nil), 1, events.waiter)
 
 	fmt.Println("")
 
@@ -97,10 +114,32 @@ func deploy(awsc aws.Clients, release *deployer.Release, deployerARN *string, re
 		return err
 	}
 
-	if outRelease.LogSummary != nil {
+	for _, warning := range outRelease.Warnings {
+		fmt.Printf("WARNING %v\n", warning)
+	}
+
+	if outRelease.Plan {
+		printChanges(os.Stdout, outRelease.Changes)
+		if outRelease.ChangeSetBlocked {
+			fmt.Printf("\nWARNING deploy would be blocked: %v\n", outRelease.ChangeSetBlockedReason)
+		}
+		return nil
+	}
+
+	// Only print the log summary if the events were not streamed
+	if len(events.seen) == 0 && outRelease.LogSummary != nil {
 		fmt.Println(*outRelease.LogSummary)
 	}
 
+	events.summary()
+
+	if len(outRelease.RetainedResources) > 0 {
+		fmt.Println("Retained Resources:")
+		for _, r := range outRelease.RetainedResources {
+			fmt.Println(r)
+		}
+	}
+
 	fmt.Println("")
 	fmt.Println(to.PrettyJSON(outRelease.Outputs))
 
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Destroy deletes the stack of a project config
+func Destroy(step_fn *string, projectName, configName *string) error {
+	release, err := configRelease(projectName, configName)
+	if err != nil {
+		return err
+	}
+
+	if !confirm(os.Stdin, *release.StackName) {
+		return fmt.Errorf("Destroy not confirmed")
+	}
+
+	release.Destroy = true
+
+	deployerARN := to.StepArn(release.AwsRegion, release.AwsAccountID, step_fn)
+
+	return start(&aws.ClientsStr{}, release, deployerARN)
+}
+
+// confirm asks the user to type the stack name
+func confirm(in io.Reader, stackName string) bool {
+	fmt.Printf("This will delete the stack %v, type its name to confirm: ", stackName)
+
+	text, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+
+	return strings.TrimSpace(text) == stackName
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/execution"
+	"github.com/coinbase/step/utils/to"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// eventStreamer tails the stack events of the change set being deployed
+type eventStreamer struct {
+	cfc aws.CFAPI
+	out io.Writer
+
+	// color statuses and clear the "Execution: RUNNING" line, only if out is a terminal
+	color bool
+
+	seen   map[string]bool
+	failed []*cloudformation.StackEvent
+}
+
+func newEventStreamer(cfc aws.CFAPI, out io.Writer) *eventStreamer {
+	return &eventStreamer{cfc: cfc, out: out, color: isTerminal(out), seen: map[string]bool{}}
+}
+
+// isTerminal is true if out is a terminal and not e.g. a pipe or file in CI
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// waiter prints new stack events before calling the default waiter
+func (es *eventStreamer) waiter(ed *execution.Execution, sd *execution.StateDetails, err error) error {
+	if err == nil && sd != nil && sd.LastOutput != nil {
+		var stack struct {
+			StackName     *string `json:"stack_name,omitempty"`
+			StackID       *string `json:"stack_id,omitempty"`
+			ChangeSetName *string `json:"change_set_name,omitempty"`
+		}
+
+		json.Unmarshal([]byte(*sd.LastOutput), &stack)
+
+		stackName := stack.StackName
+		if stack.StackID != nil {
+			stackName = stack.StackID
+		}
+
+		es.poll(stackName, stack.ChangeSetName)
+	}
+
+	return waiter(ed, sd, err)
+}
+
+// poll fetches the latest events, printing the ones for token not yet seen
+func (es *eventStreamer) poll(stackName *string, token *string) {
+	if stackName == nil || token == nil {
+		return
+	}
+
+	// Events are returned newest first, page until an event already seen
+	// or one from an earlier operation on the stack
+	newEvents := []*cloudformation.StackEvent{}
+	input := &cloudformation.DescribeStackEventsInput{StackName: stackName}
+	for {
+		output, err := es.cfc.DescribeStackEvents(input)
+		if err != nil || output == nil {
+			return // The stack may not exist yet
+		}
+
+		done := false
+		for _, e := range output.StackEvents {
+			if e.ClientRequestToken == nil {
+				continue
+			}
+
+			id := to.Strs(e.EventId)
+			if es.seen[id] || *e.ClientRequestToken != *token {
+				done = true
+				break
+			}
+
+			newEvents = append([]*cloudformation.StackEvent{e}, newEvents...)
+		}
+
+		if done || output.NextToken == nil {
+			break
+		}
+
+		input.NextToken = output.NextToken
+	}
+
+	if len(newEvents) > 0 {
+		// \r\033[K clears the "Execution: RUNNING" line, which is ended instead if out is not a terminal
+		if es.color {
+			fmt.Fprint(es.out, "\r\033[K")
+		} else {
+			fmt.Fprintln(es.out)
+		}
+	}
+
+	for _, e := range newEvents {
+		es.seen[to.Strs(e.EventId)] = true
+		es.print(e)
+		if strings.HasSuffix(to.Strs(e.ResourceStatus), "_FAILED") {
+			es.failed = append(es.failed, e)
+		}
+	}
+}
+
+// print writes a single event in the same format as the deployers log summary
+func (es *eventStreamer) print(e *cloudformation.StackEvent) {
+	timestamp := ""
+	if e.Timestamp != nil {
+		timestamp = e.Timestamp.Format(time.RFC3339)
+	}
+
+	fmt.Fprintf(
+		es.out,
+		"%s %s %s %s %s\n",
+		timestamp,
+		es.colorStatus(to.Strs(e.ResourceStatus)),
+		to.Strs(e.ResourceType),
+		to.Strs(e.LogicalResourceId),
+		to.Strs(e.ResourceStatusReason),
+	)
+}
+
+// summary lists the resources that failed and why
+func (es *eventStreamer) summary() {
+	if len(es.failed) == 0 {
+		return
+	}
+
+	fmt.Fprintln(es.out, "\nFailed Resources:")
+	for _, e := range es.failed {
+		fmt.Fprintf(
+			es.out,
+			"%s %s %s: %s\n",
+			es.colorStatus(to.Strs(e.ResourceStatus)),
+			to.Strs(e.ResourceType),
+			to.Strs(e.LogicalResourceId),
+			to.Strs(e.ResourceStatusReason),
+		)
+	}
+}
+
+func (es *eventStreamer) colorStatus(status string) string {
+	if !es.color {
+		return status
+	}
+
+	switch {
+	case strings.HasSuffix(status, "_FAILED"), strings.Contains(status, "ROLLBACK"):
+		return colorRed + status + colorReset
+	case strings.HasSuffix(status, "_COMPLETE"), status == "DELETE_SKIPPED":
+		return colorGreen + status + colorReset
+	case strings.HasSuffix(status, "_IN_PROGRESS"):
+		return colorYellow + status + colorReset
+	}
+
+	return status
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/cf"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// History prints the past releases of a project config
+func History(projectName, configName *string) error {
+	release, err := configRelease(projectName, configName)
+	if err != nil {
+		return err
+	}
+
+	results, err := history(&aws.ClientsStr{}, release)
+	if err != nil {
+		return err
+	}
+
+	printHistory(os.Stdout, results)
+	return nil
+}
+
+// Status prints the deployed stack of a project config
+func Status(projectName, configName *string) error {
+	release, err := configRelease(projectName, configName)
+	if err != nil {
+		return err
+	}
+
+	return status(os.Stdout, &aws.ClientsStr{}, release)
+}
+
+// configRelease returns a release without a template used to find the paths of a project config
+func configRelease(projectName, configName *string) (*deployer.Release, error) {
+	region, accountID := to.RegionAccount()
+
+	if is.EmptyStr(region) || is.EmptyStr(accountID) {
+		return nil, fmt.Errorf("AWS_REGION and AWS_ACCOUNT_ID envars, maybe use assume-role")
+	}
+
+	if is.EmptyStr(projectName) || is.EmptyStr(configName) {
+		return nil, fmt.Errorf("project_name and config_name required")
+	}
+
+	release := &deployer.Release{}
+	release.ProjectName = projectName
+	release.ConfigName = configName
+
+	prepareRelease(release, region, accountID)
+
+	return release, nil
+}
+
+// releaseIDs lists the release directories of the project config
+func releaseIDs(s3c aws.S3API, release *deployer.Release) ([]string, error) {
+	prefix := fmt.Sprintf("%v/", *release.RootDir())
+
+	ids := []string{}
+	input := &awss3.ListObjectsInput{
+		Bucket:    release.Bucket,
+		Prefix:    &prefix,
+		Delimiter: to.Strp("/"),
+	}
+
+	for {
+		output, err := s3c.ListObjects(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if output == nil {
+			break
+		}
+
+		for _, p := range output.CommonPrefixes {
+			id := strings.TrimSuffix(strings.TrimPrefix(to.Strs(p.Prefix), prefix), "/")
+			if id != "" {
+				ids = append(ids, id)
+			}
+		}
+
+		if output.IsTruncated == nil || !*output.IsTruncated || output.NextMarker == nil {
+			break
+		}
+
+		input.Marker = output.NextMarker
+	}
+
+	return ids, nil
+}
+
+// history returns the results of the project configs releases newest first
+func history(awsc aws.Clients, release *deployer.Release) ([]*deployer.ReleaseResult, error) {
+	s3c := awsc.S3(nil, nil, nil)
+
+	ids, err := releaseIDs(s3c, release)
+	if err != nil {
+		return nil, err
+	}
+
+	// ReleaseIDs start with their creation time
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	results := []*deployer.ReleaseResult{}
+	for _, id := range ids {
+		r := *release
+		r.ReleaseID = to.Strp(id)
+
+		var result deployer.ReleaseResult
+		err := s3.GetStruct(s3c, r.Bucket, r.ResultPath(), &result)
+		if err != nil {
+			switch err.(type) {
+			case *s3.NotFoundError:
+				// Running or never started
+				result = deployer.ReleaseResult{}
+			default:
+				return nil, err
+			}
+		}
+
+		result.ReleaseID = r.ReleaseID
+		results = append(results, &result)
+	}
+
+	return results, nil
+}
+
+func printHistory(out io.Writer, results []*deployer.ReleaseResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RELEASE ID\tCREATED AT\tSUCCESS\tSTACK STATUS\tERROR\tOUTPUTS")
+	for _, r := range results {
+		success := "-" // No result yet
+		if r.FinishedAt != nil {
+			success = fmt.Sprintf("%v", r.Success)
+		}
+
+		if r.Plan {
+			success += " (plan)"
+		}
+
+		createdAt := ""
+		if r.CreatedAt != nil {
+			createdAt = r.CreatedAt.Format(time.RFC3339)
+		}
+
+		errStr := ""
+		if r.Error != nil {
+			errStr = strings.Replace(to.Strs(r.Error.Cause), "\n", " ", -1)
+			if verrs := template.DecodeValidationErrors(errStr); verrs != nil {
+				errStr = fmt.Sprintf("%v validation errors", len(verrs))
+			}
+		}
+
+		outputs := ""
+		if len(r.Outputs) > 0 {
+			outputs = to.CompactJSONStr(r.Outputs)
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", to.Strs(r.ReleaseID), createdAt, success, r.StackStatus, errStr, outputs)
+	}
+	w.Flush()
+}
+
+func status(out io.Writer, awsc aws.Clients, release *deployer.Release) error {
+	stack, err := cf.DescribeStack(awsc.CF(nil, nil, nil), release.StackName)
+	if err != nil {
+		switch err.(type) {
+		case cf.NotFoundError:
+			fmt.Fprintf(out, "Stack %v not found\n", *release.StackName)
+			return nil
+		default:
+			return err
+		}
+	}
+
+	tags := map[string]string{}
+	for _, tag := range stack.Tags {
+		tags[to.Strs(tag.Key)] = to.Strs(tag.Value)
+	}
+
+	fmt.Fprintf(out, "Stack:       %v\n", *release.StackName)
+	fmt.Fprintf(out, "Status:      %v %v\n", to.Strs(stack.StackStatus), to.Strs(stack.StackStatusReason))
+	fmt.Fprintf(out, "Deployed:    %v\n", tags["ReleaseID"])
+
+	deployed, err := release.DeployedReleases(awsc.S3(nil, nil, nil))
+	if err == nil && len(deployed) > 0 {
+		fmt.Fprintf(out, "Last Deploy: %v\n", deployed[len(deployed)-1].ReleaseID)
+	}
+
+	fmt.Fprintf(out, "Tags:        %v\n", to.CompactJSONStr(tags))
+
+	outputs := map[string]string{}
+	for _, op := range stack.Outputs {
+		outputs[to.Strs(op.OutputKey)] = to.Strs(op.OutputValue)
+	}
+	fmt.Fprintf(out, "Outputs:     %v\n", to.CompactJSONStr(outputs))
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/ecr"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
+)
+
+// imageFunctions are the container image functions (PackageType: Image) by name, with their sorted names
+func imageFunctions(release *deployer.Release) ([]string, map[string]*serverless.Function) {
+	functions := map[string]*serverless.Function{}
+	names := []string{}
+	for name, fn := range release.Template.GetAllServerlessFunctionResources() {
+		if template.IsImageFunction(fn) {
+			functions[name] = fn
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, functions
+}
+
+// functionImageURI parses the functions ImageUri, a full ECR image URI or a repository in the releases
+// account and region. An image without a tag or digest is tagged with the ConfigName
+func functionImageURI(release *deployer.Release, fn *serverless.Function) (*ecr.ImageURI, error) {
+	passthrough, err := template.GetPassthrough(fn.AWSCloudFormationMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	imageURI := passthrough.ImageUri
+	if imageURI == "" {
+		return nil, fmt.Errorf("ImageUri required with PackageType Image")
+	}
+
+	if !strings.Contains(imageURI, ".dkr.ecr.") {
+		imageURI = fmt.Sprintf("%v.dkr.ecr.%v.amazonaws.com/%v", *release.AwsAccountID, *release.AwsRegion, imageURI)
+	}
+
+	uri, err := ecr.ParseImageURI(imageURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Tag == "" && uri.Digest == "" {
+		uri.Tag = *release.ConfigName
+	}
+
+	return uri, nil
+}
+
+// resolveImages pins every container image functions ImageUri by the digest ECR has for it
+// and records the digests on the release, like the S3 SHAs of the uploaded files
+func resolveImages(ecrc aws.ECRAPI, release *deployer.Release) error {
+	release.ImageURIDigests = map[string]string{}
+
+	names, functions := imageFunctions(release)
+	for _, name := range names {
+		fn := functions[name]
+
+		uri, err := functionImageURI(release, fn)
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		image, err := ecr.FindImage(ecrc, uri)
+		if err != nil {
+			return fmt.Errorf("%v: ImageUri %v %v", name, uri, err)
+		}
+
+		pinned := image.URI.String()
+		if err := template.SetPassthroughProperty(fn.AWSCloudFormationMetadata, "ImageUri", pinned); err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		release.ImageURIDigests[pinned] = image.URI.Digest
+	}
+
+	return nil
+}
+
+// pushImages builds every container image function and pushes it to its ImageUri. The build uses
+// the SAM CLI DockerContext and Dockerfile Metadata, defaulting to the Dockerfile beside the release file
+func pushImages(ecrc aws.ECRAPI, release *deployer.Release, releaseFile *string) error {
+	templateDir := filepath.Dir(*releaseFile)
+
+	// functions sharing an ImageUri are only built once, for their platform
+	pushed := map[string]string{}
+	loggedIn := map[string]bool{}
+
+	names, functions := imageFunctions(release)
+	for _, name := range names {
+		fn := functions[name]
+
+		uri, err := functionImageURI(release, fn)
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		if uri.Digest != "" {
+			return fmt.Errorf("%v: ImageUri %v is pinned by digest, fenrir package needs a tag to push", name, uri)
+		}
+
+		// the image must be built for the functions architecture, so functions sharing it must share that too
+		platform := "linux/" + goArch(template.FunctionArchitecture(fn))
+		if p, ok := pushed[uri.String()]; ok {
+			if p != platform {
+				return fmt.Errorf("%v: ImageUri %v is also pushed for %v", name, uri, p)
+			}
+			continue
+		}
+
+		if !loggedIn[uri.RegistryID] {
+			user, password, endpoint, err := ecr.Login(ecrc, uri.RegistryID)
+			if err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+
+			if err := dockerLogin(user, password, endpoint); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+
+			loggedIn[uri.RegistryID] = true
+		}
+
+		context, dockerfile := templateDir, "Dockerfile"
+		if dir, ok := fn.AWSCloudFormationMetadata["DockerContext"].(string); ok {
+			context = filepath.Join(templateDir, dir)
+		}
+
+		if file, ok := fn.AWSCloudFormationMetadata["Dockerfile"].(string); ok {
+			dockerfile = file
+		}
+
+		err = execute("docker", "build", "--platform", platform, "-t", uri.String(), "-f", filepath.Join(context, dockerfile), context)
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		if err := execute("docker", "push", uri.String()); err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		pushed[uri.String()] = platform
+	}
+
+	return nil
+}
+
+// dockerLogin logs docker into the registry, passing the password on stdin so it is not in the process list
+func dockerLogin(user, password, endpoint string) error {
+	cmd := exec.Command("docker", "login", "--username", user, "--password-stdin", endpoint)
+	cmd.Stdin = strings.NewReader(password)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Println("docker login", endpoint)
+
+	return cmd.Run()
+}
@@ -1,22 +1,67 @@
 package client
 
 import (
+	"archive/zip"
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
+	"github.com/coinbase/step/utils/is"
 	"github.com/coinbase/step/utils/to"
 )
 
-func Package(releaseFile *string) error {
+// Package builds a zip for every function, natively or with docker,
+// and builds and pushes the container image functions to ECR
+func Package(releaseFile *string, docker bool) error {
 	release, err := releaseFromFile(releaseFile, to.Strp("region"), to.Strp("account"))
 	if err != nil {
 		return err
 	}
 
+	if docker {
+		err = packageDocker(release, releaseFile)
+	} else {
+		err = packageNative(release, releaseFile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Images are pushed to the releases account, so it must be known
+	if names, _ := imageFunctions(release); len(names) > 0 {
+		region, accountID := to.RegionAccount()
+		if is.EmptyStr(region) || is.EmptyStr(accountID) {
+			return fmt.Errorf("AWS_REGION and AWS_ACCOUNT_ID envars required to push images, maybe use assume-role")
+		}
+
+		release.AwsRegion, release.AwsAccountID = region, accountID
+
+		awsc := &aws.ClientsStr{}
+		if err := pushImages(awsc.ECR(nil, nil, nil), release, releaseFile); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Complete")
+
+	return nil
+}
+
+// packageDocker builds the Dockerfile and copies the function and layer <name>.zip
+// out of the container root
+func packageDocker(release *deployer.Release, releaseFile *string) error {
 	buildTag := strings.ToLower(to.RandomString(8))
-	err = execute("docker", "build", "-t", buildTag, ".")
+	err := execute("docker", "build", "-t", buildTag, ".")
 	if err != nil {
 		return err
 	}
@@ -29,7 +74,18 @@ func Package(releaseFile *string) error {
 
 	defer execute("docker", "rm", containerName)
 
-	for name, _ := range release.Template.GetAllServerlessFunctionResources() {
+	names := []string{}
+	for name, fn := range release.Template.GetAllServerlessFunctionResources() {
+		if !template.IsImageFunction(fn) {
+			names = append(names, name)
+		}
+	}
+
+	for name, _ := range release.Template.GetAllServerlessLayerVersionResources() {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
 		err = execute(
 			"docker",
 			"cp",
@@ -41,11 +97,229 @@ func Package(releaseFile *string) error {
 		}
 	}
 
-	fmt.Println("Complete")
+	return nil
+}
+
+// packageNative cross-compiles each go function and zips it, and each layer
+// directory, deterministically
+func packageNative(release *deployer.Release, releaseFile *string) error {
+	tmpDir, err := ioutil.TempDir("", "fenrir")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+
+	templateDir := filepath.Dir(*releaseFile)
+
+	// functions sharing CodeUri, binary and architecture are only built once
+	built := map[string]string{}
+
+	functions := release.Template.GetAllServerlessFunctionResources()
+	names := []string{}
+	for name, fn := range functions {
+		// container images are built by pushImages
+		if !template.IsImageFunction(fn) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fn := functions[name]
+
+		binary, err := binaryName(fn.Runtime, fn.Handler)
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		codeDir := templateDir
+		if fn.CodeUri != nil && fn.CodeUri.String != nil {
+			codeDir = filepath.Join(templateDir, *fn.CodeUri.String)
+		}
+
+		goarch := goArch(template.FunctionArchitecture(fn))
+
+		key := fmt.Sprintf("%v:%v:%v", codeDir, binary, goarch)
+		binaryPath, ok := built[key]
+		if !ok {
+			binaryPath = filepath.Join(tmpDir, fmt.Sprintf("%v-%v", len(built), binary))
+			if err := goBuild(codeDir, binaryPath, goarch); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+			built[key] = binaryPath
+		}
+
+		if err := packageZip(releaseFile, name, map[string]string{binary: binaryPath}); err != nil {
+			return err
+		}
+	}
+
+	// layers zip the ContentUri directory
+	layers := release.Template.GetAllServerlessLayerVersionResources()
+	names = []string{}
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		contentURI := layers[name].ContentUri
+		if contentURI == nil || contentURI.String == nil {
+			return fmt.Errorf("%v: ContentUri must be a directory", name)
+		}
+
+		files, err := dirFiles(filepath.Join(templateDir, *contentURI.String))
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		if err := packageZip(releaseFile, name, files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// packageZip writes <releaseFile>.<name>.zip and prints its SHA256
+func packageZip(releaseFile *string, name string, files map[string]string) error {
+	zipFile := extractedFilePath(*releaseFile, fmt.Sprintf("%v.zip", name))
+	if err := writeZip(zipFile, files); err != nil {
+		return err
+	}
+
+	sha, err := to.SHA256File(zipFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%v %v\n", zipFile, sha)
 
 	return nil
 }
 
+// dirFiles maps the slash separated path of every file in dir to its path
+func dirFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = path
+		return nil
+	})
+
+	return files, err
+}
+
+// binaryName returns the name of the executable inside the zip
+func binaryName(runtime string, handler string) (string, error) {
+	switch runtime {
+	case "go1.x":
+		if handler == "" {
+			return "", fmt.Errorf("Handler required for go1.x")
+		}
+		return handler, nil
+	case "provided", "provided.al2", "provided.al2023":
+		return "bootstrap", nil
+	}
+
+	return "", fmt.Errorf("Runtime %q cannot be packaged natively, use fenrir package --docker", runtime)
+}
+
+// goArch is the GOARCH of a Lambda architecture
+func goArch(architecture string) string {
+	if architecture == "arm64" {
+		return "arm64"
+	}
+
+	return "amd64"
+}
+
+// goBuild cross-compiles the package in dir for lambda on goarch, trimming paths and
+// build IDs so the output is the same across machines
+func goBuild(dir string, output string, goarch string) error {
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "build", "-trimpath", "-ldflags=-s -w -buildid=", "-o", output, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+goarch, "CGO_ENABLED=0")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Println("go build", dir, goarch)
+
+	return cmd.Run()
+}
+
+// zipModTime is fixed so zips of the same files have the same SHA256
+var zipModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// writeZip writes a zip at path containing files, a map of name to source path.
+// Files are sorted by name and only keep whether they are executable
+func writeZip(path string, files map[string]string) error {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range names {
+		info, err := os.Stat(files[name])
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(files[name])
+		if err != nil {
+			return err
+		}
+
+		header := &zip.FileHeader{
+			Name:   name,
+			Method: zip.Deflate,
+		}
+		header.Modified = zipModTime
+		header.SetMode(0644)
+		if info.Mode()&0111 != 0 {
+			header.SetMode(0755)
+		}
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
 func execute(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	fmt.Println(name, args)
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Plan creates the releases ChangeSet and prints its changes without executing it
+func Plan(step_fn *string, releaseFile *string) error {
+	region, accountID := to.RegionAccount()
+
+	if is.EmptyStr(region) || is.EmptyStr(accountID) {
+		return fmt.Errorf("AWS_REGION and AWS_ACCOUNT_ID envars, maybe use assume-role")
+	}
+
+	release, err := releaseFromFile(releaseFile, region, accountID)
+	if err != nil {
+		return err
+	}
+
+	release.Plan = true
+
+	deployerARN := to.StepArn(region, accountID, step_fn)
+
+	return deploy(&aws.ClientsStr{}, release, deployerARN, releaseFile)
+}
+
+func printChanges(out io.Writer, changes []*deployer.ChangeSetChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No Changes")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tLOGICAL ID\tTYPE\tREPLACEMENT\tSCOPE")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", c.Action, c.LogicalResourceID, c.ResourceType, c.Replacement, strings.Join(c.Scope, ","))
+	}
+	w.Flush()
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Rollback redeploys the template and artifacts of a previously deployed release
+func Rollback(step_fn *string, projectName, configName, releaseID *string) error {
+	release, err := configRelease(projectName, configName)
+	if err != nil {
+		return err
+	}
+
+	awsc := &aws.ClientsStr{}
+
+	if err := rollbackRelease(awsc, release, releaseID); err != nil {
+		return err
+	}
+
+	deployerARN := to.StepArn(release.AwsRegion, release.AwsAccountID, step_fn)
+
+	return start(awsc, release, deployerARN)
+}
+
+// rollbackRelease sets the template and artifacts of releaseID on release
+// If releaseID is empty the release deployed before the current one is used
+func rollbackRelease(awsc aws.Clients, release *deployer.Release, releaseID *string) error {
+	s3c := awsc.S3(nil, nil, nil)
+
+	deployed, err := release.DeployedReleases(s3c)
+	if err != nil {
+		return err
+	}
+
+	if is.EmptyStr(releaseID) {
+		previous, err := previousRelease(deployed)
+		if err != nil {
+			return err
+		}
+		releaseID = &previous
+	}
+
+	found := false
+	for _, d := range deployed {
+		if d.ReleaseID == *releaseID {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("Release %v was not deployed successfully", *releaseID)
+	}
+
+	previous := &deployer.Release{}
+	previous.AwsAccountID = release.AwsAccountID
+	previous.ProjectName = release.ProjectName
+	previous.ConfigName = release.ConfigName
+	previous.ReleaseID = releaseID
+
+	if err := s3.GetStruct(s3c, release.Bucket, previous.ReleasePath(), previous); err != nil {
+		return err
+	}
+
+	// Make sure the artifacts have not changed
+	if err := previous.ValidateSHAs(s3c); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rolling back to %v as %v\n", *releaseID, *release.ReleaseID)
+
+	// Rolling back to a rollback redeploys the same release
+	release.RollbackOf = *releaseID
+	if previous.RollbackOf != "" {
+		release.RollbackOf = previous.RollbackOf
+	}
+
+	release.Template = previous.Template
+	release.S3URISHA256s = previous.S3URISHA256s
+	release.ImageURIDigests = previous.ImageURIDigests
+	release.Parameters = previous.Parameters
+	release.AllowReplacements = previous.AllowReplacements
+	release.ChangeSetTags = previous.ChangeSetTags
+	release.Env = previous.Env
+
+	return nil
+}
+
+// previousRelease is the release deployed before the current one. A rollback is treated as the release
+// it redeployed, so rolling back again goes further back instead of to the release that was rolled back from
+func previousRelease(deployed []*deployer.DeployedRelease) (string, error) {
+	// origin is the index of the release a rollback at i redeployed
+	origin := func(i int) (int, error) {
+		for deployed[i].RollbackOf != "" {
+			j := i - 1
+			for j >= 0 && deployed[j].ReleaseID != deployed[i].RollbackOf {
+				j--
+			}
+
+			if j < 0 {
+				return 0, fmt.Errorf("Cannot find %v rolled back to by %v", deployed[i].RollbackOf, deployed[i].ReleaseID)
+			}
+			i = j
+		}
+		return i, nil
+	}
+
+	if len(deployed) == 0 {
+		return "", fmt.Errorf("No previous deployed release to rollback to")
+	}
+
+	current, err := origin(len(deployed) - 1)
+	if err != nil {
+		return "", err
+	}
+
+	if current == 0 {
+		return "", fmt.Errorf("No previous deployed release to rollback to")
+	}
+
+	previous, err := origin(current - 1)
+	if err != nil {
+		return "", err
+	}
+
+	return deployed[previous].ReleaseID, nil
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer"
+	"github.com/coinbase/fenrir/deployer/template"
+	"github.com/coinbase/step/utils/is"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Validate checks the release file against the deployer rules without deploying
+func Validate(releaseFile *string) error {
+	region, accountID := to.RegionAccount()
+
+	if is.EmptyStr(region) || is.EmptyStr(accountID) {
+		return fmt.Errorf("AWS_REGION and AWS_ACCOUNT_ID envars, maybe use assume-role")
+	}
+
+	release, err := releaseFromFile(releaseFile, region, accountID)
+	if err != nil {
+		return fmt.Errorf("%v: %v", *releaseFile, err)
+	}
+
+	errs := validate(&aws.ClientsStr{}, release, releaseFile)
+	for _, warning := range release.Warnings {
+		fmt.Printf("%v: WARNING %v\n", *releaseFile, warning)
+	}
+
+	for _, err := range errs {
+		fmt.Printf("%v: %v\n", *releaseFile, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%v: %v errors found", *releaseFile, len(errs))
+	}
+
+	fmt.Printf("%v: valid\n", *releaseFile)
+	return nil
+}
+
+func validate(awsc aws.Clients, release *deployer.Release, releaseFile *string) []error {
+	errs := []error{}
+
+	release.S3URISHA256s = map[string]string{}
+
+	// pin container images by the digest of their tag, they must have been pushed by fenrir package
+	if err := resolveImages(awsc.ECR(nil, nil, nil), release); err != nil {
+		errs = append(errs, err)
+	}
+
+	s3c := &localArtifactsS3{S3API: awsc.S3(nil, nil, nil), files: map[string]string{}}
+
+	// replace the artifact URIs with the s3 path they would be uploaded to
+	// use the local file SHA, files are not uploaded
+	for _, art := range releaseArtifacts(release, *releaseFile) {
+		s3URI := s3FileURI(release, art.file)
+
+		fileSHA, err := to.SHA256File(art.localPath)
+		if os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("%v not found, run fenrir package", art.localPath))
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+
+		art.setURI(s3URI)
+		release.S3URISHA256s[s3URI] = fileSHA
+		s3c.files[strings.TrimPrefix(s3URI, "s3://")] = art.localPath
+	}
+
+	settings, err := deployer.LoadSettings(awsc.S3(nil, nil, nil), release.Bucket)
+	if err != nil {
+		return append(errs, err)
+	}
+
+	err = release.ValidateTemplate(
+		settings,
+		awsc.EC2(nil, nil, nil),
+		awsc.IAM(nil, nil, nil),
+		s3c,
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.CF(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	)
+
+	switch err := err.(type) {
+	case nil:
+	case template.ValidationErrors:
+		errs = append(errs, err...)
+	default:
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// localArtifactsS3 reads the artifacts that would be uploaded from their local files
+// so definitions the deployer fetches from S3 can be validated
+type localArtifactsS3 struct {
+	aws.S3API
+	files map[string]string // bucket/key to local path
+}
+
+func (l *localArtifactsS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	localPath, ok := l.files[fmt.Sprintf("%v/%v", *input.Bucket, *input.Key)]
+	if !ok {
+		return l.S3API.GetObject(input)
+	}
+
+	raw, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(raw))}, nil
+}
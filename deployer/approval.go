@@ -0,0 +1,124 @@
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// Approval is written next to the release by `fenrir approve` or `fenrir reject`,
+// User is the callers STS identity ARN and the S3 LastModified is when it was approved
+type Approval struct {
+	Approved bool   `json:"approved"`
+	User     string `json:"user,omitempty"`
+}
+
+// ApprovalPath is where the Approval for the release is written
+func (release *Release) ApprovalPath() *string {
+	s := fmt.Sprintf("%v/approval", *release.ReleaseDir())
+	return &s
+}
+
+// SetApproval sets whether the release needs an approval from the Fenrir Settings
+func (release *Release) SetApproval(s3c aws.S3API) error {
+	settings, err := LoadSettings(s3c, release.Bucket)
+	if err != nil {
+		return err
+	}
+
+	// Always overwrite so the release cannot skip approval
+	release.ApprovalRequired = settings.RequiresApproval(*release.ProjectName, *release.ConfigName)
+	release.ApprovalTimeout = settings.ApprovalTimeout
+	release.ApprovalRequestedAt = nil
+	release.ApprovalStatus = ""
+	release.ApprovalUser = ""
+	release.ApprovalIgnoredReason = ""
+
+	return nil
+}
+
+// CheckApproval updates ApprovalStatus to PENDING, APPROVED, REJECTED or TIMED_OUT
+func (release *Release) CheckApproval(s3c aws.S3API) error {
+	if release.ApprovalTimeout == nil {
+		return fmt.Errorf("ApprovalTimeout is nil")
+	}
+
+	if release.ApprovalRequestedAt == nil {
+		release.ApprovalRequestedAt = to.Timep(time.Now())
+	}
+
+	approval, approvedAt, err := release.getApproval(s3c)
+
+	switch err.(type) {
+	case nil:
+		// An invalid approval is ignored so the release keeps waiting, then times out and cleans up
+		if err := release.validateApproval(approval, approvedAt); err != nil {
+			release.ApprovalIgnoredReason = err.Error()
+			break
+		}
+
+		release.ApprovalIgnoredReason = ""
+		release.ApprovalUser = approval.User
+		if approval.Approved {
+			release.ApprovalStatus = "APPROVED"
+		} else {
+			release.ApprovalStatus = "REJECTED"
+		}
+		return nil
+	case *s3.NotFoundError:
+		// Not yet approved
+	default:
+		return err
+	}
+
+	timeout := release.ApprovalRequestedAt.Add(time.Duration(*release.ApprovalTimeout) * time.Second)
+	if time.Now().After(timeout) {
+		release.ApprovalStatus = "TIMED_OUT"
+		return nil
+	}
+
+	release.ApprovalStatus = "PENDING"
+	return nil
+}
+
+// getApproval fetches the Approval and when S3 last modified it, which the approver cannot set
+func (release *Release) getApproval(s3c aws.S3API) (*Approval, *time.Time, error) {
+	out, body, err := s3.GetObject(s3c, release.Bucket, release.ApprovalPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var approval Approval
+	if err := json.Unmarshal(*body, &approval); err != nil {
+		return nil, nil, err
+	}
+
+	return &approval, out.LastModified, nil
+}
+
+// validateApproval errors for an approval written before it was requested,
+// or approved by the identity that made the release
+func (release *Release) validateApproval(approval *Approval, approvedAt *time.Time) error {
+	if approvedAt == nil || approvedAt.Before(*release.ApprovalRequestedAt) {
+		return fmt.Errorf("approval written before it was requested at %v", release.ApprovalRequestedAt.Format(time.RFC3339))
+	}
+
+	if !approval.Approved {
+		// Anyone can reject
+		return nil
+	}
+
+	if approval.User == "" {
+		return fmt.Errorf("approval has no user")
+	}
+
+	if approval.User == release.User {
+		return fmt.Errorf("approval by %q who made the release", approval.User)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// maxDeployedReleases is how many deployed releases are recorded per config
+var maxDeployedReleases = 50
+
+// DeployedRelease records a release that was successfully deployed
+type DeployedRelease struct {
+	ReleaseID  string     `json:"release_id"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	DeployedAt *time.Time `json:"deployed_at,omitempty"`
+
+	// RollbackOf is the release a rollback redeployed
+	RollbackOf string `json:"rollback_of,omitempty"`
+}
+
+// DeployedReleasesPath is where the deployed releases for the config are recorded
+func (release *Release) DeployedReleasesPath() *string {
+	s := fmt.Sprintf("%v/deployed_releases", *release.RootDir())
+	return &s
+}
+
+// Deployed returns true if the release successfully updated the stack
+func (release *Release) Deployed() bool {
+	if release.Plan {
+		return false
+	}
+
+	return release.StackStatus == "CREATE_COMPLETE" || release.StackStatus == "UPDATE_COMPLETE"
+}
+
+// DeployedReleases returns the deployed releases for the config oldest first
+func (release *Release) DeployedReleases(s3c aws.S3API) ([]*DeployedRelease, error) {
+	deployed := []*DeployedRelease{}
+
+	err := s3.GetStruct(s3c, release.Bucket, release.DeployedReleasesPath(), &deployed)
+	if err != nil {
+		switch err.(type) {
+		case *s3.NotFoundError:
+			return []*DeployedRelease{}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return deployed, nil
+}
+
+// RecordDeployed appends the release to the deployed releases
+// This must be called while holding the lock
+func (release *Release) RecordDeployed(s3c aws.S3API) error {
+	deployed, err := release.DeployedReleases(s3c)
+	if err != nil {
+		return err
+	}
+
+	// ReleaseLock can be retried so don't record twice
+	for _, d := range deployed {
+		if d.ReleaseID == *release.ReleaseID {
+			return nil
+		}
+	}
+
+	deployed = append(deployed, &DeployedRelease{
+		ReleaseID:  *release.ReleaseID,
+		CreatedAt:  release.CreatedAt,
+		DeployedAt: to.Timep(time.Now()),
+		RollbackOf: release.RollbackOf,
+	})
+
+	if len(deployed) > maxDeployedReleases {
+		deployed = deployed[len(deployed)-maxDeployedReleases:]
+	}
+
+	return s3.PutStruct(s3c, release.Bucket, release.DeployedReleasesPath(), deployed)
+}
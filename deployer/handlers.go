@@ -3,8 +3,10 @@ package deployer
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer/template"
 	"github.com/coinbase/step/aws/dynamodb"
 	"github.com/coinbase/step/bifrost"
 	"github.com/coinbase/step/errors"
@@ -32,11 +34,21 @@ func Validate(awsc aws.Clients) DeployHandler {
 		// Fill in all the blank Attributes
 		release.SetDefaults(region, account)
 
+		if release.Destroy {
+			return validateDestroy(awsc, release)
+		}
+
 		if err := release.Validate(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
 			return nil, &errors.BadReleaseError{Cause: err.Error()}
 		}
 
+		settings, err := LoadSettings(awsc.S3(release.AwsRegion, nil, nil), release.Bucket)
+		if err != nil {
+			return nil, &errors.BadReleaseError{Cause: err.Error()}
+		}
+
 		if err := release.ValidateTemplate(
+			settings,
 			awsc.EC2(release.AwsRegion, release.AwsAccountID, assumedRole),
 			awsc.IAM(release.AwsRegion, release.AwsAccountID, assumedRole),
 			awsc.S3(release.AwsRegion, release.AwsAccountID, assumedRole),
@@ -47,7 +59,17 @@ func Validate(awsc aws.Clients) DeployHandler {
 			awsc.KMS(release.AwsRegion, release.AwsAccountID, assumedRole),
 			awsc.Lambda(release.AwsRegion, release.AwsAccountID, assumedRole),
 			awsc.CWL(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.EB(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.CF(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.SSM(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.SM(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.SFN(release.AwsRegion, release.AwsAccountID, assumedRole),
+			awsc.ECR(release.AwsRegion, release.AwsAccountID, assumedRole),
 		); err != nil {
+			return nil, &errors.BadReleaseError{Cause: validationCause(err)}
+		}
+
+		if err := release.SetApproval(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
 			return nil, &errors.BadReleaseError{Cause: err.Error()}
 		}
 
@@ -55,6 +77,28 @@ func Validate(awsc aws.Clients) DeployHandler {
 	}
 }
 
+// validationCause is the BadReleaseError Cause for a ValidateTemplate error,
+// ValidationErrors are JSON so the client can decode them
+func validationCause(err error) string {
+	if errs, ok := err.(template.ValidationErrors); ok {
+		return to.CompactJSONStr(errs)
+	}
+
+	return err.Error()
+}
+
+func validateDestroy(awsc aws.Clients, release *Release) (*Release, error) {
+	if err := release.ValidateDestroy(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
+		return nil, &errors.BadReleaseError{Cause: err.Error()}
+	}
+
+	if err := release.SetApproval(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
+		return nil, &errors.BadReleaseError{Cause: err.Error()}
+	}
+
+	return release, nil
+}
+
 // Lock secures a lock for the release
 func Lock(awsc aws.Clients) interface{} {
 	return func(ctx context.Context, release *Release) (*Release, error) {
@@ -94,6 +138,44 @@ func UpdateChangeSet(awsc aws.Clients) DeployHandler {
 	}
 }
 
+// DeleteStack deletes the stack when destroying
+func DeleteStack(awsc aws.Clients) DeployHandler {
+	return func(_ context.Context, release *Release) (*Release, error) {
+		if err := release.DeleteStack(
+			awsc.CF(release.AwsRegion, release.AwsAccountID, assumedRole),
+		); err != nil {
+			return nil, &errors.BadReleaseError{Cause: err.Error()}
+		}
+
+		return release, nil
+	}
+}
+
+// DeleteChangeSet deletes the changeset when planning or it is blocked
+func DeleteChangeSet(awsc aws.Clients) DeployHandler {
+	return func(_ context.Context, release *Release) (*Release, error) {
+		if err := release.DeleteChangeSet(
+			awsc.CF(release.AwsRegion, release.AwsAccountID, assumedRole),
+		); err != nil {
+			return nil, err
+		}
+
+		release.Planned = release.Plan
+		return release, nil
+	}
+}
+
+// CheckApproval checks for an approval or rejection of the changeset
+func CheckApproval(awsc aws.Clients) DeployHandler {
+	return func(_ context.Context, release *Release) (*Release, error) {
+		if err := release.CheckApproval(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
+			return nil, err
+		}
+
+		return release, nil
+	}
+}
+
 // Execute executes the changeset
 func Execute(awsc aws.Clients) DeployHandler {
 	return func(_ context.Context, release *Release) (*Release, error) {
@@ -110,6 +192,14 @@ func Execute(awsc aws.Clients) DeployHandler {
 // ReleaseLock releases lock with sucess
 func ReleaseLock(awsc aws.Clients) DeployHandler {
 	return func(ctx context.Context, release *Release) (*Release, error) {
+		// Record while still holding the lock
+		if release.Deployed() {
+			if err := release.RecordDeployed(awsc.S3(release.AwsRegion, nil, nil)); err != nil {
+				// ignore errors, the deploy succeeded
+				fmt.Printf("Warning(RecordDeployed) error ignored: %v\n", err.Error())
+			}
+		}
+
 		err := release.UnlockRoot(
 			awsc.S3(release.AwsRegion, nil, nil),
 			dynamodb.NewDynamoDBLocker(awsc.DynamoDBClient(nil, nil, nil)),
@@ -120,6 +210,12 @@ func ReleaseLock(awsc aws.Clients) DeployHandler {
 		}
 
 		release.Success = to.Boolp(true)
+
+		// CleanUp overwrites the result if the release failed
+		if err := release.WriteResult(awsc.S3(release.AwsRegion, nil, nil), release.Deployed() || release.Planned || release.Destroyed()); err != nil {
+			fmt.Printf("Warning(WriteResult) error ignored: %v\n", err.Error())
+		}
+
 		return release, nil
 	}
 }
@@ -145,6 +241,15 @@ func CleanUp(awsc aws.Clients) DeployHandler {
 
 		release.Success = to.Boolp(false)
 
+		// A changeset that was not approved is never executed, so delete it
+		if !release.Destroy && (release.ApprovalStatus == "REJECTED" || release.ApprovalStatus == "TIMED_OUT") {
+			if err := release.DeleteChangeSet(
+				awsc.CF(release.AwsRegion, release.AwsAccountID, assumedRole),
+			); err != nil {
+				return nil, &errors.CleanUpError{Cause: err.Error()}
+			}
+		}
+
 		if err := release.CleanUp(
 			awsc.S3(release.AwsRegion, nil, nil),
 			awsc.CF(release.AwsRegion, release.AwsAccountID, assumedRole),
@@ -154,21 +259,41 @@ func CleanUp(awsc aws.Clients) DeployHandler {
 
 		// Add Error if if can be found
 		if release.Error == nil {
-			cause := ""
+			causes := []string{}
 			if release.ChangeSetStatusReason != "" {
-				cause += fmt.Sprintf("changeset: %s", release.ChangeSetStatusReason)
+				causes = append(causes, fmt.Sprintf("changeset: %s", release.ChangeSetStatusReason))
 			}
 
 			if release.StackStatusReason != "" && release.StackStatusReason != "User Initiated" {
-				cause += fmt.Sprintf(":: stack: %s", release.StackStatusReason)
+				causes = append(causes, fmt.Sprintf("stack: %s", release.StackStatusReason))
+			}
+
+			if release.ChangeSetBlocked {
+				causes = append(causes, fmt.Sprintf("changeset blocked: %s", release.ChangeSetBlockedReason))
 			}
 
+			switch release.ApprovalStatus {
+			case "REJECTED":
+				causes = append(causes, fmt.Sprintf("approval: rejected by %q", release.ApprovalUser))
+			case "TIMED_OUT":
+				if release.ApprovalIgnoredReason != "" {
+					causes = append(causes, fmt.Sprintf("approval: timed out, ignored %v", release.ApprovalIgnoredReason))
+				} else {
+					causes = append(causes, "approval: timed out")
+				}
+			}
+
+			cause := strings.Join(causes, " :: ")
 			release.Error = &bifrost.ReleaseError{
 				Error: to.Strp("Failed"),
 				Cause: &cause,
 			}
 		}
 
+		if err := release.WriteResult(awsc.S3(release.AwsRegion, nil, nil), false); err != nil {
+			fmt.Printf("Warning(WriteResult) error ignored: %v\n", err.Error())
+		}
+
 		return release, nil
 	}
 }
@@ -7,10 +7,10 @@ import (
 	"testing"
 	"time"
 
-	goformation "github.com/awslabs/goformation/v4"
 	"github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/intrinsics"
 	"github.com/coinbase/fenrir/aws/mocks"
+	"github.com/coinbase/fenrir/deployer/template"
 	"github.com/coinbase/step/bifrost"
 	"github.com/coinbase/step/machine"
 	"github.com/coinbase/step/utils/to"
@@ -22,11 +22,12 @@ import (
 ////////
 
 func parseTemplate(rawSAM string) (*cloudformation.Template, error) {
-	// process Globals
-	// Dont process intrinsics
-	return goformation.ParseYAMLWithOptions([]byte(rawSAM), &intrinsics.ProcessorOptions{
-		IntrinsicHandlerOverrides: cloudformation.EncoderIntrinsics,
-	})
+	rawJSON, err := intrinsics.ProcessYAML([]byte(rawSAM), &intrinsics.ProcessorOptions{NoProcess: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return template.ParseTemplate(rawJSON)
 }
 
 func MockRelease(fileName string) (*Release, error) {
@@ -72,6 +73,7 @@ func MockAwsClients(release *Release) *mocks.MockClients {
 		awsc.S3Client.AddGetObject(releasePath, string(raw), nil)
 
 		awsc.S3Client.AddGetObject("path.zip", "", nil)
+		awsc.S3Client.AddGetObject(*SettingsPath, `{"event_sources": {"project": ["aws.ec2"]}}`, nil)
 
 		// Good resources
 		awsc.EC2Client.AddSecurityGroup("sg_correct", *release.ProjectName, *release.ConfigName, "hello", nil)
@@ -114,6 +116,7 @@ func assertSuccessfulExecution(t *testing.T, release *Release) map[string]interf
 	assert.Equal(t, []string{
 		"Validate",
 		"Lock",
+		"Destroy?",
 		"CreateChangeSet",
 		"WaitForChangeSet",
 		"UpdateChangeSet",
@@ -141,6 +144,7 @@ func assertFailedExecution(t *testing.T, release *Release) (map[string]interface
 	assert.NotEqual(t, []string{
 		"Validate",
 		"Lock",
+		"Destroy?",
 		"CreateChangeSet",
 		"WaitForChangeSet",
 		"UpdateChangeSet",
@@ -157,5 +161,14 @@ func assertFailedExecution(t *testing.T, release *Release) (map[string]interface
 	output := exec.LastOutput["error"]
 	errorOutput := output.(map[string]interface{})
 
-	return exec.LastOutput, exec.Path(), fmt.Sprintf("%v", errorOutput["Cause"])
+	cause := fmt.Sprintf("%v", errorOutput["Cause"])
+	if verrs := template.DecodeValidationErrors(cause); verrs != nil {
+		errs := template.ValidationErrors{}
+		for _, verr := range verrs {
+			errs = append(errs, verr)
+		}
+		cause = errs.Error()
+	}
+
+	return exec.LastOutput, exec.Path(), cause
 }
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/coinbase/fenrir/aws/mocks"
+	"github.com/coinbase/step/aws/s3"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -88,7 +90,7 @@ var badFiles = []struct {
 	},
 	{
 		File:     "../examples/tests/not/bad_lambda_permission_principal.yml",
-		ErrorStr: `BadReleaseError: AWS::Lambda::Permission#basicHelloPermission: badprincipal.amazonaws.com is not a currently supported value for Lambda::Permission.Principal`,
+		ErrorStr: `AWS::Lambda::Permission#basicHelloPermission: badprincipal.amazonaws.com is not a currently supported value for Lambda::Permission.Principal`,
 	},
 	{
 		File:     "../examples/tests/not/bad_subnet.yml",
@@ -126,6 +128,26 @@ var badFiles = []struct {
 		File:     "../examples/tests/not/unsupported_function_event.yml",
 		ErrorStr: `Unsupported Event type "IoTRule"`,
 	},
+	{
+		File:     "../examples/tests/not/multiple_errors.yml",
+		ErrorStr: `AWS::Serverless::Function#alpha: VpcConfig Incorrect ServiceName for SecurityGroup(.|\n)*AWS::Serverless::Function#hello: Incorrect ProjectName for Role(.|\n)*AWS::Serverless::Function#hello: Event "BadEvent" Unsupported Event type "IoTRule"`,
+	},
+	{
+		File:     "../examples/tests/not/bad_parameters.yml",
+		ErrorStr: `Parameter#Secret: NoEcho parameters are not supported`,
+	},
+	{
+		File:     "../examples/tests/not/bad_conditions.yml",
+		ErrorStr: `Condition#HasQueue: Fn::GetAtt cannot be resolved statically \(rule: Unresolvable`,
+	},
+	{
+		File:     "../examples/tests/not/bad_function_policies_targets.yml",
+		ErrorStr: `Policies.S3ReadPolicy.BucketName untagged-bucket Unkown Bucket`,
+	},
+	{
+		File:     "../examples/tests/not/bad_function_policy_document.yml",
+		ErrorStr: `Action "\*" not allowed \(rule: NotLeastPrivilege, path: Policies\[0\].Statement\[0\]\)`,
+	},
 	{
 		File:     "../examples/tests/not/bad_codeuri_sha.yml",
 		ErrorStr: `CodeUri s3://no_sha/path.zip not included in the SHA256s`,
@@ -158,6 +180,46 @@ var badFiles = []struct {
 		File:     "../examples/tests/not/bad_target_group_instance.yml",
 		ErrorStr: `TargetGroup.Targets must be empty for TargetType instance`,
 	},
+	{
+		File:     "../examples/tests/not/bad_sns_subscription.yml",
+		ErrorStr: `Endpoint https://example.com/hook is not an allowed SNS endpoint`,
+	},
+	{
+		File:     "../examples/tests/not/bad_s3_bucket.yml",
+		ErrorStr: `AccessControl PublicRead not supported, only Private`,
+	},
+	{
+		File:     "../examples/tests/not/bad_image.yml",
+		ErrorStr: `ImageUri 000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:development must be an ECR image pinned by @sha256: digest`,
+	},
+	{
+		File:     "../examples/tests/not/bad_s3_bucket_logging.yml",
+		ErrorStr: `LoggingConfiguration.DestinationBucketName other-logs`,
+	},
+	{
+		File:     "../examples/tests/not/bad_log_group.yml",
+		ErrorStr: `LogGroupName "/aws/lambda/other-function" must be /aws/lambda/<FunctionName> of a function in the template`,
+	},
+	{
+		File:     "../examples/tests/not/bad_runtime.yml",
+		ErrorStr: `Runtime "go1.x" support ended 2024-01-08`,
+	},
+	{
+		File:     "../examples/tests/not/bad_schedule.yml",
+		ErrorStr: `Schedule Event "everySecond" Schedule rate\(30 seconds\) unit must be minutes, hours or days(.|\n)*CloudWatchEvent Event "everything" Pattern must match a list of source values`,
+	},
+	{
+		File:     "../examples/tests/not/bad_events_rule.yml",
+		ErrorStr: `EventBusName arn:aws:events:us-east-1:111111111111:event-bus/shared(.|\n)*Targets\[0\].Arn must be a function, queue, topic or state machine in the template`,
+	},
+	{
+		File:     "../examples/tests/not/bad_state_machine.yml",
+		ErrorStr: `Definition.States.Hello.Resource Lambda other_lambda_arn ProjectName \(project != project\) OR ConfigName \(otherconfig != development\) tags incorrect`,
+	},
+	{
+		File:     "../examples/tests/not/bad_http_api.yml",
+		ErrorStr: `HttpApi Event "CatchAll" ApiId must be explicitly defined(.|\n)*AWS::Serverless::HttpApi#helloHttpApi: Names are overwritten`,
+	},
 }
 
 func Test_Unsuccessful_Execution(t *testing.T) {
@@ -205,6 +267,7 @@ func Test_Unsuccessful_ChangeSetOnNewStack(t *testing.T) {
 	assert.Equal(t, []string{
 		"Validate",
 		"Lock",
+		"Destroy?",
 		"CreateChangeSet",
 		"WaitForChangeSet",
 		"UpdateChangeSet",
@@ -241,6 +304,7 @@ func Test_Unsuccessful_ExecuteChangeSet(t *testing.T) {
 	assert.Equal(t, []string{
 		"Validate",
 		"Lock",
+		"Destroy?",
 		"CreateChangeSet",
 		"WaitForChangeSet",
 		"UpdateChangeSet",
@@ -255,3 +319,528 @@ func Test_Unsuccessful_ExecuteChangeSet(t *testing.T) {
 		"FailureClean",
 	}, exec.Path())
 }
+
+func Test_Successful_Plan(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.Plan = true
+
+	awsc := MockAwsClients(release)
+
+	// Empty stack created by the CREATE changeset
+	awsc.CFClient.StackResp = &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{
+		&cloudformation.Stack{
+			StackStatus:  to.Strp("REVIEW_IN_PROGRESS"),
+			CreationTime: to.Timep(time.Now()),
+		},
+	}}
+
+	awsc.CFClient.ChangeSet = &cloudformation.DescribeChangeSetOutput{
+		Status:          to.Strp("CREATE_COMPLETE"),
+		ExecutionStatus: to.Strp("AVAILABLE"),
+		Changes: []*cloudformation.Change{
+			&cloudformation.Change{
+				Type: to.Strp("Resource"),
+				ResourceChange: &cloudformation.ResourceChange{
+					Action:            to.Strp("Add"),
+					LogicalResourceId: to.Strp("hello"),
+					ResourceType:      to.Strp("AWS::Lambda::Function"),
+				},
+			},
+		},
+	}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	exec, err := stateMachine.Execute(release)
+	assert.NoError(t, err)
+
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, true, exec.Output["planned"])
+	assert.True(t, awsc.CFClient.DeleteChangeSetCalled)
+	assert.True(t, awsc.CFClient.DeleteStackCalled)
+
+	changes := exec.Output["changes"].([]interface{})
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, "hello", changes[0].(map[string]interface{})["logical_resource_id"])
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"Destroy?",
+		"CreateChangeSet",
+		"WaitForChangeSet",
+		"UpdateChangeSet",
+		"Execute?",
+		"DeleteChangeSet",
+		"ReleaseLock",
+		"Success?",
+		"Success",
+	}, exec.Path())
+}
+
+func Test_Successful_Plan_NoChanges(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.Plan = true
+
+	awsc := MockAwsClients(release)
+
+	// An up to date stack FAILS the changeset
+	awsc.CFClient.ChangeSet = &cloudformation.DescribeChangeSetOutput{
+		Status:          to.Strp("FAILED"),
+		ExecutionStatus: to.Strp("UNAVAILABLE"),
+		StatusReason:    to.Strp("The submitted information didn't contain changes. Submit different information to create a change set."),
+	}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	exec, err := stateMachine.Execute(release)
+	assert.NoError(t, err)
+
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, true, exec.Output["planned"])
+	assert.Equal(t, true, exec.Output["change_set_empty"])
+	assert.Nil(t, exec.Output["changes"])
+	assert.True(t, awsc.CFClient.DeleteChangeSetCalled)
+	assert.False(t, awsc.CFClient.DeleteStackCalled)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"Destroy?",
+		"CreateChangeSet",
+		"WaitForChangeSet",
+		"UpdateChangeSet",
+		"Execute?",
+		"DeleteChangeSet",
+		"ReleaseLock",
+		"Success?",
+		"Success",
+	}, exec.Path())
+}
+
+func Test_Approval_Execution(t *testing.T) {
+	settings := `{"approvals": [{"project_name": "project", "config_name": "*"}]}`
+	approvalPath := "00000000/project/development/release-1/approval"
+
+	t.Run("approved", func(t *testing.T) {
+		release, err := MockRelease("../examples/tests/allowed/function.yml")
+		assert.NoError(t, err)
+
+		awsc := MockAwsClients(release)
+		awsc.S3Client.AddGetObject(*SettingsPath, settings, nil)
+		awsc.S3Client.AddGetObject(approvalPath, `{"approved": true, "user": "approver"}`, nil)
+		awsc.S3Client.GetObjectResp[approvalPath].Resp.LastModified = to.Timep(time.Now().Add(time.Hour))
+
+		stateMachine := createTestStateMachine(t, awsc)
+
+		exec, err := stateMachine.Execute(release)
+		assert.NoError(t, err)
+
+		assert.Equal(t, true, exec.Output["success"])
+		assert.Equal(t, "APPROVED", exec.Output["approval_status"])
+		assert.Equal(t, "approver", exec.Output["approval_user"])
+
+		assert.Equal(t, []string{
+			"Validate",
+			"Lock",
+			"Destroy?",
+			"CreateChangeSet",
+			"WaitForChangeSet",
+			"UpdateChangeSet",
+			"Execute?",
+			"CheckApproval",
+			"Approved?",
+			"Execute",
+			"WaitForComplete",
+			"UpdateStack",
+			"Complete?",
+			"ReleaseLock",
+			"Success?",
+			"Success",
+		}, exec.Path())
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		release, err := MockRelease("../examples/tests/allowed/function.yml")
+		assert.NoError(t, err)
+
+		awsc := MockAwsClients(release)
+		awsc.S3Client.AddGetObject(*SettingsPath, settings, nil)
+		awsc.S3Client.AddGetObject(approvalPath, `{"approved": false, "user": "rejecter"}`, nil)
+		awsc.S3Client.GetObjectResp[approvalPath].Resp.LastModified = to.Timep(time.Now().Add(time.Hour))
+
+		stateMachine := createTestStateMachine(t, awsc)
+
+		exec, err := stateMachine.Execute(release)
+		assert.Error(t, err)
+
+		assert.Equal(t, false, exec.LastOutput["success"])
+		assert.Regexp(t, `approval: rejected by \\"rejecter\\"`, exec.LastOutputJSON)
+		assert.True(t, awsc.CFClient.DeleteChangeSetCalled)
+
+		assert.Equal(t, []string{
+			"Validate",
+			"Lock",
+			"Destroy?",
+			"CreateChangeSet",
+			"WaitForChangeSet",
+			"UpdateChangeSet",
+			"Execute?",
+			"CheckApproval",
+			"Approved?",
+			"ReleaseLock",
+			"Success?",
+			"CleanUp",
+			"FailureClean",
+		}, exec.Path())
+	})
+
+	// Invalid approvals are ignored, so the release times out and its change set is deleted
+	invalid := []struct {
+		Name       string
+		Approval   string
+		ApprovedAt time.Time
+		ErrorStr   string
+	}{
+		{
+			Name:       "approved before requested",
+			Approval:   `{"approved": true, "user": "approver"}`,
+			ApprovedAt: time.Now().Add(-time.Hour),
+			ErrorStr:   `approval: timed out, ignored approval written before it was requested`,
+		},
+		{
+			Name:       "approved by releaser",
+			Approval:   `{"approved": true, "user": "arn:aws:sts::000000000000:assumed-role/role/releaser"}`,
+			ApprovedAt: time.Now().Add(time.Hour),
+			ErrorStr:   `approval: timed out, ignored approval by \\"arn:aws:sts::000000000000:assumed-role/role/releaser\\" who made the release`,
+		},
+	}
+
+	for _, test := range invalid {
+		t.Run(test.Name, func(t *testing.T) {
+			release, err := MockRelease("../examples/tests/allowed/function.yml")
+			assert.NoError(t, err)
+
+			release.User = "arn:aws:sts::000000000000:assumed-role/role/releaser"
+
+			awsc := MockAwsClients(release)
+			awsc.S3Client.AddGetObject(*SettingsPath, `{"approvals": [{"project_name": "project", "config_name": "*"}], "approval_timeout": 0}`, nil)
+			awsc.S3Client.AddGetObject(approvalPath, test.Approval, nil)
+			awsc.S3Client.GetObjectResp[approvalPath].Resp.LastModified = to.Timep(test.ApprovedAt)
+
+			stateMachine := createTestStateMachine(t, awsc)
+
+			exec, err := stateMachine.Execute(release)
+			assert.Error(t, err)
+
+			assert.Equal(t, false, exec.LastOutput["success"])
+			assert.Equal(t, "TIMED_OUT", exec.LastOutput["approval_status"])
+			assert.Regexp(t, test.ErrorStr, exec.LastOutputJSON)
+			assert.True(t, awsc.CFClient.DeleteChangeSetCalled)
+
+			assert.Equal(t, []string{
+				"Validate",
+				"Lock",
+				"Destroy?",
+				"CreateChangeSet",
+				"WaitForChangeSet",
+				"UpdateChangeSet",
+				"Execute?",
+				"CheckApproval",
+				"Approved?",
+				"ReleaseLock",
+				"Success?",
+				"CleanUp",
+				"FailureClean",
+			}, exec.Path())
+		})
+	}
+}
+
+func Test_Unsuccessful_BlockedChangeSet(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients(release)
+
+	awsc.CFClient.ChangeSet = &cloudformation.DescribeChangeSetOutput{
+		Status:          to.Strp("CREATE_COMPLETE"),
+		ExecutionStatus: to.Strp("AVAILABLE"),
+		Changes: []*cloudformation.Change{
+			&cloudformation.Change{
+				Type: to.Strp("Resource"),
+				ResourceChange: &cloudformation.ResourceChange{
+					Action:            to.Strp("Modify"),
+					LogicalResourceId: to.Strp("table"),
+					ResourceType:      to.Strp("AWS::DynamoDB::Table"),
+					Replacement:       to.Strp("True"),
+				},
+			},
+		},
+	}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	exec, err := stateMachine.Execute(release)
+	assert.Error(t, err)
+
+	assert.Equal(t, false, exec.LastOutput["success"])
+	assert.Regexp(t, `changeset blocked: table \(AWS::DynamoDB::Table\) would be replaced`, exec.LastOutputJSON)
+	assert.True(t, awsc.CFClient.DeleteChangeSetCalled)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"Destroy?",
+		"CreateChangeSet",
+		"WaitForChangeSet",
+		"UpdateChangeSet",
+		"Execute?",
+		"DeleteChangeSet",
+		"ReleaseLock",
+		"Success?",
+		"CleanUp",
+		"FailureClean",
+	}, exec.Path())
+}
+
+func Test_Successful_Execution_RecordsDeployed(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients(release)
+	stateMachine := createTestStateMachine(t, awsc)
+
+	_, err = stateMachine.Execute(release)
+	assert.NoError(t, err)
+
+	release.SetDefaults(to.Strp("region"), release.AwsAccountID)
+	deployed, err := release.DeployedReleases(awsc.S3(nil, nil, nil))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(deployed))
+	assert.Equal(t, "release-1", deployed[0].ReleaseID)
+
+	var result ReleaseResult
+	err = s3.GetStruct(awsc.S3(nil, nil, nil), release.Bucket, release.ResultPath(), &result)
+	assert.NoError(t, err)
+
+	assert.True(t, result.Success)
+	assert.Equal(t, "CREATE_COMPLETE", result.StackStatus)
+}
+
+func Test_Unsuccessful_Execution_WritesResult(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients(release)
+	awsc.CFClient.StackResp = &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{
+		&cloudformation.Stack{
+			StackStatus:       to.Strp("UPDATE_ROLLBACK_COMPLETE"),
+			StackStatusReason: to.Strp("broke"),
+			CreationTime:      to.Timep(time.Now()),
+		},
+	}}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	_, err = stateMachine.Execute(release)
+	assert.Error(t, err)
+
+	release.SetDefaults(to.Strp("region"), release.AwsAccountID)
+
+	var result ReleaseResult
+	err = s3.GetStruct(awsc.S3(nil, nil, nil), release.Bucket, release.ResultPath(), &result)
+	assert.NoError(t, err)
+
+	assert.False(t, result.Success)
+	assert.Equal(t, "UPDATE_ROLLBACK_COMPLETE", result.StackStatus)
+	assert.Regexp(t, "stack: broke", *result.Error.Cause)
+
+	deployed, err := release.DeployedReleases(awsc.S3(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(deployed))
+}
+
+func Test_Successful_Destroy(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.Destroy = true
+	release.Template = nil
+
+	awsc := MockAwsClients(release)
+
+	awsc.CFClient.StackResp = &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{
+		&cloudformation.Stack{
+			StackId:      to.Strp("stack-id"),
+			StackStatus:  to.Strp("DELETE_COMPLETE"),
+			CreationTime: to.Timep(time.Now()),
+			Tags: []*cloudformation.Tag{
+				&cloudformation.Tag{Key: to.Strp("ProjectName"), Value: to.Strp("project")},
+				&cloudformation.Tag{Key: to.Strp("ConfigName"), Value: to.Strp("development")},
+			},
+		},
+	}}
+
+	awsc.CFClient.StackEvents = []*cloudformation.StackEvent{
+		&cloudformation.StackEvent{
+			Timestamp:          &time.Time{},
+			ResourceStatus:     to.Strp("DELETE_SKIPPED"),
+			ResourceType:       to.Strp("AWS::DynamoDB::Table"),
+			LogicalResourceId:  to.Strp("table"),
+			PhysicalResourceId: to.Strp("fenrir-project-development-table"),
+		},
+	}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	exec, err := stateMachine.Execute(release)
+	assert.NoError(t, err)
+
+	assert.Equal(t, true, exec.Output["success"])
+	assert.Equal(t, "stack-id", exec.Output["stack_id"])
+	assert.Equal(t, []interface{}{"AWS::DynamoDB::Table table fenrir-project-development-table"}, exec.Output["retained_resources"])
+	assert.True(t, awsc.CFClient.DeleteStackCalled)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"Destroy?",
+		"DeleteStack",
+		"WaitForComplete",
+		"UpdateStack",
+		"Complete?",
+		"ReleaseLock",
+		"Success?",
+		"Success",
+	}, exec.Path())
+}
+
+func Test_Approval_Destroy(t *testing.T) {
+	settings := `{"approvals": [{"project_name": "project", "config_name": "*"}]}`
+	approvalPath := "00000000/project/development/release-1/approval"
+
+	destroyRelease := func(approval string) (*Release, *mocks.MockClients) {
+		release, err := MockRelease("../examples/tests/allowed/function.yml")
+		assert.NoError(t, err)
+
+		release.Destroy = true
+		release.Template = nil
+
+		awsc := MockAwsClients(release)
+		awsc.S3Client.AddGetObject(*SettingsPath, settings, nil)
+		awsc.S3Client.AddGetObject(approvalPath, approval, nil)
+		awsc.S3Client.GetObjectResp[approvalPath].Resp.LastModified = to.Timep(time.Now().Add(time.Hour))
+
+		awsc.CFClient.StackResp = &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{
+			&cloudformation.Stack{
+				StackId:      to.Strp("stack-id"),
+				StackStatus:  to.Strp("DELETE_COMPLETE"),
+				CreationTime: to.Timep(time.Now()),
+				Tags: []*cloudformation.Tag{
+					&cloudformation.Tag{Key: to.Strp("ProjectName"), Value: to.Strp("project")},
+					&cloudformation.Tag{Key: to.Strp("ConfigName"), Value: to.Strp("development")},
+				},
+			},
+		}}
+
+		return release, awsc
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		release, awsc := destroyRelease(`{"approved": true, "user": "approver"}`)
+
+		stateMachine := createTestStateMachine(t, awsc)
+
+		exec, err := stateMachine.Execute(release)
+		assert.NoError(t, err)
+
+		assert.Equal(t, true, exec.Output["success"])
+		assert.Equal(t, "APPROVED", exec.Output["approval_status"])
+		assert.True(t, awsc.CFClient.DeleteStackCalled)
+
+		assert.Equal(t, []string{
+			"Validate",
+			"Lock",
+			"Destroy?",
+			"CheckDestroyApproval",
+			"DestroyApproved?",
+			"DeleteStack",
+			"WaitForComplete",
+			"UpdateStack",
+			"Complete?",
+			"ReleaseLock",
+			"Success?",
+			"Success",
+		}, exec.Path())
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		release, awsc := destroyRelease(`{"approved": false, "user": "rejecter"}`)
+
+		stateMachine := createTestStateMachine(t, awsc)
+
+		exec, err := stateMachine.Execute(release)
+		assert.Error(t, err)
+
+		assert.Regexp(t, `approval: rejected by \\"rejecter\\"`, exec.LastOutputJSON)
+		assert.False(t, awsc.CFClient.DeleteStackCalled)
+		assert.False(t, awsc.CFClient.DeleteChangeSetCalled)
+
+		assert.Equal(t, []string{
+			"Validate",
+			"Lock",
+			"Destroy?",
+			"CheckDestroyApproval",
+			"DestroyApproved?",
+			"ReleaseLock",
+			"Success?",
+			"CleanUp",
+			"FailureClean",
+		}, exec.Path())
+	})
+}
+
+func Test_Unsuccessful_Destroy_Tags(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.Destroy = true
+	release.Template = nil
+
+	awsc := MockAwsClients(release)
+
+	awsc.CFClient.StackResp = &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{
+		&cloudformation.Stack{
+			StackStatus:  to.Strp("UPDATE_COMPLETE"),
+			CreationTime: to.Timep(time.Now()),
+			Tags: []*cloudformation.Tag{
+				&cloudformation.Tag{Key: to.Strp("ProjectName"), Value: to.Strp("other")},
+			},
+		},
+	}}
+
+	stateMachine := createTestStateMachine(t, awsc)
+
+	exec, err := stateMachine.Execute(release)
+	assert.Error(t, err)
+
+	assert.Regexp(t, "do not match release", exec.LastOutputJSON)
+	assert.False(t, awsc.CFClient.DeleteStackCalled)
+
+	assert.Equal(t, []string{
+		"Validate",
+		"Lock",
+		"Destroy?",
+		"DeleteStack",
+		"ReleaseLock",
+		"Success?",
+		"CleanUp",
+		"FailureClean",
+	}, exec.Path())
+}
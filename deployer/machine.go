@@ -30,7 +30,7 @@ func StateMachine() (*machine.StateMachine, error) {
         "Type": "TaskFn",
         "Comment": "Grab Lock",
         "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
-        "Next": "CreateChangeSet",
+        "Next": "Destroy?",
         "Catch": [
           {
             "Comment": "Something else is deploying",
@@ -46,6 +46,81 @@ func StateMachine() (*machine.StateMachine, error) {
           }
         ]
       },
+      "Destroy?": {
+        "Comment": "Delete the stack instead of deploying",
+        "Type": "Choice",
+        "Choices": [
+          {
+            "Comment": "Wait for a manual approval",
+            "And": [
+              { "Variable": "$.destroy", "BooleanEquals": true },
+              { "Variable": "$.approval_required", "BooleanEquals": true }
+            ],
+            "Next": "CheckDestroyApproval"
+          },
+          {
+            "Variable": "$.destroy",
+            "BooleanEquals": true,
+            "Next": "DeleteStack"
+          }
+        ],
+        "Default": "CreateChangeSet"
+      },
+      "CheckDestroyApproval": {
+        "Type": "TaskFn",
+        "Comment": "Check for an approval or rejection of the Destroy",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Next": "DestroyApproved?",
+        "Retry": [{
+          "Comment": "Retry a few times in case of another error",
+          "ErrorEquals": ["States.ALL"],
+          "MaxAttempts": 3,
+          "IntervalSeconds": 5
+        }],
+        "Catch": [{
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "ReleaseLock"
+        }]
+      },
+      "DestroyApproved?": {
+        "Comment": "Delete the stack when approved, fail when rejected or timed out",
+        "Type": "Choice",
+        "Choices": [
+          {
+            "Variable": "$.approval_status",
+            "StringEquals": "APPROVED",
+            "Next": "DeleteStack"
+          },
+          {
+            "OR": [
+              { "Variable": "$.approval_status", "StringEquals": "REJECTED" },
+              { "Variable": "$.approval_status", "StringEquals": "TIMED_OUT" }
+            ],
+            "Next": "ReleaseLock"
+          }
+        ],
+        "Default": "WaitForDestroyApproval"
+      },
+      "WaitForDestroyApproval": {
+        "Type": "Wait",
+        "Seconds" : 30,
+        "Next": "CheckDestroyApproval"
+      },
+      "DeleteStack": {
+        "Type": "TaskFn",
+        "Comment": "Delete the CloudFormation Stack",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Next": "WaitForComplete",
+        "Catch": [
+          {
+            "Comment": "Stack not deleted, Fail",
+            "ErrorEquals": ["States.ALL"],
+            "ResultPath": "$.error",
+            "Next": "ReleaseLock"
+          }
+        ]
+      },
       "CreateChangeSet": {
         "Type": "TaskFn",
         "Comment": "Create the CloudFormation ChangeSet",
@@ -86,12 +161,44 @@ func StateMachine() (*machine.StateMachine, error) {
         "Comment": "Wait until we are able to Execute",
         "Type": "Choice",
         "Choices": [
+          {
+            "Comment": "Plan only, do not Execute",
+            "And": [
+              { "Variable": "$.plan", "BooleanEquals": true },
+              { "Variable": "$.change_set_execution_status", "StringEquals": "AVAILABLE" }
+            ],
+            "Next": "DeleteChangeSet"
+          },
+          {
+            "Comment": "Destructive changes, delete the ChangeSet and Fail",
+            "And": [
+              { "Variable": "$.change_set_blocked", "BooleanEquals": true },
+              { "Variable": "$.change_set_execution_status", "StringEquals": "AVAILABLE" }
+            ],
+            "Next": "DeleteChangeSet"
+          },
+          {
+            "Comment": "Wait for a manual approval",
+            "And": [
+              { "Variable": "$.approval_required", "BooleanEquals": true },
+              { "Variable": "$.change_set_execution_status", "StringEquals": "AVAILABLE" }
+            ],
+            "Next": "CheckApproval"
+          },
           {
             "Comment": "Continue to Success",
             "Variable": "$.change_set_execution_status",
             "StringEquals": "AVAILABLE",
             "Next": "Execute"
           },
+          {
+            "Comment": "Plan without changes, delete the FAILED ChangeSet",
+            "And": [
+              { "Variable": "$.plan", "BooleanEquals": true },
+              { "Variable": "$.change_set_empty", "BooleanEquals": true }
+            ],
+            "Next": "DeleteChangeSet"
+          },
           {
             "Comment": "It failed",
             "Variable": "$.change_set_status",
@@ -101,6 +208,64 @@ func StateMachine() (*machine.StateMachine, error) {
         ],
         "Default": "WaitForChangeSet"
       },
+      "DeleteChangeSet": {
+        "Type": "TaskFn",
+        "Comment": "Delete the planned or blocked ChangeSet",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Next": "ReleaseLock",
+        "Retry": [{
+          "Comment": "Retry a few times in case of another error",
+          "ErrorEquals": ["States.ALL"],
+          "MaxAttempts": 3,
+          "IntervalSeconds": 5
+        }],
+        "Catch": [{
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "ReleaseLock"
+        }]
+      },
+      "CheckApproval": {
+        "Type": "TaskFn",
+        "Comment": "Check for an approval or rejection of the ChangeSet",
+        "Resource": "arn:aws:lambda:{{aws_region}}:{{aws_account}}:function:{{lambda_name}}",
+        "Next": "Approved?",
+        "Retry": [{
+          "Comment": "Retry a few times in case of another error",
+          "ErrorEquals": ["States.ALL"],
+          "MaxAttempts": 3,
+          "IntervalSeconds": 5
+        }],
+        "Catch": [{
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "ReleaseLock"
+        }]
+      },
+      "Approved?": {
+        "Comment": "Execute when approved, fail when rejected or timed out",
+        "Type": "Choice",
+        "Choices": [
+          {
+            "Variable": "$.approval_status",
+            "StringEquals": "APPROVED",
+            "Next": "Execute"
+          },
+          {
+            "OR": [
+              { "Variable": "$.approval_status", "StringEquals": "REJECTED" },
+              { "Variable": "$.approval_status", "StringEquals": "TIMED_OUT" }
+            ],
+            "Next": "ReleaseLock"
+          }
+        ],
+        "Default": "WaitForApproval"
+      },
+      "WaitForApproval": {
+        "Type": "Wait",
+        "Seconds" : 30,
+        "Next": "CheckApproval"
+      },
       "Execute": {
         "Type": "TaskFn",
         "Comment": "Execute the Changeset",
@@ -179,6 +344,20 @@ func StateMachine() (*machine.StateMachine, error) {
         "Comment": "Check the ChangeSet Complete",
         "Type": "Choice",
         "Choices": [
+          {
+            "Comment": "Destroy Complete",
+            "And": [
+              { "Variable": "$.destroy", "BooleanEquals": true },
+              { "Variable": "$.stack_status", "StringEquals": "DELETE_COMPLETE" }
+            ],
+            "Next": "Success"
+          },
+          {
+            "Comment": "Plan Complete",
+            "Variable": "$.planned",
+            "BooleanEquals": true,
+            "Next": "Success"
+          },
           {
             "OR": [
               { "Variable": "$.stack_status", "StringEquals": "CREATE_COMPLETE" },
@@ -241,8 +420,12 @@ func CreateTaskHandlers(awsc aws.Clients) *handler.TaskHandlers {
 	tm["Validate"] = Validate(awsc)
 	tm["Lock"] = Lock(awsc)
 
+	tm["DeleteStack"] = DeleteStack(awsc)
 	tm["CreateChangeSet"] = CreateChangeSet(awsc)
 	tm["UpdateChangeSet"] = UpdateChangeSet(awsc)
+	tm["DeleteChangeSet"] = DeleteChangeSet(awsc)
+	tm["CheckApproval"] = CheckApproval(awsc)
+	tm["CheckDestroyApproval"] = CheckApproval(awsc)
 
 	tm["Execute"] = Execute(awsc)
 
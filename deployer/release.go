@@ -3,12 +3,14 @@ package deployer
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/coinbase/fenrir/aws"
 	"github.com/coinbase/fenrir/aws/cf"
+	"github.com/coinbase/fenrir/aws/ecr"
 	"github.com/coinbase/fenrir/deployer/template"
 
 	"github.com/coinbase/step/aws/s3"
@@ -30,6 +32,12 @@ type Release struct {
 	// All references to S3 must come with SHA values
 	S3URISHA256s map[string]string `json:"s3_uris_sha256s,omitempty"`
 
+	// All container images must be pinned by their ECR digest
+	ImageURIDigests map[string]string `json:"image_uris_digests,omitempty"`
+
+	// Parameters are the values of the template Parameters for this config
+	Parameters map[string]string `json:"parameters,omitempty"`
+
 	StackName *string `json:"stack_name,omitempty"`
 
 	ChangeSetName *string `json:"change_set_name,omitempty"`
@@ -39,6 +47,9 @@ type Release struct {
 	ChangeSetStatus       string `json:"change_set_status,omitempty"`
 	ChangeSetStatusReason string `json:"change_set_status_reason,omitempty"`
 
+	// ChangeSetEmpty if the ChangeSet FAILED because the template has no changes
+	ChangeSetEmpty bool `json:"change_set_empty"`
+
 	// ChangeSetExecutionStatus enum AVAILABLE, UNAVAILABLE, OBSOLETE
 	ChangeSetExecutionStatus string `json:"change_set_execution_status,omitempty"`
 
@@ -57,12 +68,83 @@ type Release struct {
 	Env string `json:"env,omitempty"`
 
 	ChangeSetTags map[string]string `json:"change_set_tags,omitempty"`
+
+	// Plan only creates the ChangeSet to record its Changes, it is never executed
+	// they are used in choice blocks so are never omitted
+	Plan    bool               `json:"plan"`
+	Planned bool               `json:"planned"`
+	Changes []*ChangeSetChange `json:"changes,omitempty"`
+
+	// Destroy deletes the stack instead of deploying the Template, it is used in choice blocks so is never omitted
+	Destroy bool    `json:"destroy"`
+	StackID *string `json:"stack_id,omitempty"`
+
+	// RetainedResources were skipped when deleting, e.g. DeletionPolicy Retain
+	RetainedResources []string `json:"retained_resources,omitempty"`
+
+	// RollbackOf is the previously deployed release a `fenrir rollback` redeploys
+	RollbackOf string `json:"rollback_of,omitempty"`
+
+	// AllowReplacements are logical IDs allowed to be removed or replaced
+	AllowReplacements []string `json:"allow_replacements,omitempty"`
+
+	// ChangeSetBlocked if the changes would remove or replace a resource, it is used in choice blocks so is never omitted
+	ChangeSetBlocked       bool   `json:"change_set_blocked"`
+	ChangeSetBlockedReason string `json:"change_set_blocked_reason,omitempty"`
+
+	// Warnings are validation findings that do not fail the release, e.g. a runtime near its end of support
+	Warnings []string `json:"warnings,omitempty"`
+
+	// User is the STS identity ARN that made the release, it cannot approve it
+	User string `json:"user,omitempty"`
+
+	// Approval is set from the Fenrir Settings, ApprovalRequired is used in choice blocks so is never omitted
+	ApprovalRequired    bool       `json:"approval_required"`
+	ApprovalTimeout     *int       `json:"approval_timeout,omitempty"`
+	ApprovalRequestedAt *time.Time `json:"approval_requested_at,omitempty"`
+
+	// ApprovalStatus enum PENDING, APPROVED, REJECTED, TIMED_OUT
+	ApprovalStatus string `json:"approval_status,omitempty"`
+	ApprovalUser   string `json:"approval_user,omitempty"`
+
+	// ApprovalIgnoredReason is why the last approval found was invalid, e.g. written by User
+	ApprovalIgnoredReason string `json:"approval_ignored_reason,omitempty"`
+}
+
+// ChangeSetChange is a resource change from DescribeChangeSet
+type ChangeSetChange struct {
+	// Action enum Add, Modify, Remove, Import, Dynamic
+	Action             string `json:"action"`
+	LogicalResourceID  string `json:"logical_resource_id"`
+	PhysicalResourceID string `json:"physical_resource_id,omitempty"`
+	ResourceType       string `json:"resource_type"`
+
+	// Replacement enum True, False, Conditional
+	Replacement string   `json:"replacement,omitempty"`
+	Scope       []string `json:"scope,omitempty"`
 }
 
 //////////
 // Validate
 //////////
 
+// ValidateDestroy validates a release that deletes the stack
+func (release *Release) ValidateDestroy(s3c aws.S3API) error {
+	if err := release.Release.Validate(s3c, &Release{}); err != nil {
+		return err
+	}
+
+	if release.Template != nil {
+		return fmt.Errorf("Destroy must not include a template")
+	}
+
+	if release.Plan {
+		return fmt.Errorf("Destroy cannot be planned")
+	}
+
+	return nil
+}
+
 // Validate returns
 func (release *Release) Validate(s3c aws.S3API) error {
 	if err := release.Release.Validate(s3c, &Release{}); err != nil {
@@ -73,6 +155,11 @@ func (release *Release) Validate(s3c aws.S3API) error {
 		return fmt.Errorf("SAM is nil")
 	}
 
+	// Resolve Mappings and Conditions so the deployed template is validated
+	if err := release.ResolveTemplate(); err != nil {
+		return err
+	}
+
 	input, err := release.CreateChangeSetInput()
 	if err != nil {
 		return err
@@ -83,6 +170,10 @@ func (release *Release) Validate(s3c aws.S3API) error {
 		return err
 	}
 
+	if err := release.ValidateImageDigests(); err != nil {
+		return err
+	}
+
 	if err := input.Validate(); err != nil {
 		return err
 	}
@@ -118,6 +209,22 @@ func (release *Release) ValidateSHAs(s3c aws.S3API) error {
 	return nil
 }
 
+// ValidateImageDigests checks every image URI is pinned by its digest,
+// the images are found in ECR when the functions are validated
+func (release *Release) ValidateImageDigests() error {
+	for imageURI, digest := range release.ImageURIDigests {
+		uri, err := ecr.ParseImageURI(imageURI)
+		if err != nil {
+			return err
+		}
+
+		if uri.Digest == "" || uri.Digest != digest {
+			return fmt.Errorf("Incorrect digest for %v: expected %v", imageURI, digest)
+		}
+	}
+	return nil
+}
+
 func (release *Release) ValidateSchema() error {
 	// Don't use SAM.JSON() because it replaces base64 strings with objects
 	templateBody, err := json.Marshal(release.Template)
@@ -125,6 +232,27 @@ func (release *Release) ValidateSchema() error {
 		return err
 	}
 
+	var document map[string]interface{}
+	if err := json.Unmarshal(templateBody, &document); err != nil {
+		return err
+	}
+
+	if len(release.Template.Parameters) > 0 {
+		document["Parameters"] = template.SchemaParameters(release.Template.Parameters)
+	}
+
+	// Names the schema requires are generated after it is validated
+	resources, _ := document["Resources"].(map[string]interface{})
+	template.SchemaGeneratedNames(resources)
+
+	// The schema does not have the passthrough types or container images, they are validated by decoding their properties
+	template.SchemaPassthroughTypes(resources)
+	template.SchemaImageFunctions(resources)
+
+	if templateBody, err = json.Marshal(document); err != nil {
+		return err
+	}
+
 	schemaLoader := gojsonschema.NewStringLoader(schema.SamSchema)
 	documentLoader := gojsonschema.NewStringLoader(string(templateBody))
 
@@ -147,6 +275,7 @@ func (release *Release) ValidateSchema() error {
 
 // Resource Validations
 func (release *Release) ValidateTemplate(
+	settings *Settings,
 	ec2c aws.EC2API,
 	iamc aws.IAMAPI,
 	s3c aws.S3API,
@@ -157,41 +286,119 @@ func (release *Release) ValidateTemplate(
 	kmsc aws.KMSAPI,
 	lambdac aws.LambdaAPI,
 	cwlc aws.CWLAPI,
+	ebc aws.EBAPI,
+	cfc aws.CFAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+	ecrc aws.ECRAPI,
 ) error {
-	// Disabling some template objects because their interations might be
-	if release.Template.Parameters != nil {
-		return fmt.Errorf("Unsupported Parameters")
-	}
-
+	// Validators only understand the template as it will be deployed
 	if release.Template.Conditions != nil {
-		return fmt.Errorf("Unsupported Conditions")
+		return fmt.Errorf("Unresolved Conditions")
 	}
 
 	if release.Template.Mappings != nil {
-		return fmt.Errorf("Unsupported Mappings")
+		return fmt.Errorf("Unresolved Mappings")
 	}
 
+	// Disabling some template objects because their interations might be
 	if release.Template.Metadata != nil {
 		return fmt.Errorf("Unsupported Metadata")
 	}
 
-	if err := template.ValidateTemplateResources(
+	// Report parameter and resource errors together
+	errs := template.ValidationErrors{}
+
+	if err := template.ValidateParameters(release.Template.Parameters, release.Parameters); err != nil {
+		errs = append(errs, err.(template.ValidationErrors)...)
+	}
+
+	// Function log groups are added before validation so they are validated like declared ones
+	if err := release.addFunctionLogGroups(cfc, cwlc); err != nil {
+		return err
+	}
+
+	switch err := template.ValidateTemplateResources(
 		*release.ProjectName, *release.ConfigName,
 		*release.AwsRegion, *release.AwsAccountID,
-		release.Template, release.S3URISHA256s,
-		iamc, ec2c, s3c, kinc, ddbc, sqsc, snsc, kmsc, lambdac, cwlc); err != nil {
+		release.Template, release.S3URISHA256s, release.ImageURIDigests,
+		settings.Limits(*release.ProjectName, *release.ConfigName),
+		iamc, ec2c, s3c, kinc, ddbc, sqsc, snsc, kmsc, lambdac, cwlc, ebc, ssmc, smc, sfnc, ecrc).(type) {
+	case nil:
+	case template.ValidationErrors:
+		errs = append(errs, err...)
+	default:
+		return err
+	}
+
+	errs, warnings := errs.SplitWarnings()
+	release.Warnings = []string{}
+	for _, warning := range warnings {
+		release.Warnings = append(release.Warnings, warning.Error())
+	}
+
+	return errs.OrNil()
+}
+
+// addFunctionLogGroups adds a log group for each function that is not already in the stack
+func (release *Release) addFunctionLogGroups(cfc aws.CFAPI, cwlc aws.CWLAPI) error {
+	resources, err := cf.StackResources(cfc, release.StackName)
+	if err != nil {
 		return err
 	}
 
+	managed := map[string]bool{}
+	for _, res := range resources {
+		if to.Strs(res.ResourceType) == "AWS::Logs::LogGroup" {
+			managed[to.Strs(res.PhysicalResourceId)] = true
+		}
+	}
+
+	return template.AddFunctionLogGroups(*release.ProjectName, *release.ConfigName, release.Template, managed, cwlc)
+}
+
+// ResolveTemplate statically evaluates the templates Mappings, Conditions and Parameters
+// so disabled resources are removed rather than skipping validation
+func (release *Release) ResolveTemplate() error {
+	// Don't use SAM.JSON() because it replaces base64 strings with objects
+	templateBody, err := json.Marshal(release.Template)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := template.ResolveConditions(templateBody, release.StaticValues())
+	if err != nil {
+		return err
+	}
+
+	tmpl := &gocf.Template{}
+	if err := json.Unmarshal(resolved, tmpl); err != nil {
+		return err
+	}
+
+	release.Template = tmpl
 	return nil
 }
 
+// StaticValues are the values Mappings and Conditions are evaluated against
+func (release *Release) StaticValues() template.StaticValues {
+	return template.StaticValues{
+		ProjectName: to.Strs(release.ProjectName),
+		ConfigName:  to.Strs(release.ConfigName),
+		Region:      to.Strs(release.AwsRegion),
+		AccountID:   to.Strs(release.AwsAccountID),
+		Parameters:  release.Parameters,
+	}
+}
+
 //////////
 // Defaults
 //////////
 
 func (release *Release) SetDefaults(region *string, account *string) {
 	release.Success = to.Boolp(false)
+	release.Planned = false
 
 	if release.Timeout == nil {
 		release.Timeout = to.Intp(300) // Default to 5 mins
@@ -206,6 +413,10 @@ func (release *Release) SetDefaults(region *string, account *string) {
 		release.S3URISHA256s = map[string]string{}
 	}
 
+	if release.ImageURIDigests == nil {
+		release.ImageURIDigests = map[string]string{}
+	}
+
 	// Override Tags
 	if release.ChangeSetTags == nil {
 		release.ChangeSetTags = map[string]string{}
@@ -258,6 +469,12 @@ func (release *Release) CreateChangeSetInput() (*cloudformation.CreateChangeSetI
 		return nil, err
 	}
 
+	// SAM goformation drops is passed through resource Metadata
+	templateBody, err = template.RestorePassthrough(templateBody)
+	if err != nil {
+		return nil, err
+	}
+
 	changeSetInput := &cloudformation.CreateChangeSetInput{
 		ChangeSetName: release.ChangeSetName,
 		ClientToken:   release.ReleaseID,
@@ -267,11 +484,32 @@ func (release *Release) CreateChangeSetInput() (*cloudformation.CreateChangeSetI
 		Capabilities:  []*string{to.Strp("CAPABILITY_IAM")},
 		TemplateBody:  to.Strp(string(templateBody)),
 		Tags:          mapToTags(release.ChangeSetTags),
+		Parameters:    mapToParameters(release.Parameters),
 	}
 
 	return changeSetInput, nil
 }
 
+// mapToParameters sorts the parameters by key, nil if there are none
+func mapToParameters(params map[string]string) []*cloudformation.Parameter {
+	if len(params) == 0 {
+		return nil
+	}
+
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cfparams := []*cloudformation.Parameter{}
+	for _, k := range keys {
+		cfparams = append(cfparams, &cloudformation.Parameter{ParameterKey: to.Strp(k), ParameterValue: to.Strp(params[k])})
+	}
+
+	return cfparams
+}
+
 func mapToTags(tags map[string]string) []*cloudformation.Tag {
 	cstags := []*cloudformation.Tag{}
 	for k, v := range tags {
@@ -314,15 +552,128 @@ func (release *Release) FetchChangeSet(cfc aws.CFAPI) error {
 		release.ChangeSetStatusReason = *output.StatusReason
 	}
 
+	release.ChangeSetEmpty = release.ChangeSetStatus == "FAILED" && isEmptyChangeSetReason(release.ChangeSetStatusReason)
+
+	// Changes are paginated
+	release.Changes = []*ChangeSetChange{}
+	for {
+		release.Changes = append(release.Changes, changeSetChanges(output.Changes)...)
+
+		if output.NextToken == nil {
+			break
+		}
+
+		output, err = cfc.DescribeChangeSet(&cloudformation.DescribeChangeSetInput{
+			ChangeSetName: release.ChangeSetName,
+			StackName:     release.StackName,
+			NextToken:     output.NextToken,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if output == nil {
+			return fmt.Errorf("Unknown DescribeChangeSet Error")
+		}
+	}
+
+	if release.ChangeSetStatus == "CREATE_COMPLETE" {
+		release.BlockDestructiveChanges()
+	}
+
 	return nil
 }
 
+// emptyChangeSetReasons are the StatusReasons of a ChangeSet that FAILED because nothing changed
+var emptyChangeSetReasons = []string{
+	"didn't contain changes",
+	"No updates are to be performed",
+}
+
+func isEmptyChangeSetReason(reason string) bool {
+	for _, r := range emptyChangeSetReasons {
+		if strings.Contains(reason, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// statefulResourceTypes lose data when replaced
+var statefulResourceTypes = []string{
+	"AWS::DynamoDB::Table",
+	"AWS::SQS::Queue",
+	"AWS::Kinesis::Stream",
+	"AWS::ElasticLoadBalancingV2::LoadBalancer",
+	"AWS::S3::Bucket",
+}
+
+// BlockDestructiveChanges blocks the changeset if it removes any resource
+// or replaces a stateful resource not in AllowReplacements
+func (release *Release) BlockDestructiveChanges() {
+	reasons := []string{}
+	for _, c := range release.Changes {
+		if inSlice(c.LogicalResourceID, release.AllowReplacements) {
+			continue
+		}
+
+		if c.Action == "Remove" {
+			reasons = append(reasons, fmt.Sprintf("%v (%v) would be removed", c.LogicalResourceID, c.ResourceType))
+		} else if c.Replacement == "True" && inSlice(c.ResourceType, statefulResourceTypes) {
+			reasons = append(reasons, fmt.Sprintf("%v (%v) would be replaced", c.LogicalResourceID, c.ResourceType))
+		}
+	}
+
+	release.ChangeSetBlocked = len(reasons) > 0
+	release.ChangeSetBlockedReason = ""
+	if release.ChangeSetBlocked {
+		release.ChangeSetBlockedReason = fmt.Sprintf("%v, add to allow_replacements to allow", strings.Join(reasons, ", "))
+	}
+}
+
+func inSlice(str string, list []string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func changeSetChanges(changes []*cloudformation.Change) []*ChangeSetChange {
+	cscs := []*ChangeSetChange{}
+	for _, c := range changes {
+		if c == nil || c.ResourceChange == nil {
+			continue
+		}
+
+		rc := c.ResourceChange
+		cscs = append(cscs, &ChangeSetChange{
+			Action:             to.Strs(rc.Action),
+			LogicalResourceID:  to.Strs(rc.LogicalResourceId),
+			PhysicalResourceID: to.Strs(rc.PhysicalResourceId),
+			ResourceType:       to.Strs(rc.ResourceType),
+			Replacement:        to.Strs(rc.Replacement),
+			Scope:              to.StrSlice(rc.Scope),
+		})
+	}
+
+	return cscs
+}
+
 func (release *Release) ClientRequestToken() *string {
 	return release.ChangeSetName
 }
 
 func (release *Release) FetchStack(s3c aws.S3API, cfc aws.CFAPI) error {
-	stack, err := cf.DescribeStack(cfc, release.StackName)
+	// Deleted stacks can only be described by their ID
+	name := release.StackName
+	if release.StackID != nil {
+		name = release.StackID
+	}
+
+	stack, err := cf.DescribeStack(cfc, name)
 
 	if err != nil {
 		switch err.(type) {
@@ -357,7 +708,13 @@ func (release *Release) updateStack(s3c aws.S3API, cfc aws.CFAPI, stack *cloudfo
 		release.StackStatusReason = *stack.StackStatusReason
 	}
 
-	output, err := cfc.DescribeStackEvents(&cloudformation.DescribeStackEventsInput{StackName: release.StackName})
+	// Deleted stacks events are only found by ID
+	stackName := release.StackName
+	if stack.StackId != nil {
+		stackName = stack.StackId
+	}
+
+	output, err := cfc.DescribeStackEvents(&cloudformation.DescribeStackEventsInput{StackName: stackName})
 	if err != nil || output == nil || output.StackEvents == nil {
 		return nil // Ignore this error, not great but it will be fine, I swear.
 	}
@@ -365,12 +722,17 @@ func (release *Release) updateStack(s3c aws.S3API, cfc aws.CFAPI, stack *cloudfo
 	// LOG looks like
 	// date	Status	Type	Logical ID	Status Reason
 	log := ""
+	retained := []string{}
 	for _, e := range output.StackEvents {
 		// Filter by token should only show changeset events
 		if e.ClientRequestToken != nil && (*e.ClientRequestToken != *release.ClientRequestToken()) {
 			continue
 		}
 
+		if to.Strs(e.ResourceStatus) == "DELETE_SKIPPED" {
+			retained = append(retained, fmt.Sprintf("%s %s %s", to.Strs(e.ResourceType), to.Strs(e.LogicalResourceId), to.Strs(e.PhysicalResourceId)))
+		}
+
 		log += fmt.Sprintf(
 			"%s %s %s %s %s\n",
 			e.Timestamp.Format(time.RFC3339),
@@ -385,6 +747,10 @@ func (release *Release) updateStack(s3c aws.S3API, cfc aws.CFAPI, stack *cloudfo
 		log += fmt.Sprintf("%s\n", release.ChangeSetStatusReason)
 	}
 
+	if len(retained) > 0 {
+		release.RetainedResources = retained
+	}
+
 	// Attach log to release and write to file
 	release.LogSummary = to.Strp(log)
 	release.WriteLog(s3c, log) // ignore errors
@@ -407,6 +773,76 @@ func (release *Release) Execute(cfc aws.CFAPI) error {
 	return nil
 }
 
+// DeleteChangeSet removes a planned changeset without executing it
+// A CREATE changeset leaves an empty stack in REVIEW_IN_PROGRESS that is also deleted
+func (release *Release) DeleteChangeSet(cfc aws.CFAPI) error {
+	_, err := cfc.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+		ChangeSetName: release.ChangeSetName,
+		StackName:     release.StackName,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if release.ChangeSetType == nil || *release.ChangeSetType != "CREATE" {
+		return nil
+	}
+
+	stack, err := cf.DescribeStack(cfc, release.StackName)
+	if err != nil {
+		switch err.(type) {
+		case cf.NotFoundError:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	// Only a stack with no resources can be deleted
+	if to.Strs(stack.StackStatus) != "REVIEW_IN_PROGRESS" {
+		return nil
+	}
+
+	return cf.DeleteStack(cfc, release.StackName)
+}
+
+// DeleteStack deletes the projects stack if its tags match the release
+func (release *Release) DeleteStack(cfc aws.CFAPI) error {
+	stack, err := cf.DescribeStack(cfc, release.StackName)
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range stack.Tags {
+		tags[to.Strs(tag.Key)] = to.Strs(tag.Value)
+	}
+
+	if tags["ProjectName"] != *release.ProjectName || tags["ConfigName"] != *release.ConfigName {
+		return fmt.Errorf("Stack tags ProjectName %q ConfigName %q do not match release", tags["ProjectName"], tags["ConfigName"])
+	}
+
+	if strings.HasSuffix(to.Strs(stack.StackStatus), "_IN_PROGRESS") {
+		return fmt.Errorf("Stack status %v is in progress", to.Strs(stack.StackStatus))
+	}
+
+	release.StackID = stack.StackId
+	release.StackCreationTime = stack.CreationTime
+
+	_, err = cfc.DeleteStack(&cloudformation.DeleteStackInput{
+		StackName:          release.StackID,
+		ClientRequestToken: release.ClientRequestToken(),
+	})
+
+	return err
+}
+
+// Destroyed returns true if the release deleted the stack
+func (release *Release) Destroyed() bool {
+	return release.Destroy && release.StackStatus == "DELETE_COMPLETE"
+}
+
 // CleanUpStuckStack checks to see if we need to delete the stack on create failure
 // We have to be very careful in this method as we DO NOT want to accidentally delete a stack
 // because of https://github.com/awslabs/aws-cdk/issues/901
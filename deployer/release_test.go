@@ -1,10 +1,13 @@
 package deployer
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/coinbase/fenrir/deployer/template"
 	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,6 +33,25 @@ func Test_Release_Cleanup(t *testing.T) {
 	assert.True(t, awsc.CFClient.DeleteStackCalled)
 }
 
+func Test_CleanUp_Cause(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.SetDefaults(to.Strp("region"), release.AwsAccountID)
+	release.ChangeSetStatusReason = "broke"
+	release.StackStatusReason = "failed"
+	release.ChangeSetBlocked = true
+	release.ChangeSetBlockedReason = "table would be replaced"
+	release.ApprovalStatus = "TIMED_OUT"
+
+	awsc := MockAwsClients(release)
+
+	release, err = CleanUp(awsc)(context.Background(), release)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "changeset: broke :: stack: failed :: changeset blocked: table would be replaced :: approval: timed out", *release.Error.Cause)
+}
+
 func Test_Release_CreateChangeSetInput_Tags(t *testing.T) {
 	t.Run("tags", func(t *testing.T) {
 		release, err := MockRelease("../examples/tests/allowed/function.yml")
@@ -55,3 +77,299 @@ func Test_Release_CreateChangeSetInput_Tags(t *testing.T) {
 		assert.Equal(t, "TTTAG", tags["CustomTag"])
 	})
 }
+
+func Test_Release_CreateChangeSetInput_Parameters(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/parameters.yml")
+	assert.NoError(t, err)
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+	assert.Nil(t, input.Parameters)
+
+	release.Parameters = map[string]string{"Stage": "prod", "Threshold": "5"}
+
+	input, err = release.CreateChangeSetInput()
+	assert.NoError(t, err)
+	assert.Equal(t, []*cloudformation.Parameter{
+		&cloudformation.Parameter{ParameterKey: to.Strp("Stage"), ParameterValue: to.Strp("prod")},
+		&cloudformation.Parameter{ParameterKey: to.Strp("Threshold"), ParameterValue: to.Strp("5")},
+	}, input.Parameters)
+
+	// Values are part of the release SHA
+	sha := to.SHA256Struct(release)
+	release.Parameters["Stage"] = "dev"
+	assert.NotEqual(t, sha, to.SHA256Struct(release))
+}
+
+func Test_Release_CreateChangeSetInput_Passthrough(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function_w_more_policies.yml")
+	assert.NoError(t, err)
+
+	fn, err := release.Template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.Contains(t, fn.AWSCloudFormationMetadata, template.PassthroughKey)
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+
+	var body struct {
+		Resources map[string]struct {
+			Metadata   map[string]interface{}
+			Properties struct {
+				Policies []map[string]interface{}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(*input.TemplateBody), &body))
+
+	// The stashed policies are appended and the Metadata removed
+	hello := body.Resources["hello"]
+	assert.Nil(t, hello.Metadata)
+	assert.Len(t, hello.Properties.Policies, 9)
+	assert.Equal(t, map[string]interface{}{
+		"SSMParameterReadPolicy": map[string]interface{}{"ParameterName": "project/development/parameter"},
+	}, hello.Properties.Policies[7])
+
+	// Only validated policies are restored
+	fn.AWSCloudFormationMetadata[template.PassthroughKey] = map[string]interface{}{
+		"Policies": []interface{}{map[string]interface{}{"AdministratorAccess": map[string]interface{}{}}},
+	}
+	_, err = release.CreateChangeSetInput()
+	assert.Error(t, err)
+}
+
+func Test_Release_CreateChangeSetInput_PassthroughHttpApi(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/http_api.yml")
+	assert.NoError(t, err)
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+
+	var body struct {
+		Resources map[string]struct {
+			Type       string
+			Properties struct {
+				Events map[string]map[string]interface{}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(*input.TemplateBody), &body))
+
+	// The custom resource is deployed as its SAM type and the events are restored
+	assert.Equal(t, "AWS::Serverless::HttpApi", body.Resources["helloHttpApi"].Type)
+	assert.Len(t, body.Resources["hello"].Properties.Events, 3)
+	assert.Equal(t, map[string]interface{}{"Ref": "helloHttpApi"}, body.Resources["hello"].Properties.Events["Get"]["Properties"].(map[string]interface{})["ApiId"])
+}
+
+func Test_Release_CreateChangeSetInput_PassthroughImage(t *testing.T) {
+	release, err := MockRelease("../examples/tests/not/bad_image.yml")
+	assert.NoError(t, err)
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+
+	var body struct {
+		Resources map[string]struct {
+			Metadata   map[string]interface{}
+			Properties map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(*input.TemplateBody), &body))
+
+	// The container image properties are restored from the Metadata
+	hello := body.Resources["hello"]
+	assert.Nil(t, hello.Metadata)
+	assert.Equal(t, "Image", hello.Properties["PackageType"])
+	assert.Equal(t, "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:development", hello.Properties["ImageUri"])
+	assert.Equal(t, map[string]interface{}{"Command": []interface{}{"bootstrap"}}, hello.Properties["ImageConfig"])
+}
+
+func Test_Release_CreateChangeSetInput_PassthroughStateMachine(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/state_machine_w_policies.yml")
+	assert.NoError(t, err)
+	release.SetDefaults(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	awsc := MockAwsClients(release)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), release.Bucket)
+	assert.NoError(t, err)
+
+	assert.NoError(t, release.ValidateTemplate(
+		settings,
+		awsc.EC2(nil, nil, nil),
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.CF(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	))
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+
+	var body struct {
+		Resources map[string]struct {
+			Metadata   map[string]interface{}
+			Properties struct {
+				PermissionsBoundary string
+				Policies            []map[string]interface{}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(*input.TemplateBody), &body))
+
+	// The policy templates goformation only has for functions and the permissions boundary are restored
+	sm := body.Resources["helloStateMachine"]
+	assert.Nil(t, sm.Metadata)
+	assert.Equal(t, "arn:aws:iam::00000000:policy/fenrir-permissions-boundary", sm.Properties.PermissionsBoundary)
+	assert.Len(t, sm.Properties.Policies, 3)
+	assert.Contains(t, sm.Properties.Policies, map[string]interface{}{
+		"DynamoDBReadPolicy": map[string]interface{}{"TableName": map[string]interface{}{"Ref": "Table"}},
+	})
+}
+
+func Test_Release_ValidateImageDigests(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	digest := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	pinned := "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@" + digest
+
+	release.ImageURIDigests = map[string]string{pinned: digest}
+	assert.NoError(t, release.ValidateImageDigests())
+
+	release.ImageURIDigests = map[string]string{pinned: "sha256:other"}
+	assert.Regexp(t, "Incorrect digest", release.ValidateImageDigests())
+
+	release.ImageURIDigests = map[string]string{"000000000000.dkr.ecr.us-east-1.amazonaws.com/hello:latest": digest}
+	assert.Regexp(t, "Incorrect digest", release.ValidateImageDigests())
+}
+
+func Test_Release_BlockDestructiveChanges(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function.yml")
+	assert.NoError(t, err)
+
+	release.Changes = []*ChangeSetChange{
+		&ChangeSetChange{Action: "Modify", LogicalResourceID: "hello", ResourceType: "AWS::Lambda::Function", Replacement: "True"},
+		&ChangeSetChange{Action: "Modify", LogicalResourceID: "table", ResourceType: "AWS::DynamoDB::Table", Replacement: "Conditional"},
+	}
+
+	release.BlockDestructiveChanges()
+	assert.False(t, release.ChangeSetBlocked)
+
+	release.Changes = append(release.Changes,
+		&ChangeSetChange{Action: "Modify", LogicalResourceID: "queue", ResourceType: "AWS::SQS::Queue", Replacement: "True"},
+		&ChangeSetChange{Action: "Remove", LogicalResourceID: "old", ResourceType: "AWS::Lambda::Function"},
+	)
+
+	release.BlockDestructiveChanges()
+	assert.True(t, release.ChangeSetBlocked)
+	assert.Regexp(t, `queue \(AWS::SQS::Queue\) would be replaced, old \(AWS::Lambda::Function\) would be removed`, release.ChangeSetBlockedReason)
+
+	release.AllowReplacements = []string{"queue", "old"}
+
+	release.BlockDestructiveChanges()
+	assert.False(t, release.ChangeSetBlocked)
+	assert.Equal(t, "", release.ChangeSetBlockedReason)
+}
+
+func Test_Release_ValidateTemplate_LogGroups(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/cloudwatch.yml")
+	assert.NoError(t, err)
+	release.SetDefaults(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	awsc := MockAwsClients(release)
+
+	// Lambda created the log group, but it is already in the stack
+	awsc.CWLClient.AddLogGroup("/aws/lambda/fenrir-project-development-hello")
+	awsc.CFClient.StackResources = []*cloudformation.StackResourceSummary{{
+		ResourceType:       to.Strp("AWS::Logs::LogGroup"),
+		PhysicalResourceId: to.Strp("/aws/lambda/fenrir-project-development-hello"),
+	}}
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), release.Bucket)
+	assert.NoError(t, err)
+
+	err = release.ValidateTemplate(
+		settings,
+		awsc.EC2(nil, nil, nil),
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.CF(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	)
+	assert.NoError(t, err)
+
+	group, err := release.Template.GetLogsLogGroupWithName("helloLogGroup")
+	assert.NoError(t, err)
+	assert.Equal(t, "/aws/lambda/fenrir-project-development-hello", group.LogGroupName)
+	assert.Equal(t, 30, group.RetentionInDays)
+}
+
+func Test_Release_ValidateTemplate_Warnings(t *testing.T) {
+	release, err := MockRelease("../examples/tests/allowed/function_arm64.yml")
+	assert.NoError(t, err)
+	release.SetDefaults(to.Strp("us-east-1"), to.Strp("000000000000"))
+
+	awsc := MockAwsClients(release)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), release.Bucket)
+	assert.NoError(t, err)
+
+	err = release.ValidateTemplate(
+		settings,
+		awsc.EC2(nil, nil, nil),
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.CF(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	)
+
+	// provided.al2 is past end of support but can still be deployed
+	assert.NoError(t, err)
+	assert.Len(t, release.Warnings, 1)
+	assert.Regexp(t, `Runtime "provided.al2" support ended 2026-06-30.*rule: DeprecatedRuntime`, release.Warnings[0])
+
+	input, err := release.CreateChangeSetInput()
+	assert.NoError(t, err)
+
+	var body struct {
+		Resources map[string]struct {
+			Properties map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(*input.TemplateBody), &body))
+	assert.Equal(t, []interface{}{"arm64"}, body.Resources["hello"].Properties["Architectures"])
+}
@@ -0,0 +1,43 @@
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/bifrost"
+)
+
+// ReleaseResult is the record of what a release did, written when it finishes
+type ReleaseResult struct {
+	ReleaseID   *string               `json:"release_id,omitempty"`
+	CreatedAt   *time.Time            `json:"created_at,omitempty"`
+	FinishedAt  *time.Time            `json:"finished_at,omitempty"`
+	Success     bool                  `json:"success"`
+	Plan        bool                  `json:"plan,omitempty"`
+	StackStatus string                `json:"stack_status,omitempty"`
+	Error       *bifrost.ReleaseError `json:"error,omitempty"`
+	Outputs     map[string]string     `json:"outputs,omitempty"`
+}
+
+// ResultPath is where the ReleaseResult is written
+func (release *Release) ResultPath() *string {
+	s := fmt.Sprintf("%v/result", *release.ReleaseDir())
+	return &s
+}
+
+// WriteResult records the outcome of the release
+func (release *Release) WriteResult(s3c aws.S3API, success bool) error {
+	now := time.Now()
+	return s3.PutStruct(s3c, release.Bucket, release.ResultPath(), &ReleaseResult{
+		ReleaseID:   release.ReleaseID,
+		CreatedAt:   release.CreatedAt,
+		FinishedAt:  &now,
+		Success:     success,
+		Plan:        release.Plan,
+		StackStatus: release.StackStatus,
+		Error:       release.Error,
+		Outputs:     release.Outputs,
+	})
+}
@@ -0,0 +1,135 @@
+package deployer
+
+import (
+	"time"
+
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/deployer/template"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// SettingsPath is the key of the deployers Settings in the Fenrir bucket
+var SettingsPath = to.Strp("_settings.json")
+
+// Settings are controlled by the Fenrir bucket owner, not the release
+type Settings struct {
+	// Approvals lists the projects and configs that need a manual approval
+	Approvals []*ApprovalSetting `json:"approvals,omitempty"`
+
+	// ApprovalTimeout is the seconds to wait for an approval, defaults to 1 hour
+	ApprovalTimeout *int `json:"approval_timeout,omitempty"`
+
+	// MinScheduleRate is the fewest seconds between scheduled events, defaults to 1 minute
+	MinScheduleRate *int `json:"min_schedule_rate,omitempty"`
+
+	// EventSources lists the event pattern sources each ProjectName can match, "*" matches every project.
+	// Without it any source can be matched
+	EventSources map[string][]string `json:"event_sources,omitempty"`
+
+	// Domains lists the custom domains, and their subdomains, each ProjectName can use, "*" matches every project
+	Domains map[string][]string `json:"domains,omitempty"`
+
+	// SNSEndpoints lists the external endpoints, e.g. email addresses, SNS topics of a ProjectName and ConfigName can subscribe
+	SNSEndpoints []*SNSEndpointSetting `json:"sns_endpoints,omitempty"`
+
+	// LogRetentionInDays is the retention of log groups that do not set one, defaults to 30
+	LogRetentionInDays *int `json:"log_retention_in_days,omitempty"`
+}
+
+// ApprovalSetting matches a ProjectName and ConfigName, "*" matches everything
+type ApprovalSetting struct {
+	ProjectName string `json:"project_name"`
+	ConfigName  string `json:"config_name"`
+}
+
+// SNSEndpointSetting matches a ProjectName and ConfigName, "*" matches everything
+type SNSEndpointSetting struct {
+	ProjectName string   `json:"project_name"`
+	ConfigName  string   `json:"config_name"`
+	Endpoints   []string `json:"endpoints"`
+}
+
+// LoadSettings fetches the Settings, if none exist the defaults are returned
+func LoadSettings(s3c aws.S3API, bucket *string) (*Settings, error) {
+	var settings Settings
+
+	err := s3.GetStruct(s3c, bucket, SettingsPath, &settings)
+	if err != nil {
+		switch err.(type) {
+		case *s3.NotFoundError:
+			// No settings use defaults
+		default:
+			return nil, err
+		}
+	}
+
+	settings.SetDefaults()
+
+	return &settings, nil
+}
+
+func (settings *Settings) SetDefaults() {
+	if settings.ApprovalTimeout == nil {
+		settings.ApprovalTimeout = to.Intp(3600)
+	}
+
+	if settings.MinScheduleRate == nil {
+		settings.MinScheduleRate = to.Intp(60)
+	}
+
+	if settings.LogRetentionInDays == nil {
+		settings.LogRetentionInDays = to.Intp(30)
+	}
+}
+
+// RequiresApproval returns true if a release to projectName and configName must be approved
+func (settings *Settings) RequiresApproval(projectName, configName string) bool {
+	for _, a := range settings.Approvals {
+		if a == nil {
+			continue
+		}
+
+		if (a.ProjectName == "*" || a.ProjectName == projectName) &&
+			(a.ConfigName == "*" || a.ConfigName == configName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Limits returns the resource limits for projectName and configName
+func (settings *Settings) Limits(projectName, configName string) template.Limits {
+	// Sources are only limited once event_sources is set
+	var sources []string
+	if settings.EventSources != nil {
+		sources = []string{}
+		sources = append(sources, settings.EventSources["*"]...)
+		sources = append(sources, settings.EventSources[projectName]...)
+	}
+
+	domains := []string{}
+	domains = append(domains, settings.Domains["*"]...)
+	domains = append(domains, settings.Domains[projectName]...)
+
+	endpoints := []string{}
+	for _, e := range settings.SNSEndpoints {
+		if e == nil {
+			continue
+		}
+
+		if (e.ProjectName == "*" || e.ProjectName == projectName) &&
+			(e.ConfigName == "*" || e.ConfigName == configName) {
+			endpoints = append(endpoints, e.Endpoints...)
+		}
+	}
+
+	return template.Limits{
+		MinScheduleRate:    time.Duration(*settings.MinScheduleRate) * time.Second,
+		Sources:            sources,
+		LogRetentionInDays: *settings.LogRetentionInDays,
+		Domains:            domains,
+		SNSEndpoints:       endpoints,
+	}
+}
@@ -0,0 +1,97 @@
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coinbase/fenrir/aws/mocks"
+	"github.com/coinbase/step/utils/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Settings_RequiresApproval(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.S3Client.AddGetObject(*SettingsPath, `{
+		"approvals": [
+			{"project_name": "coinbase/fenrir", "config_name": "production"},
+			{"project_name": "*", "config_name": "prod"}
+		],
+		"approval_timeout": 60
+	}`, nil)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 60, *settings.ApprovalTimeout)
+	assert.True(t, settings.RequiresApproval("coinbase/fenrir", "production"))
+	assert.True(t, settings.RequiresApproval("other", "prod"))
+	assert.False(t, settings.RequiresApproval("coinbase/fenrir", "development"))
+}
+
+func Test_Settings_Defaults(t *testing.T) {
+	settings, err := LoadSettings(mocks.MockAWS().S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3600, *settings.ApprovalTimeout)
+	assert.Equal(t, 60, *settings.MinScheduleRate)
+	assert.Equal(t, 30, *settings.LogRetentionInDays)
+	assert.False(t, settings.RequiresApproval("coinbase/fenrir", "production"))
+	assert.Nil(t, settings.Limits("coinbase/fenrir", "development").Sources)
+}
+
+func Test_Settings_EventLimits(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.S3Client.AddGetObject(*SettingsPath, `{
+		"min_schedule_rate": 300,
+		"event_sources": {
+			"coinbase/fenrir": ["aws.ec2"],
+			"*": ["aws.health"]
+		}
+	}`, nil)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+
+	limits := settings.Limits("coinbase/fenrir", "development")
+	assert.Equal(t, 5*time.Minute, limits.MinScheduleRate)
+	assert.Equal(t, []string{"aws.health", "aws.ec2"}, limits.Sources)
+	assert.Equal(t, []string{"aws.health"}, settings.Limits("other", "development").Sources)
+
+	// Once set projects without sources cannot match any on a shared bus
+	awsc.S3Client.AddGetObject(*SettingsPath, `{"event_sources": {"coinbase/fenrir": ["aws.ec2"]}}`, nil)
+	settings, err = LoadSettings(awsc.S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, settings.Limits("other", "development").Sources)
+}
+
+func Test_Settings_DomainLimits(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.S3Client.AddGetObject(*SettingsPath, `{
+		"domains": {
+			"coinbase/fenrir": ["fenrir.example.com"],
+			"*": ["shared.example.com"]
+		}
+	}`, nil)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"shared.example.com", "fenrir.example.com"}, settings.Limits("coinbase/fenrir", "development").Domains)
+	assert.Equal(t, []string{"shared.example.com"}, settings.Limits("other", "development").Domains)
+}
+
+func Test_Settings_SNSEndpointLimits(t *testing.T) {
+	awsc := mocks.MockAWS()
+	awsc.S3Client.AddGetObject(*SettingsPath, `{
+		"sns_endpoints": [
+			{"project_name": "coinbase/fenrir", "config_name": "production", "endpoints": ["alerts@example.com"]},
+			{"project_name": "*", "config_name": "*", "endpoints": ["https://example.com/hook"]}
+		]
+	}`, nil)
+
+	settings, err := LoadSettings(awsc.S3(nil, nil, nil), to.Strp("bucket"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"alerts@example.com", "https://example.com/hook"}, settings.Limits("coinbase/fenrir", "production").SNSEndpoints)
+	assert.Equal(t, []string{"https://example.com/hook"}, settings.Limits("coinbase/fenrir", "development").SNSEndpoints)
+}
@@ -20,7 +20,7 @@ func ValidateAWSCloudFrontDistribution(
 	// Disallow s3 origins for now - we need to validate them securely which isn't trivial
 	for _, origin := range res.DistributionConfig.Origins {
 		if origin.S3OriginConfig != nil {
-			return resourceError(res, resourceName, "S3 Origins are not yet supported for cloudfront distributions")
+			return resourceError(res, resourceName, "DistributionConfig.Origins", "UnsupportedProperty", "S3 Origins are not yet supported for cloudfront distributions")
 		}
 	}
 
@@ -11,7 +11,7 @@ func ValidateAWSCloudWatchAlarm(
 	res *cloudwatch.Alarm,
 ) error {
 	if res.AlarmName != "" {
-		return resourceError(res, resourceName, "Names are overwritten")
+		return resourceError(res, resourceName, "AlarmName", "NameOverwritten", "Names are overwritten")
 	}
 
 	res.AlarmName = normalizeName("fenrir", projectName, configName, resourceName, 255)
@@ -0,0 +1,68 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/dynamodb"
+	"github.com/awslabs/goformation/v4/cloudformation/policies"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/kms"
+)
+
+// AWS::DynamoDB::Table
+
+func ValidateAWSDynamoDBTable(
+	projectName, configName, resourceName string,
+	template *cloudformation.Template,
+	res *dynamodb.Table,
+	kmsc aws.KMSAPI,
+) error {
+
+	if res.AWSCloudFormationDeletionPolicy == "" {
+		res.AWSCloudFormationDeletionPolicy = policies.DeletionPolicy("Retain")
+	}
+
+	if res.AWSCloudFormationUpdateReplacePolicy == "" {
+		res.AWSCloudFormationUpdateReplacePolicy = policies.UpdateReplacePolicy("Retain")
+	}
+
+	if res.TableName != "" {
+		return resourceError(res, resourceName, "TableName", "NameOverwritten", "Names are overwritten")
+	}
+
+	res.TableName = normalizeName("fenrir", projectName, configName, resourceName, 255)
+
+	res.Tags = forceTags(res.Tags, projectName, configName, resourceName)
+
+	if res.PointInTimeRecoverySpecification == nil {
+		res.PointInTimeRecoverySpecification = &dynamodb.Table_PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: true,
+		}
+	}
+
+	if res.SSESpecification == nil {
+		res.SSESpecification = &dynamodb.Table_SSESpecification{SSEEnabled: true}
+	}
+
+	if keyID := res.SSESpecification.KMSMasterKeyId; keyID != "" {
+		key, err := kms.FindKey(kmsc, keyID)
+		if err != nil {
+			return resourceError(res, resourceName, "SSESpecification.KMSMasterKeyId", "ResourceNotFound", fmt.Sprintf("SSESpecification.KMSMasterKeyId %v", err.Error()))
+		}
+
+		if err := hasCorrectTags(projectName, configName, key.Tags); err != nil {
+			return resourceError(res, resourceName, "SSESpecification.KMSMasterKeyId", "IncorrectTags", fmt.Sprintf("SSESpecification.KMSMasterKeyId %v", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// isTable is true for the table resources a DynamoDBCrudPolicy can !Ref
+// tableTypes are the resource types that are DynamoDB tables
+var tableTypes = []string{"AWS::DynamoDB::Table", "AWS::Serverless::SimpleTable"}
+
+func isTable(res cloudformation.Resource) bool {
+	return isType(res, tableTypes...)
+}
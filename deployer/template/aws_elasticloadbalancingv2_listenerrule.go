@@ -12,14 +12,14 @@ func ValidateAWSElasticLoadBalancingV2ListenerRule(
 ) error {
 	ref, err := decodeRef(res.ListenerArn)
 	if err != nil || ref == "" {
-		return resourceError(res, resourceName, "ListenerRule.ListenerArn must be !Ref")
+		return resourceError(res, resourceName, "ListenerArn", "MustBeRef", "ListenerRule.ListenerArn must be !Ref")
 	}
 
 	for _, action := range res.Actions {
 		if action.Type == "forward" {
 			ref, err := decodeRef(action.TargetGroupArn)
 			if err != nil || ref == "" {
-				return resourceError(res, resourceName, "ListenerRule.Actions.TargetGroupArn must be !Ref")
+				return resourceError(res, resourceName, "Actions.TargetGroupArn", "MustBeRef", "ListenerRule.Actions.TargetGroupArn must be !Ref")
 			}
 		}
 	}
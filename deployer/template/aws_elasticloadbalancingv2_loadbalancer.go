@@ -24,25 +24,26 @@ func ValidateAWSElasticLoadBalancingV2LoadBalancer(
 	res.Tags = append(res.Tags, tags.Tag{Key: "ServiceName", Value: resourceName})
 
 	if res.Type != "application" {
-		return resourceError(res, resourceName, "Only application load balancers are supported")
+		return resourceError(res, resourceName, "Type", "UnsupportedValue", "Only application load balancers are supported")
 	}
 
 	// Ipv4 is the default, so empty is fine too
 	if res.IpAddressType != "" && res.IpAddressType != "ipv4" {
-		return resourceError(res, resourceName, "Only ipv4 load balancers are supported")
+		return resourceError(res, resourceName, "IpAddressType", "UnsupportedValue", "Only ipv4 load balancers are supported")
 	}
 
+	errs := ValidationErrors{}
 	if res.SecurityGroups != nil {
 		if err := ValidateLoadbalancerSecurityGroups(projectName, configName, resourceName, res, ec2c); err != nil {
-			return resourceError(res, resourceName, err.Error())
+			errs = append(errs, resourceError(res, resourceName, "SecurityGroups", "IncorrectTags", err.Error()))
 		}
 	}
 
 	if err := ValidateLoadbalancerSubnets(projectName, configName, resourceName, res, ec2c); err != nil {
-		return resourceError(res, resourceName, err.Error())
+		errs = append(errs, resourceError(res, resourceName, "Subnets", "IncorrectTags", err.Error()))
 	}
 
-	return nil
+	return errs.OrNil()
 }
 
 func ValidateLoadbalancerSecurityGroups(
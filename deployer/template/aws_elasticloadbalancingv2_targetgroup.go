@@ -26,7 +26,7 @@ func ValidateAWSElasticLoadBalancingV2TargetGroup(
 		// Currently only allow empty targets list - this allows ASGs to attach targets
 		// but no individual instances can be added.
 		if len(res.Targets) != 0 {
-			return resourceError(res, resourceName, "TargetGroup.Targets must be empty for TargetType instance")
+			return resourceError(res, resourceName, "Targets", "UnsupportedProperty", "TargetGroup.Targets must be empty for TargetType instance")
 		}
 
 		return nil
@@ -34,7 +34,7 @@ func ValidateAWSElasticLoadBalancingV2TargetGroup(
 
 	// Only allow lambda targets for now
 	if res.TargetType != "lambda" {
-		return resourceError(res, resourceName, "TargetGroup.TargetType must be lambda")
+		return resourceError(res, resourceName, "TargetType", "UnsupportedValue", "TargetGroup.TargetType must be lambda")
 	}
 
 	for _, target := range res.Targets {
@@ -44,11 +44,11 @@ func ValidateAWSElasticLoadBalancingV2TargetGroup(
 		if err != nil || len(args) != 2 || args[1] != "Arn" {
 			lambda, err := lambda.FindFunction(lambdac, target.Id)
 			if err != nil {
-				return resourceError(res, resourceName, "TargetGroup.Targets.Id must be \"!GetAtt <lambdaName>.Arn\" or a valid lambda ARN")
+				return resourceError(res, resourceName, "Targets.Id", "MustBeGetAtt", "TargetGroup.Targets.Id must be \"!GetAtt <lambdaName>.Arn\" or a valid lambda ARN")
 			}
 
 			if err := hasCorrectTags(projectName, configName, convTagMap(lambda.Tags)); err != nil {
-				return resourceError(res, resourceName, fmt.Sprintf("TargetGroup.Target %v", err.Error()))
+				return resourceError(res, resourceName, "Targets.Id", "IncorrectTags", fmt.Sprintf("TargetGroup.Target %v", err.Error()))
 			}
 		}
 	}
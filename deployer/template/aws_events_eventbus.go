@@ -0,0 +1,91 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/events"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/eb"
+	"github.com/coinbase/step/utils/to"
+)
+
+// AWS::Events::EventBus
+
+func ValidateAWSEventsEventBus(
+	projectName, configName, resourceName string,
+	template *cloudformation.Template,
+	res *events.EventBus,
+) error {
+
+	if res.Name != "" {
+		return resourceError(res, resourceName, "Name", "NameOverwritten", "Names are overwritten")
+	}
+
+	res.Name = normalizeName("fenrir", projectName, configName, resourceName, 256)
+
+	if res.EventSourceName != "" {
+		return resourceError(res, resourceName, "EventSourceName", "UnsupportedProperty", "EventSourceName partner event sources not supported")
+	}
+
+	return nil
+}
+
+// ValidateEventBusName allows the default bus, a bus in the template or a bus with the correct tags.
+// A bus in another account must be explicitly tagged FenrirAllowed:<project>:<config>
+func ValidateEventBusName(
+	template *cloudformation.Template,
+	projectName, configName, accountId, name string,
+	ebc aws.EBAPI,
+) error {
+	if name == "" || name == "default" {
+		return nil
+	}
+
+	if ref, err := decodeRef(name); err == nil {
+		if res, ok := template.Resources[ref]; !ok || res.AWSCloudFormationType() != "AWS::Events::EventBus" {
+			return fmt.Errorf("EventBusName !Ref %v is not an AWS::Events::EventBus in the template", ref)
+		}
+		return nil
+	}
+
+	if isIntrinsic(name) {
+		return fmt.Errorf("EventBusName must be !Ref, a name or an ARN")
+	}
+
+	if _, account, _ := to.ArnRegionAccountResource(name); account != "" && account != accountId {
+		bus, err := eb.FindEventBus(ebc, name)
+		if err != nil {
+			return fmt.Errorf("EventBusName %v %v", name, err.Error())
+		}
+
+		if bus.Tags[fmt.Sprintf("FenrirAllowed:%v:%v", projectName, configName)] != "true" {
+			return fmt.Errorf("EventBusName %v in account %v must be tagged FenrirAllowed:%v:%v", name, account, projectName, configName)
+		}
+
+		return nil
+	}
+
+	bus, err := eb.FindEventBus(ebc, name)
+	if err != nil {
+		return fmt.Errorf("EventBusName %v %v", name, err.Error())
+	}
+
+	if err := hasCorrectTags(projectName, configName, bus.Tags); err != nil {
+		return fmt.Errorf("EventBusName %v %v", name, err.Error())
+	}
+
+	return nil
+}
+
+// isSharedBus is true unless name is a !Ref to a bus created in the template,
+// other buses can receive events from anyone in the account
+func isSharedBus(template *cloudformation.Template, name string) bool {
+	ref, err := decodeRef(name)
+	if err != nil {
+		return true
+	}
+
+	res, ok := template.Resources[ref]
+	return !ok || res.AWSCloudFormationType() != "AWS::Events::EventBus"
+}
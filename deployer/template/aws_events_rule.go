@@ -0,0 +1,126 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/events"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/iam"
+	"github.com/coinbase/step/utils/to"
+)
+
+// AWS::Events::Rule
+
+func ValidateAWSEventsRule(
+	projectName, configName, accountId, resourceName string,
+	template *cloudformation.Template,
+	res *events.Rule,
+	limits Limits,
+	iamc aws.IAMAPI,
+	ebc aws.EBAPI,
+) error {
+
+	if res.Name != "" {
+		return resourceError(res, resourceName, "Name", "NameOverwritten", "Names are overwritten")
+	}
+
+	res.Name = normalizeName("fenrir", projectName, configName, resourceName, 64)
+
+	if res.RoleArn != "" {
+		return resourceError(res, resourceName, "RoleArn", "UnsupportedProperty", "RoleArn not supported, use Targets RoleArn")
+	}
+
+	errs := ValidationErrors{}
+
+	if err := ValidateEventBusName(template, projectName, configName, accountId, res.EventBusName, ebc); err != nil {
+		errs = append(errs, resourceError(res, resourceName, "EventBusName", "IncorrectTags", err.Error()))
+	}
+
+	if res.ScheduleExpression == "" && res.EventPattern == nil {
+		errs = append(errs, resourceError(res, resourceName, "ScheduleExpression", "RequiredProperty", "ScheduleExpression or EventPattern required"))
+	}
+
+	if res.ScheduleExpression != "" {
+		if err := ValidateScheduleExpression(res.ScheduleExpression, limits); err != nil {
+			errs = append(errs, resourceError(res, resourceName, "ScheduleExpression", "InvalidSchedule", err.Error()))
+		}
+	}
+
+	if res.EventPattern != nil {
+		if err := ValidateEventPattern(res.EventPattern, isSharedBus(template, res.EventBusName), limits); err != nil {
+			errs = append(errs, resourceError(res, resourceName, "EventPattern", "InvalidPattern", err.Error()))
+		}
+	}
+
+	if len(res.Targets) == 0 {
+		errs = append(errs, resourceError(res, resourceName, "Targets", "RequiredProperty", "Targets required"))
+	}
+
+	for i, target := range res.Targets {
+		path := fmt.Sprintf("Targets[%v]", i)
+
+		if err := validateRuleTarget(template, target.Arn); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path+".Arn", "UnsupportedTarget", fmt.Sprintf("%v.Arn %v", path, err.Error())))
+		}
+
+		if err := ValidateEventInput(target.Input, target.InputPath); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path+".Input", "InvalidInput", fmt.Sprintf("%v %v", path, err.Error())))
+		}
+
+		if target.RoleArn == "" {
+			continue
+		}
+
+		// State machine targets need a role to start executions
+		role, err := iam.GetRole(iamc, &target.RoleArn)
+		if err != nil {
+			errs = append(errs, resourceError(res, resourceName, path+".RoleArn", "ResourceNotFound", fmt.Sprintf("%v.RoleArn %v %v", path, target.RoleArn, err.Error())))
+			continue
+		}
+
+		res.Targets[i].RoleArn = to.Strs(role.Arn)
+
+		if err := ValidateResource("Role", projectName, configName, resourceName, role); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path+".RoleArn", "IncorrectTags", err.Error()))
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// validateRuleTarget allows the ARN of a function, queue, topic or state machine in the template
+func validateRuleTarget(template *cloudformation.Template, arn string) error {
+	// The intrinsic that returns the ARN of each resource type
+	arnIntrinsics := map[string]string{
+		"AWS::Serverless::Function":     "!GetAtt <name>.Arn",
+		"AWS::SQS::Queue":               "!GetAtt <name>.Arn",
+		"AWS::SNS::Topic":               "!Ref <name>",
+		"AWS::Serverless::StateMachine": "!Ref <name>",
+	}
+
+	name, intrinsic := "", ""
+	if ref, err := decodeRef(arn); err == nil {
+		name, intrinsic = ref, "!Ref <name>"
+	} else if getAtt, err := decodeGetAtt(arn); err == nil && len(getAtt) == 2 && getAtt[1] == "Arn" {
+		name, intrinsic = getAtt[0], "!GetAtt <name>.Arn"
+	} else {
+		return fmt.Errorf("must be a function, queue, topic or state machine in the template")
+	}
+
+	res, ok := template.Resources[name]
+	if !ok {
+		return fmt.Errorf("%v is not in the template", name)
+	}
+
+	expected, ok := arnIntrinsics[res.AWSCloudFormationType()]
+	if !ok {
+		return fmt.Errorf("%v type %v is not a supported target", name, res.AWSCloudFormationType())
+	}
+
+	if expected != intrinsic {
+		return fmt.Errorf("%v must be %v", name, expected)
+	}
+
+	return nil
+}
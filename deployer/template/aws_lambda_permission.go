@@ -11,7 +11,7 @@ func ValidateAWSLambdaPermission(
 	res *lambda.Permission,
 ) error {
 	if res.Action != "lambda:InvokeFunction" {
-		return resourceError(res, resourceName, "Lambda::Permission.Action must be lambda:InvokeFunction")
+		return resourceError(res, resourceName, "Action", "UnsupportedValue", "Lambda::Permission.Action must be lambda:InvokeFunction")
 	}
 
 	allowedPrincipals := []string{
@@ -22,12 +22,12 @@ func ValidateAWSLambdaPermission(
 	}
 
 	if !(inSlice(res.Principal, allowedPrincipals)) {
-		return resourceError(res, resourceName, res.Principal+" is not a currently supported value for Lambda::Permission.Principal")
+		return resourceError(res, resourceName, "Principal", "UnsupportedValue", res.Principal+" is not a currently supported value for Lambda::Permission.Principal")
 	}
 
 	args, err := decodeGetAtt(res.FunctionName)
 	if err != nil || len(args) != 2 || args[1] != "Arn" {
-		return resourceError(res, resourceName, "Lambda::Permission.FunctionName must be \"!GetAtt <lambdaName> Arn\"")
+		return resourceError(res, resourceName, "FunctionName", "MustBeGetAtt", "Lambda::Permission.FunctionName must be \"!GetAtt <lambdaName> Arn\"")
 	}
 
 	return nil
@@ -0,0 +1,131 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/logs"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/cwl"
+	"github.com/coinbase/fenrir/aws/kms"
+	"github.com/coinbase/step/utils/to"
+)
+
+// AWS::Logs::LogGroup
+
+// retentionDays are the RetentionInDays values CloudWatch Logs accepts
+var retentionDays = []int{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1827, 3653}
+
+// FunctionLogGroupName is the log group Lambda writes the functions logs to
+func FunctionLogGroupName(projectName, configName, resourceName string) string {
+	return "/aws/lambda/" + normalizeName("fenrir", projectName, configName, resourceName, 64)
+}
+
+// AddFunctionLogGroups adds a log group to the template for each function that does not declare one.
+// managed are the log groups already in the stack, a log group that exists outside the stack
+// was created by Lambda and cannot be created again, so it is skipped
+func AddFunctionLogGroups(
+	projectName, configName string,
+	template *cloudformation.Template,
+	managed map[string]bool,
+	cwlc aws.CWLAPI,
+) error {
+
+	declared := map[string]bool{}
+	for _, group := range template.GetAllLogsLogGroupResources() {
+		declared[group.LogGroupName] = true
+	}
+
+	names := []string{}
+	for name := range template.GetAllServerlessFunctionResources() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		groupName := FunctionLogGroupName(projectName, configName, name)
+		if declared[groupName] {
+			continue
+		}
+
+		if !managed[groupName] {
+			exists, err := cwl.LogGroupExists(cwlc, to.Strp(groupName))
+			if err != nil {
+				return fmt.Errorf("LogGroup %v %v", groupName, err.Error())
+			}
+
+			if exists {
+				continue
+			}
+		}
+
+		logicalID := name + "LogGroup"
+		if _, ok := template.Resources[logicalID]; ok {
+			return fmt.Errorf("Cannot add LogGroup for %v, %v already exists", name, logicalID)
+		}
+
+		template.Resources[logicalID] = &logs.LogGroup{LogGroupName: groupName}
+	}
+
+	return nil
+}
+
+func ValidateAWSLogsLogGroup(
+	projectName, configName, resourceName string,
+	template *cloudformation.Template,
+	res *logs.LogGroup,
+	limits Limits,
+	kmsc aws.KMSAPI,
+) error {
+
+	// Log groups can only be for the functions in the template
+	if !isFunctionLogGroup(projectName, configName, template, res.LogGroupName) {
+		return resourceError(res, resourceName, "LogGroupName", "InvalidName", fmt.Sprintf("LogGroupName %q must be /aws/lambda/<FunctionName> of a function in the template", res.LogGroupName))
+	}
+
+	if res.RetentionInDays == 0 {
+		res.RetentionInDays = limits.LogRetentionInDays
+	}
+
+	if !inIntSlice(res.RetentionInDays, retentionDays) {
+		return resourceError(res, resourceName, "RetentionInDays", "InvalidRetention", fmt.Sprintf("RetentionInDays %v must be one of %v", res.RetentionInDays, retentionDays))
+	}
+
+	if res.KmsKeyId != "" {
+		key, err := kms.FindKey(kmsc, res.KmsKeyId)
+		if err != nil {
+			return resourceError(res, resourceName, "KmsKeyId", "ResourceNotFound", fmt.Sprintf("KmsKeyId %v", err.Error()))
+		}
+
+		if err := hasCorrectTags(projectName, configName, key.Tags); err != nil {
+			return resourceError(res, resourceName, "KmsKeyId", "IncorrectTags", fmt.Sprintf("KmsKeyId %v", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func isFunctionLogGroup(projectName, configName string, template *cloudformation.Template, groupName string) bool {
+	if !strings.HasPrefix(groupName, "/aws/lambda/") {
+		return false
+	}
+
+	for name := range template.GetAllServerlessFunctionResources() {
+		if FunctionLogGroupName(projectName, configName, name) == groupName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func inIntSlice(value int, slice []int) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
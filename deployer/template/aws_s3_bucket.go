@@ -0,0 +1,190 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/policies"
+	"github.com/awslabs/goformation/v4/cloudformation/s3"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/kms"
+)
+
+// AWS::S3::Bucket
+
+func ValidateAWSS3Bucket(
+	projectName, configName, region, accountId, resourceName string,
+	template *cloudformation.Template,
+	res *s3.Bucket,
+	iamc aws.IAMAPI,
+	s3c aws.S3API,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+) error {
+
+	if res.AWSCloudFormationDeletionPolicy == "" {
+		res.AWSCloudFormationDeletionPolicy = policies.DeletionPolicy("Retain")
+	}
+
+	if res.AWSCloudFormationUpdateReplacePolicy == "" {
+		res.AWSCloudFormationUpdateReplacePolicy = policies.UpdateReplacePolicy("Retain")
+	}
+
+	if res.BucketName != "" {
+		return resourceError(res, resourceName, "BucketName", "NameOverwritten", "Names are overwritten")
+	}
+
+	// Bucket names are global so include the account, and can only be lowercase
+	res.BucketName = bucketName(projectName, configName, accountId, resourceName)
+
+	res.Tags = forceTags(res.Tags, projectName, configName, resourceName)
+
+	if res.AccessControl != "" && res.AccessControl != "Private" {
+		return resourceError(res, resourceName, "AccessControl", "UnsupportedValue", fmt.Sprintf("AccessControl %v not supported, only Private", res.AccessControl))
+	}
+
+	res.PublicAccessBlockConfiguration = &s3.Bucket_PublicAccessBlockConfiguration{
+		BlockPublicAcls:       true,
+		BlockPublicPolicy:     true,
+		IgnorePublicAcls:      true,
+		RestrictPublicBuckets: true,
+	}
+
+	if res.BucketEncryption == nil || len(res.BucketEncryption.ServerSideEncryptionConfiguration) == 0 {
+		res.BucketEncryption = &s3.Bucket_BucketEncryption{
+			ServerSideEncryptionConfiguration: []s3.Bucket_ServerSideEncryptionRule{
+				{ServerSideEncryptionByDefault: &s3.Bucket_ServerSideEncryptionByDefault{SSEAlgorithm: "AES256"}},
+			},
+		}
+	}
+
+	for i, rule := range res.BucketEncryption.ServerSideEncryptionConfiguration {
+		path := fmt.Sprintf("BucketEncryption.ServerSideEncryptionConfiguration[%v].ServerSideEncryptionByDefault", i)
+		sse := rule.ServerSideEncryptionByDefault
+		if sse == nil {
+			return resourceError(res, resourceName, path, "RequiredProperty", fmt.Sprintf("%v required", path))
+		}
+
+		if sse.SSEAlgorithm != "AES256" && sse.SSEAlgorithm != "aws:kms" {
+			return resourceError(res, resourceName, path, "UnsupportedValue", fmt.Sprintf("%v.SSEAlgorithm must be AES256 or aws:kms", path))
+		}
+
+		if sse.KMSMasterKeyID == "" {
+			continue
+		}
+
+		key, err := kms.FindKey(kmsc, sse.KMSMasterKeyID)
+		if err != nil {
+			return resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v.KMSMasterKeyID %v", path, err.Error()))
+		}
+
+		if err := hasCorrectTags(projectName, configName, key.Tags); err != nil {
+			return resourceError(res, resourceName, path, "IncorrectTags", fmt.Sprintf("%v.KMSMasterKeyID %v", path, err.Error()))
+		}
+	}
+
+	return validateBucketTargets(projectName, configName, region, accountId, resourceName, template, res, iamc, s3c, sqsc, snsc)
+}
+
+// validateBucketTargets checks the buckets, functions, queues and topics a bucket replicates, logs or notifies to
+// are in the template or have the correct tags
+func validateBucketTargets(
+	projectName, configName, region, accountId, resourceName string,
+	template *cloudformation.Template,
+	res *s3.Bucket,
+	iamc aws.IAMAPI,
+	s3c aws.S3API,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+) error {
+	errs := ValidationErrors{}
+
+	// target must be a !Ref or !GetAtt of a resource in the template of resourceType,
+	// or, if arnFormat is set, the name or ARN of a resource with the correct tags
+	target := func(path, value, arnFormat, resourceType string) {
+		name := ""
+		if ref, err := decodeRef(value); err == nil {
+			name = ref
+		} else if getAtt, err := decodeGetAtt(value); err == nil {
+			name = getAtt[0]
+		} else if value == "" || isIntrinsic(value) {
+			errs = append(errs, resourceError(res, resourceName, path, "MustBeRef", fmt.Sprintf("%v must be !Ref, !GetAtt or an ARN", path)))
+			return
+		}
+
+		if name != "" {
+			if local, ok := template.Resources[name]; !ok || local.AWSCloudFormationType() != resourceType {
+				errs = append(errs, resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v %v is not an %v in the template", path, name, resourceType)))
+			}
+			return
+		}
+
+		if arnFormat == "" {
+			errs = append(errs, resourceError(res, resourceName, path, "MustBeRef", fmt.Sprintf("%v must be !Ref or !GetAtt", path)))
+			return
+		}
+
+		arn := value
+		if !strings.HasPrefix(arn, "arn:") {
+			arn = fmt.Sprintf(arnFormat, region, accountId, value)
+		}
+
+		tags, err := arnTags(arn, s3c, nil, nil, sqsc, snsc, nil, nil, nil, nil, nil)
+		if err != nil {
+			errs = append(errs, resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v %v %v", path, value, err.Error())))
+			return
+		}
+
+		if err := hasCorrectTags(projectName, configName, tags); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path, "IncorrectTags", fmt.Sprintf("%v %v %v", path, value, err.Error())))
+		}
+	}
+
+	if r := res.ReplicationConfiguration; r != nil {
+		if err := validateRole(res, projectName, configName, resourceName, "ReplicationConfiguration.Role", &r.Role, iamc); err != nil {
+			errs = append(errs, err)
+		}
+
+		for i, rule := range r.Rules {
+			path := fmt.Sprintf("ReplicationConfiguration.Rules[%v].Destination", i)
+			if rule.Destination == nil {
+				errs = append(errs, resourceError(res, resourceName, path, "RequiredProperty", fmt.Sprintf("%v required", path)))
+				continue
+			}
+
+			// Changing the owner of the replicas would let another account control them
+			if rule.Destination.Account != "" || rule.Destination.AccessControlTranslation != nil {
+				errs = append(errs, resourceError(res, resourceName, path, "UnsupportedProperty", fmt.Sprintf("%v Account and AccessControlTranslation not supported", path)))
+			}
+
+			target(fmt.Sprintf("%v.Bucket", path), rule.Destination.Bucket, "arn:aws:s3:::%[3]s", "AWS::S3::Bucket")
+		}
+	}
+
+	if n := res.NotificationConfiguration; n != nil {
+		for i, c := range n.LambdaConfigurations {
+			target(fmt.Sprintf("NotificationConfiguration.LambdaConfigurations[%v].Function", i), c.Function, "", "AWS::Serverless::Function")
+		}
+
+		for i, c := range n.QueueConfigurations {
+			target(fmt.Sprintf("NotificationConfiguration.QueueConfigurations[%v].Queue", i), c.Queue, "arn:aws:sqs:%s:%s:%s", "AWS::SQS::Queue")
+		}
+
+		for i, c := range n.TopicConfigurations {
+			target(fmt.Sprintf("NotificationConfiguration.TopicConfigurations[%v].Topic", i), c.Topic, "arn:aws:sns:%s:%s:%s", "AWS::SNS::Topic")
+		}
+	}
+
+	if l := res.LoggingConfiguration; l != nil && l.DestinationBucketName != "" {
+		target("LoggingConfiguration.DestinationBucketName", l.DestinationBucketName, "arn:aws:s3:::%[3]s", "AWS::S3::Bucket")
+	}
+
+	return errs.OrNil()
+}
+
+func bucketName(projectName, configName, accountId, resourceName string) string {
+	name := normalizeName(fmt.Sprintf("fenrir-%v", accountId), projectName, configName, resourceName, 63)
+	return strings.Replace(strings.ToLower(name), "_", "-", -1)
+}
@@ -15,7 +15,7 @@ func ValidateAWSServerlessApi(
 ) error {
 
 	if res.Name != "" {
-		return resourceError(res, resourceName, "Names are overwritten")
+		return resourceError(res, resourceName, "Name", "NameOverwritten", "Names are overwritten")
 	}
 
 	res.Name = normalizeName("fenrir", projectName, configName, resourceName, 128)
@@ -26,20 +26,20 @@ func ValidateAWSServerlessApi(
 	}
 
 	if res.EndpointConfiguration != "REGIONAL" && res.EndpointConfiguration != "EDGE" && res.EndpointConfiguration != "PRIVATE" {
-		return resourceError(res, resourceName, "EndpointConfiguration must equal either REGIONAL EDGE PRIVATE")
+		return resourceError(res, resourceName, "EndpointConfiguration", "UnsupportedValue", "EndpointConfiguration must equal either REGIONAL EDGE PRIVATE")
 	}
 
 	if res.DefinitionUri != nil {
 		if res.DefinitionUri.S3Location != nil {
-			return resourceError(res, resourceName, "DefinitionUri.S3Location not supported")
+			return resourceError(res, resourceName, "DefinitionUri", "UnsupportedProperty", "DefinitionUri.S3Location not supported")
 		}
 		if res.DefinitionUri.String == nil {
-			return resourceError(res, resourceName, "DefinitionUri nil")
+			return resourceError(res, resourceName, "DefinitionUri", "RequiredProperty", "DefinitionUri nil")
 		}
 
 		s3URI := *res.DefinitionUri.String
 		if _, ok := s3shas[s3URI]; !ok {
-			return resourceError(res, resourceName, fmt.Sprintf("DefinitionUri %v not included in the SHA256s map", s3URI))
+			return resourceError(res, resourceName, "DefinitionUri", "MissingArtifact", fmt.Sprintf("DefinitionUri %v not included in the SHA256s map", s3URI))
 		}
 	}
 
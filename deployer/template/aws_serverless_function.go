@@ -2,10 +2,14 @@ package template
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/cloudformation/serverless"
 	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/ecr"
 	"github.com/coinbase/fenrir/aws/iam"
 	"github.com/coinbase/fenrir/aws/kms"
 	"github.com/coinbase/fenrir/aws/sg"
@@ -18,6 +22,8 @@ func ValidateAWSServerlessFunction(
 	template *cloudformation.Template,
 	fun *serverless.Function,
 	s3shas map[string]string,
+	imageDigests map[string]string,
+	limits Limits,
 	iamc aws.IAMAPI,
 	ec2c aws.EC2API,
 	s3c aws.S3API,
@@ -27,10 +33,15 @@ func ValidateAWSServerlessFunction(
 	snsc aws.SNSAPI,
 	kmsc aws.KMSAPI,
 	cwlc aws.CWLAPI,
+	ebc aws.EBAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+	ecrc aws.ECRAPI,
 ) error {
 
 	if fun.FunctionName != "" {
-		return resourceError(fun, resourceName, fmt.Sprintf("Names are overwritten, it is %v", fun.FunctionName))
+		return resourceError(fun, resourceName, "FunctionName", "NameOverwritten", fmt.Sprintf("Names are overwritten, it is %v", fun.FunctionName))
 	}
 
 	// Forces Lambda name for no conflicts
@@ -44,130 +55,473 @@ func ValidateAWSServerlessFunction(
 	fun.Tags["ConfigName"] = configName
 	fun.Tags["ServiceName"] = resourceName
 
-	if err := ValidateFunctionIAM(projectName, configName, accountId, resourceName, fun, iamc, kmsc); err != nil {
-		return err
+	// Runtime, IAM, VPC, Events and the package are independent so report all their errors
+	errs := ValidationErrors{}
+
+	// Container images have no Runtime, their package is checked instead
+	if !IsImageFunction(fun) {
+		warning, err := ValidateRuntime(fun.Runtime, time.Now())
+		if err != nil {
+			errs = append(errs, resourceError(fun, resourceName, "Runtime", "UnsupportedRuntime", err.Error()))
+		} else if warning != "" {
+			errs = append(errs, resourceWarning(fun, resourceName, "Runtime", "DeprecatedRuntime", warning))
+		}
+	}
+
+	if passthrough, _ := GetPassthrough(fun.AWSCloudFormationMetadata); passthrough != nil && passthrough.Architectures != nil {
+		if err := ValidateArchitectures(passthrough.Architectures); err != nil {
+			errs = append(errs, resourceError(fun, resourceName, "Architectures", "UnsupportedArchitecture", err.Error()))
+		}
+	}
+
+	switch err := ValidateFunctionIAM(template, projectName, configName, region, accountId, resourceName, fun, iamc, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc).(type) {
+	case nil:
+	case ValidationErrors:
+		errs = append(errs, err...)
+	default:
+		errs = append(errs, err)
 	}
 
 	if fun.VpcConfig != nil {
 		if err := ValidateVPCConfig(projectName, configName, resourceName, fun, ec2c); err != nil {
-			return resourceError(fun, resourceName, err.Error())
+			errs = append(errs, resourceError(fun, resourceName, "VpcConfig", "IncorrectTags", err.Error()))
 		}
 	}
 
-	if err := ValidateFunctionEvents(template, projectName, configName, region, accountId, resourceName, fun, s3c, kinc, ddbc, sqsc, snsc, cwlc); err != nil {
-		return err
+	switch err := ValidateFunctionEvents(template, projectName, configName, region, accountId, resourceName, fun, limits, s3c, kinc, ddbc, sqsc, snsc, cwlc, ebc).(type) {
+	case nil:
+	case ValidationErrors:
+		errs = append(errs, err...)
+	default:
+		errs = append(errs, err)
+	}
+
+	switch err := ValidateFunctionPackage(projectName, configName, region, accountId, resourceName, fun, s3shas, imageDigests, ecrc).(type) {
+	case nil:
+	case ValidationErrors:
+		errs = append(errs, err...)
+	default:
+		errs = append(errs, err)
+	}
+
+	return errs.OrNil()
+}
+
+// IsImageFunction is true if the function is a container image, PackageType Image
+func IsImageFunction(fun *serverless.Function) bool {
+	passthrough, _ := GetPassthrough(fun.AWSCloudFormationMetadata)
+	return passthrough != nil && passthrough.PackageType == "Image"
+}
+
+// ValidateFunctionPackage checks the CodeUri zip was uploaded with the release,
+// or the container ImageUri is pinned by a digest recorded on the release
+func ValidateFunctionPackage(
+	projectName, configName, region, accountId, resourceName string,
+	fun *serverless.Function,
+	s3shas map[string]string,
+	imageDigests map[string]string,
+	ecrc aws.ECRAPI,
+) error {
+	// An invalid passthrough is reported by ValidateFunctionIAM
+	passthrough, _ := GetPassthrough(fun.AWSCloudFormationMetadata)
+	if passthrough == nil {
+		passthrough = &Passthrough{}
 	}
 
-	// CodeURI checking
-	if fun.CodeUri != nil {
-		if fun.CodeUri.S3Location != nil {
-			return resourceError(fun, resourceName, "CodeUri.S3Location not supported")
+	errs := ValidationErrors{}
+
+	switch passthrough.PackageType {
+	case "", "Zip":
+		if passthrough.ImageUri != "" || passthrough.ImageConfig != nil {
+			errs = append(errs, resourceError(fun, resourceName, "PackageType", "UnsupportedProperty", "ImageUri and ImageConfig require PackageType Image"))
+		}
+
+		// CodeURI checking
+		if fun.CodeUri != nil {
+			if fun.CodeUri.S3Location != nil {
+				errs = append(errs, resourceError(fun, resourceName, "CodeUri", "UnsupportedProperty", "CodeUri.S3Location not supported"))
+			} else if fun.CodeUri.String == nil {
+				errs = append(errs, resourceError(fun, resourceName, "CodeUri", "RequiredProperty", "CodeUri nil"))
+			} else if _, ok := s3shas[*fun.CodeUri.String]; !ok {
+				errs = append(errs, resourceError(fun, resourceName, "CodeUri", "MissingArtifact", fmt.Sprintf("CodeUri %v not included in the SHA256s map", *fun.CodeUri.String)))
+			}
+		}
+	case "Image":
+		// The image has the code and runtime
+		unsupported := []string{}
+		if fun.Runtime != "" {
+			unsupported = append(unsupported, "Runtime")
+		}
+
+		if fun.Handler != "" {
+			unsupported = append(unsupported, "Handler")
 		}
 
-		if fun.CodeUri.String == nil {
-			return resourceError(fun, resourceName, "CodeUri nil")
+		if fun.CodeUri != nil {
+			unsupported = append(unsupported, "CodeUri")
 		}
 
-		s3URI := *fun.CodeUri.String
-		if _, ok := s3shas[s3URI]; !ok {
-			return fmt.Errorf("CodeUri %v not included in the SHA256s map", s3URI)
+		if len(fun.Layers) > 0 {
+			unsupported = append(unsupported, "Layers")
 		}
+
+		if len(unsupported) > 0 {
+			errs = append(errs, resourceError(fun, resourceName, "PackageType", "UnsupportedProperty", fmt.Sprintf("%v not supported with PackageType Image", strings.Join(unsupported, ", "))))
+		}
+
+		if err := validateImageUri(projectName, configName, region, accountId, resourceName, fun, passthrough.ImageUri, imageDigests, ecrc); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, resourceError(fun, resourceName, "PackageType", "UnsupportedProperty", fmt.Sprintf("PackageType %q must be Zip or Image", passthrough.PackageType)))
+	}
+
+	return errs.OrNil()
+}
+
+// validateImageUri checks the image is in a correctly tagged repository of the account
+// and is pinned by a digest in the releases image digests
+func validateImageUri(
+	projectName, configName, region, accountId, resourceName string,
+	fun *serverless.Function,
+	imageUri string,
+	imageDigests map[string]string,
+	ecrc aws.ECRAPI,
+) error {
+	if imageUri == "" {
+		return resourceError(fun, resourceName, "ImageUri", "RequiredProperty", "ImageUri required with PackageType Image")
+	}
+
+	uri, err := ecr.ParseImageURI(imageUri)
+	if err != nil || uri.Digest == "" {
+		return resourceError(fun, resourceName, "ImageUri", "MustBePinned", fmt.Sprintf("ImageUri %v must be an ECR image pinned by @sha256: digest", imageUri))
+	}
+
+	if uri.RegistryID != accountId || uri.Region != region {
+		return resourceError(fun, resourceName, "ImageUri", "IncorrectRepository", fmt.Sprintf("ImageUri %v must be an ECR repository in %v %v", imageUri, region, accountId))
+	}
+
+	if imageDigests[imageUri] != uri.Digest {
+		return resourceError(fun, resourceName, "ImageUri", "MissingArtifact", fmt.Sprintf("ImageUri %v not included in the image digests map", imageUri))
+	}
+
+	image, err := ecr.FindImage(ecrc, uri)
+	if err != nil {
+		return resourceError(fun, resourceName, "ImageUri", "ResourceNotFound", fmt.Sprintf("ImageUri %v %v", imageUri, err.Error()))
+	}
+
+	if err := hasCorrectTags(projectName, configName, image.Tags); err != nil {
+		return resourceError(fun, resourceName, "ImageUri", "IncorrectTags", fmt.Sprintf("ImageUri repository %v %v", uri.Repository, err.Error()))
 	}
 
 	return nil
 }
 
 func ValidateFunctionIAM(
-	projectName, configName, accountId, resourceName string,
+	template *cloudformation.Template,
+	projectName, configName, region, accountId, resourceName string,
 	fun *serverless.Function,
 	iamc aws.IAMAPI,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
 	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
 ) error {
 	// IAM VALIDATIONS
 	// Either Role XOR Policies
 
-	if fun.Role != "" && fun.Policies == nil {
+	// Policy templates goformation does not have are passed through the Metadata
+	passthrough, err := GetPassthrough(fun.AWSCloudFormationMetadata)
+	if err != nil {
+		return resourceError(fun, resourceName, "Metadata", "InvalidPassthrough", err.Error())
+	}
+
+	stashed := []PassthroughPolicy{}
+	if passthrough != nil {
+		stashed = passthrough.Policies
+	}
+
+	hasPolicies := fun.Policies != nil || len(stashed) > 0
+
+	if fun.Role != "" && !hasPolicies {
 
 		// Role Must be Name and NOT intrinsic
 		// We make sure it exists and has the correct tags
-		role, err := iam.GetRole(iamc, &fun.Role)
-		if err != nil {
-			return resourceError(fun, resourceName, fmt.Sprintf("%v %v", fun.Role, err.Error()))
+		if err := validateRole(fun, projectName, configName, resourceName, "Role", &fun.Role, iamc); err != nil {
+			return err
 		}
 
-		fun.Role = to.Strs(role.Arn)
+	} else if fun.Role == "" && hasPolicies {
+		fun.PermissionsBoundary = permissionsBoundary(accountId)
+		return validatePolicies(template, projectName, configName, region, accountId, resourceName, fun, fun.Policies, stashed, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+	} else {
+		return resourceError(fun, resourceName, "Role", "RoleXorPolicies", "Must define either Role or Policies, not both")
+	}
+
+	return nil
+}
+
+// permissionsBoundary is the fenrir-permissions-boundary policy of the account, set on roles SAM creates from Policies
+func permissionsBoundary(accountId string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:policy/fenrir-permissions-boundary", accountId)
+}
+
+// validatePolicies checks the SAM policy templates or policy documents of res, with stashed the passthrough policy templates
+func validatePolicies(
+	template *cloudformation.Template,
+	projectName, configName, region, accountId, resourceName string,
+	res cloudformation.Resource,
+	policies *serverless.Function_Policies,
+	stashed []PassthroughPolicy,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) error {
+	if policies == nil {
+		policies = &serverless.Function_Policies{}
+	}
+
+	if policies.String != nil {
+		return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", "Policies: only support SAMPolicyTemplateArray or policy documents")
+	}
 
-		if err := ValidateResource("Role", projectName, configName, resourceName, role); err != nil {
-			return resourceError(fun, resourceName, err.Error())
+	// A single policy document
+	if policies.IAMPolicyDocument != nil {
+		if len(stashed) > 0 {
+			return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", "Policies: cannot mix SAMPolicyTemplates and policy documents")
 		}
 
-	} else if fun.Role == "" && fun.Policies != nil {
-		fun.PermissionsBoundary = fmt.Sprintf("arn:aws:iam::%s:policy/fenrir-permissions-boundary", accountId)
-		policies := fun.Policies
-		if policies.String != nil ||
-			policies.IAMPolicyDocument != nil {
-			return resourceError(fun, resourceName, "Policies: only support SAMPolicyTemplateArray")
+		return ValidateFunctionPolicyDocuments(template, projectName, configName, resourceName, res, []serverless.Function_IAMPolicyDocument{*policies.IAMPolicyDocument}, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+	}
+
+	// Arrays are a bit annoying because they contain the zero values
+	if policies.StringArray != nil {
+		for _, s := range *policies.StringArray {
+			if s != "" {
+				return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", fmt.Sprintf("Policies: only support SAMPolicyTemplateArray not StringArray with %q", s))
+			}
 		}
+	}
 
-		// Arrays are a bit annoying because they contain the zero values
-		if policies.StringArray != nil {
-			for _, s := range *policies.StringArray {
-				if s != "" {
-					return resourceError(fun, resourceName, fmt.Sprintf("Policies: only support SAMPolicyTemplateArray not StringArray with %q", s))
-				}
+	// Lists are unmarshalled into both arrays, an element with a Statement is a policy document
+	docs := []serverless.Function_IAMPolicyDocument{}
+	if policies.IAMPolicyDocumentArray != nil {
+		for _, i := range *policies.IAMPolicyDocumentArray {
+			if i.Statement != nil {
+				docs = append(docs, i)
 			}
 		}
+	}
+
+	if len(docs) > 0 {
+		// Only one of the arrays is marshalled so they cannot be mixed
+		if len(stashed) > 0 || policies.SAMPolicyTemplateArray != nil && len(docs) != len(*policies.SAMPolicyTemplateArray) {
+			return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", "Policies: cannot mix SAMPolicyTemplates and policy documents")
+		}
 
-		if policies.IAMPolicyDocumentArray != nil {
-			for _, i := range *policies.IAMPolicyDocumentArray {
-				if i.Statement != nil {
-					return resourceError(fun, resourceName, "Policies: only support SAMPolicyTemplateArray not IAMPolicyDocumentArray")
-				}
+		policies.StringArray = nil
+		policies.SAMPolicyTemplateArray = nil
+
+		return ValidateFunctionPolicyDocuments(template, projectName, configName, resourceName, res, docs, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+	}
+
+	policies.IAMPolicyDocumentArray = nil
+
+	samPolicies := []serverless.Function_SAMPolicyTemplate{}
+	if policies.SAMPolicyTemplateArray != nil {
+		samPolicies = *policies.SAMPolicyTemplateArray
+	}
+
+	if len(samPolicies) == 0 && len(stashed) == 0 {
+		return resourceError(res, resourceName, "Policies", "RequiredProperty", "Policies: SAMPolicyTemplateArray undefined")
+	}
+
+	// local must be a resource in the template of one of the types
+	local := func(path, intrinsic, name string, types []string) error {
+		localRes, ok := template.Resources[name]
+		if !ok {
+			return resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v %v %v is not in the template", path, intrinsic, name))
+		}
+
+		if !isType(localRes, types...) {
+			return resourceError(res, resourceName, path, "IncorrectType", fmt.Sprintf("%v %v %v is not a %v", path, intrinsic, name, strings.Join(types, " or ")))
+		}
+
+		return nil
+	}
+
+	// ref must be a !Ref to a resource in the template of one of the types
+	ref := func(policy, property, name string, types ...string) error {
+		path := fmt.Sprintf("Policies.%v.%v", policy, property)
+
+		ref, err := decodeRef(name)
+		if err != nil || ref == "" {
+			return resourceError(res, resourceName, path, "MustBeRef", fmt.Sprintf("%v must be !Ref", path))
+		}
+
+		return local(path, "!Ref", ref, types)
+	}
+
+	// target must be a local !Ref or !GetAtt of one of the types, or the name of a resource
+	// whose ARN (arnFormat with region, account and name) has the correct tags
+	target := func(policy, property, name, arnFormat string, types ...string) error {
+		path := fmt.Sprintf("Policies.%v.%v", policy, property)
+
+		if len(types) > 0 {
+			if ref, err := decodeRef(name); err == nil {
+				return local(path, "!Ref", ref, types)
+			}
+
+			if getAtt, err := decodeGetAtt(name); err == nil {
+				return local(path, "!GetAtt", getAtt[0], types)
 			}
 		}
 
-		if policies.SAMPolicyTemplateArray == nil || len(*policies.SAMPolicyTemplateArray) == 0 {
-			return resourceError(fun, resourceName, "Policies: SAMPolicyTemplateArray undefined")
+		if name == "" || isIntrinsic(name) {
+			if len(types) == 0 {
+				return resourceError(res, resourceName, path, "MustBeName", fmt.Sprintf("%v must be a literal name or ARN", path))
+			}
+			return resourceError(res, resourceName, path, "MustBeRef", fmt.Sprintf("%v must be !Ref, !GetAtt or a name", path))
 		}
 
-		for _, p := range *policies.SAMPolicyTemplateArray {
-			if p.DynamoDBCrudPolicy != nil {
-				ref, err := decodeRef(p.DynamoDBCrudPolicy.TableName)
-				if err != nil || ref == "" {
-					return resourceError(fun, resourceName, "Policies.DynamoDBCrudPolicy.TableName must be !Ref")
-				}
-			} else if p.SQSPollerPolicy != nil {
-				ref, err := decodeRef(p.SQSPollerPolicy.QueueName)
-				if err != nil || ref == "" {
-					return resourceError(fun, resourceName, "Policies.SQSPollerPolicy.QueueName must be !Ref")
-				}
-			} else if p.LambdaInvokePolicy != nil {
-				ref, err := decodeRef(p.LambdaInvokePolicy.FunctionName)
-				if err != nil || ref == "" {
-					return resourceError(fun, resourceName, "Policies.LambdaInvokePolicy.FunctionName must be !Ref")
-				}
-			} else if p.KMSDecryptPolicy != nil {
-				key, err := kms.FindKey(kmsc, p.KMSDecryptPolicy.KeyId)
-				if err != nil {
-					return resourceError(fun, resourceName, fmt.Sprintf("KMSDecryptPolicy %v", err.Error()))
-				}
+		tags, err := arnTags(fmt.Sprintf(arnFormat, region, accountId, name), s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+		if err != nil {
+			return resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v %v %v", path, name, err.Error()))
+		}
+
+		if err := hasCorrectTags(projectName, configName, tags); err != nil {
+			return resourceError(res, resourceName, path, "IncorrectTags", fmt.Sprintf("%v %v %v", path, name, err.Error()))
+		}
 
-				// Overwrite keyID to be Key Id (in cases where it was set to an alias)
-				p.KMSDecryptPolicy.KeyId = key.Id
+		return nil
+	}
 
-				err = hasCorrectTags(projectName, configName, key.Tags)
-				if err != nil {
-					return resourceError(fun, resourceName, fmt.Sprintf("KMSDecryptPolicy %v", err.Error()))
+	for _, p := range samPolicies {
+		if p.DynamoDBCrudPolicy != nil {
+			// SAM builds the table ARN from TableName, so a tables StreamArn is replaced with its !Ref
+			if getAtt, err := decodeGetAtt(p.DynamoDBCrudPolicy.TableName); err == nil && len(getAtt) == 2 && getAtt[1] == "StreamArn" {
+				if err := local("Policies.DynamoDBCrudPolicy.TableName", "!GetAtt", getAtt[0], []string{"AWS::DynamoDB::Table"}); err != nil {
+					return err
 				}
-			} else if p.VPCAccessPolicy != nil {
-				// All good
-			} else {
-				return resourceError(fun, resourceName, fmt.Sprintf("Policies: Unsupported SAMPolicyTemplate %s", to.CompactJSONStr(p)))
+				p.DynamoDBCrudPolicy.TableName = cloudformation.Ref(getAtt[0])
+			} else if err := ref("DynamoDBCrudPolicy", "TableName", p.DynamoDBCrudPolicy.TableName, tableTypes...); err != nil {
+				return err
+			}
+		} else if p.SQSPollerPolicy != nil {
+			if err := ref("SQSPollerPolicy", "QueueName", p.SQSPollerPolicy.QueueName, "AWS::SQS::Queue"); err != nil {
+				return err
+			}
+		} else if p.LambdaInvokePolicy != nil {
+			if err := ref("LambdaInvokePolicy", "FunctionName", p.LambdaInvokePolicy.FunctionName, "AWS::Serverless::Function"); err != nil {
+				return err
+			}
+		} else if p.KMSDecryptPolicy != nil {
+			key, err := kms.FindKey(kmsc, p.KMSDecryptPolicy.KeyId)
+			if err != nil {
+				return resourceError(res, resourceName, "Policies.KMSDecryptPolicy.KeyId", "ResourceNotFound", fmt.Sprintf("KMSDecryptPolicy %v", err.Error()))
+			}
+
+			// Overwrite keyID to be Key Id (in cases where it was set to an alias)
+			p.KMSDecryptPolicy.KeyId = key.Id
+
+			err = hasCorrectTags(projectName, configName, key.Tags)
+			if err != nil {
+				return resourceError(res, resourceName, "Policies.KMSDecryptPolicy.KeyId", "IncorrectTags", fmt.Sprintf("KMSDecryptPolicy %v", err.Error()))
 			}
+		} else if p.VPCAccessPolicy != nil {
+			// All good
+		} else if p.DynamoDBReadPolicy != nil {
+			if err := target("DynamoDBReadPolicy", "TableName", p.DynamoDBReadPolicy.TableName, "arn:aws:dynamodb:%s:%s:table/%s", tableTypes...); err != nil {
+				return err
+			}
+		} else if p.DynamoDBStreamReadPolicy != nil {
+			// StreamName is the stream label of the table
+			if err := target("DynamoDBStreamReadPolicy", "TableName", p.DynamoDBStreamReadPolicy.TableName, "arn:aws:dynamodb:%s:%s:table/%s", tableTypes...); err != nil {
+				return err
+			}
+		} else if p.SQSSendMessagePolicy != nil {
+			if err := target("SQSSendMessagePolicy", "QueueName", p.SQSSendMessagePolicy.QueueName, "arn:aws:sqs:%s:%s:%s", "AWS::SQS::Queue"); err != nil {
+				return err
+			}
+		} else if p.SNSPublishMessagePolicy != nil {
+			if err := target("SNSPublishMessagePolicy", "TopicName", p.SNSPublishMessagePolicy.TopicName, "arn:aws:sns:%s:%s:%s", "AWS::SNS::Topic"); err != nil {
+				return err
+			}
+		} else if p.S3ReadPolicy != nil {
+			if err := target("S3ReadPolicy", "BucketName", p.S3ReadPolicy.BucketName, "arn:aws:s3:::%[3]s", "AWS::S3::Bucket"); err != nil {
+				return err
+			}
+		} else if p.S3CrudPolicy != nil {
+			if err := target("S3CrudPolicy", "BucketName", p.S3CrudPolicy.BucketName, "arn:aws:s3:::%[3]s", "AWS::S3::Bucket"); err != nil {
+				return err
+			}
+		} else if p.KinesisStreamReadPolicy != nil {
+			if err := target("KinesisStreamReadPolicy", "StreamName", p.KinesisStreamReadPolicy.StreamName, "arn:aws:kinesis:%s:%s:stream/%s", "AWS::Kinesis::Stream"); err != nil {
+				return err
+			}
+		} else if p.StepFunctionsExecutionPolicy != nil {
+			if err := target("StepFunctionsExecutionPolicy", "StateMachineName", p.StepFunctionsExecutionPolicy.StateMachineName, "arn:aws:states:%s:%s:stateMachine:%s", "AWS::Serverless::StateMachine"); err != nil {
+				return err
+			}
+		} else {
+			return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", fmt.Sprintf("Policies: Unsupported SAMPolicyTemplate %s", to.CompactJSONStr(p)))
 		}
+	}
 
-	} else {
-		return resourceError(fun, resourceName, "Must define either Role or Policies, not both")
+	// Parameters and secrets cannot be in the template so they must be tagged
+	for _, p := range stashed {
+		if p.SSMParameterReadPolicy != nil {
+			// ParameterName has no leading slash, SAM adds it to the ARN
+			if err := target("SSMParameterReadPolicy", "ParameterName", p.SSMParameterReadPolicy.ParameterName, "arn:aws:ssm:%s:%s:parameter/%s"); err != nil {
+				return err
+			}
+		} else if p.SecretsManagerGetSecretValuePolicy != nil {
+			if err := target("SecretsManagerGetSecretValuePolicy", "SecretArn", p.SecretsManagerGetSecretValuePolicy.SecretArn, "%[3]s"); err != nil {
+				return err
+			}
+		} else {
+			return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", fmt.Sprintf("Policies: Unsupported SAMPolicyTemplate %s", to.CompactJSONStr(p)))
+		}
+	}
+
+	return nil
+}
+
+// validateRole checks the role, by name or ARN, exists with the correct tags and replaces it with its ARN
+func validateRole(
+	res cloudformation.Resource,
+	projectName, configName, resourceName, path string,
+	roleName *string,
+	iamc aws.IAMAPI,
+) error {
+	// arn:aws:iam::account:role/path/name
+	name := *roleName
+	if strings.HasPrefix(name, "arn:") {
+		name = name[strings.LastIndex(name, "/")+1:]
+	}
+
+	role, err := iam.GetRole(iamc, &name)
+	if err != nil {
+		return resourceError(res, resourceName, path, "ResourceNotFound", fmt.Sprintf("%v %v", *roleName, err.Error()))
+	}
+
+	*roleName = to.Strs(role.Arn)
+
+	if err := ValidateResource(path, projectName, configName, resourceName, role); err != nil {
+		return resourceError(res, resourceName, path, "IncorrectTags", err.Error())
 	}
 
 	return nil
@@ -177,59 +531,102 @@ func ValidateFunctionEvents(
 	template *cloudformation.Template,
 	projectName, configName, region, accountId, resourceName string,
 	fun *serverless.Function,
+	limits Limits,
 	s3c aws.S3API,
 	kinc aws.KINAPI,
 	ddbc aws.DDBAPI,
 	sqsc aws.SQSAPI,
 	snsc aws.SNSAPI,
 	cwlc aws.CWLAPI,
+	ebc aws.EBAPI,
 ) error {
 	// Support and Validate These Events
-	// S3 SNS Kinesis DynamoDB SQS Api Schedule CloudWatchEvent CloudWatchLogs IoTRule AlexaSkill
-	for eventName, event := range fun.Events {
+	// S3 SNS Kinesis DynamoDB SQS Api HttpApi Schedule CloudWatchEvent CloudWatchLogs IoTRule AlexaSkill
+	eventNames := []string{}
+	for eventName := range fun.Events {
+		eventNames = append(eventNames, eventName)
+	}
+	sort.Strings(eventNames)
+
+	errs := ValidationErrors{}
+	for _, eventName := range eventNames {
+		event := fun.Events[eventName]
+		path := fmt.Sprintf("Events.%v", eventName)
+
 		switch event.Type {
 		case "Api":
 			if err := ValidateAPIEvent(template, event.Properties.ApiEvent); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("API Event %q %v", eventName, err.Error()))
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("API Event %q %v", eventName, err.Error())))
 			}
 		case "S3":
-			if err := ValidateS3Event(projectName, configName, event.Properties.S3Event, s3c); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("S3 Event %q %v", eventName, err.Error()))
+			if err := ValidateS3Event(template, projectName, configName, event.Properties.S3Event, s3c); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("S3 Event %q %v", eventName, err.Error())))
 			}
 		case "Kinesis":
 			if err := ValidateKinesisEvent(projectName, configName, region, accountId, event.Properties.KinesisEvent, kinc); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("Kinesis Event %q %v", eventName, err.Error()))
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("Kinesis Event %q %v", eventName, err.Error())))
 			}
 		case "DynamoDB":
-			if err := ValidateDynamoDBEvent(projectName, configName, event.Properties.DynamoDBEvent, ddbc); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("DynamoDB Event %q %v", eventName, err.Error()))
+			if err := ValidateDynamoDBEvent(template, projectName, configName, event.Properties.DynamoDBEvent, ddbc); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("DynamoDB Event %q %v", eventName, err.Error())))
 			}
 		case "SQS":
 			if err := ValidateSQSEvent(projectName, configName, region, accountId, event.Properties.SQSEvent, sqsc); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("SQS Event %q %v", eventName, err.Error()))
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("SQS Event %q %v", eventName, err.Error())))
 			}
 		case "SNS":
-			if err := ValidateSNSEvent(projectName, configName, region, accountId, event.Properties.SNSEvent, snsc); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("SNS Event %q %v", eventName, err.Error()))
+			if err := ValidateSNSEvent(template, projectName, configName, region, accountId, event.Properties.SNSEvent, snsc); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("SNS Event %q %v", eventName, err.Error())))
 			}
 		case "Schedule":
-			if err := ValidateScheduleEvent(event.Properties.ScheduleEvent); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("Schedule Event %q %v", eventName, err.Error()))
+			if err := ValidateScheduleEvent(event.Properties.ScheduleEvent, limits); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("Schedule Event %q %v", eventName, err.Error())))
 			}
-		case "CloudWatchEvent":
-			if err := ValidateCloudWatchEventEvent(event.Properties.CloudWatchEventEvent); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("CloudWatch Event %q %v", eventName, err.Error()))
+		case "CloudWatchEvent", "EventBridgeRule":
+			if err := ValidateEventBridgeRuleEvent(template, projectName, configName, accountId, event.Properties.EventBridgeRuleEvent, limits, ebc); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("%v Event %q %v", event.Type, eventName, err.Error())))
 			}
 		case "CloudWatchLogs":
 			if err := ValidateCloudWatchLogsEvent(projectName, configName, event.Properties.CloudWatchLogsEvent, cwlc); err != nil {
-				return resourceError(fun, resourceName, fmt.Sprintf("CloudWatchLogs Event %q %v", eventName, err.Error()))
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("CloudWatchLogs Event %q %v", eventName, err.Error())))
 			}
 		default:
-			return resourceError(fun, resourceName, fmt.Sprintf("Event %q Unsupported Event type %q", eventName, event.Type))
+			errs = append(errs, resourceError(fun, resourceName, path, "UnsupportedEvent", fmt.Sprintf("Event %q Unsupported Event type %q", eventName, event.Type)))
 		}
 	}
 
-	return nil
+	// Events goformation does not have are passed through the Metadata, an invalid passthrough is reported by ValidateFunctionIAM
+	passthrough, _ := GetPassthrough(fun.AWSCloudFormationMetadata)
+	if passthrough == nil {
+		return errs.OrNil()
+	}
+
+	eventNames = []string{}
+	for eventName := range passthrough.Events {
+		eventNames = append(eventNames, eventName)
+	}
+	sort.Strings(eventNames)
+
+	for _, eventName := range eventNames {
+		event := passthrough.Events[eventName]
+		path := fmt.Sprintf("Events.%v", eventName)
+
+		if _, ok := fun.Events[eventName]; ok {
+			errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("Event %q is duplicated", eventName)))
+			continue
+		}
+
+		switch event.Type {
+		case "HttpApi":
+			if err := ValidateHttpApiEvent(template, event); err != nil {
+				errs = append(errs, resourceError(fun, resourceName, path, "InvalidEvent", fmt.Sprintf("HttpApi Event %q %v", eventName, err.Error())))
+			}
+		default:
+			errs = append(errs, resourceError(fun, resourceName, path, "UnsupportedEvent", fmt.Sprintf("Event %q Unsupported Event type %q", eventName, event.Type)))
+		}
+	}
+
+	return errs.OrNil()
 }
 
 func ValidateVPCConfig(
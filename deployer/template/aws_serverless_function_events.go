@@ -41,7 +41,43 @@ func ValidateAPIEvent(template *cloudformation.Template, event *serverless.Funct
 	return nil
 }
 
-func ValidateS3Event(projectName, configName string, event *serverless.Function_S3Event, s3c aws.S3API) error {
+func ValidateHttpApiEvent(template *cloudformation.Template, event HttpApiEvent) error {
+	// ApiId must be explicitly defined, otherwise SAM creates an implicit HttpApi Fenrir has not validated
+	if event.Properties.ApiId == "" {
+		return fmt.Errorf("ApiId must be explicitly defined")
+	}
+
+	ref, err := decodeRef(event.Properties.ApiId)
+	if err != nil || ref == "" {
+		return fmt.Errorf("ApiId must be !Ref")
+	}
+
+	api, err := GetHttpApi(template, ref)
+	if err != nil {
+		return fmt.Errorf("ApiId Reference %q not found", ref)
+	}
+
+	if event.Properties.Auth == nil {
+		return nil
+	}
+
+	authorizer := event.Properties.Auth.Authorizer
+	if authorizer != "NONE" && !hasHttpApiAuthorizer(api, authorizer) {
+		return fmt.Errorf("Auth.Authorizer %q is not an Authorizer of %q", authorizer, ref)
+	}
+
+	return nil
+}
+
+func ValidateS3Event(template *cloudformation.Template, projectName, configName string, event *serverless.Function_S3Event, s3c aws.S3API) error {
+	// A bucket in the template is already tagged
+	if ref, err := decodeRef(event.Bucket); err == nil {
+		if res, ok := template.Resources[ref]; !ok || res.AWSCloudFormationType() != "AWS::S3::Bucket" {
+			return fmt.Errorf("Bucket !Ref %v is not an AWS::S3::Bucket in the template", ref)
+		}
+		return nil
+	}
+
 	tags, err := s3.GetBucketTags(s3c, to.Strp(event.Bucket))
 	if err != nil {
 		return err
@@ -92,7 +128,15 @@ func ValidateKinesisEvent(projectName, configName, region, accountId string, eve
 	return hasCorrectTags(projectName, configName, tags)
 }
 
-func ValidateDynamoDBEvent(projectName, configName string, event *serverless.Function_DynamoDBEvent, ddbc aws.DDBAPI) error {
+func ValidateDynamoDBEvent(template *cloudformation.Template, projectName, configName string, event *serverless.Function_DynamoDBEvent, ddbc aws.DDBAPI) error {
+	// A table in the template is already tagged
+	if getAtt, err := decodeGetAtt(event.Stream); err == nil {
+		if res, ok := template.Resources[getAtt[0]]; !ok || res.AWSCloudFormationType() != "AWS::DynamoDB::Table" || len(getAtt) != 2 || getAtt[1] != "StreamArn" {
+			return fmt.Errorf("Stream must be !GetAtt <table>.StreamArn of an AWS::DynamoDB::Table in the template")
+		}
+		return nil
+	}
+
 	// we want to check the tags on the table itself, streams do not have tags
 	dynamodbStreamName := strings.SplitN(event.Stream, "/stream", 3)[0]
 
@@ -151,7 +195,15 @@ func ValidateSQSEvent(projectName, configName, region, accountId string, event *
 	return hasCorrectTags(projectName, configName, tags)
 }
 
-func ValidateSNSEvent(projectName, configName, region, accountId string, event *serverless.Function_SNSEvent, snsc aws.SNSAPI) error {
+func ValidateSNSEvent(template *cloudformation.Template, projectName, configName, region, accountId string, event *serverless.Function_SNSEvent, snsc aws.SNSAPI) error {
+	// A topic in the template is already tagged
+	if ref, err := decodeRef(event.Topic); err == nil {
+		if res, ok := template.Resources[ref]; !ok || res.AWSCloudFormationType() != "AWS::SNS::Topic" {
+			return fmt.Errorf("Topic !Ref %v is not an AWS::SNS::Topic in the template", ref)
+		}
+		return nil
+	}
+
 	// event.Topic is ARN or NAME e.g.arn:aws:sns:us-east-1:000000000000:test-topic
 	if strings.HasPrefix(event.Topic, "arn:") {
 		region, account, resource := to.ArnRegionAccountResource(event.Topic)
@@ -181,12 +233,38 @@ func ValidateSNSEvent(projectName, configName, region, accountId string, event *
 	return hasCorrectTags(projectName, configName, tags)
 }
 
-func ValidateScheduleEvent(event *serverless.Function_ScheduleEvent) error {
-	// Allowed any
-	return nil
+func ValidateScheduleEvent(event *serverless.Function_ScheduleEvent, limits Limits) error {
+	if event == nil {
+		return fmt.Errorf("Event Properties nil")
+	}
+
+	if err := ValidateScheduleExpression(event.Schedule, limits); err != nil {
+		return err
+	}
+
+	return ValidateEventInput(event.Input, "")
 }
 
-func ValidateCloudWatchEventEvent(event *serverless.Function_CloudWatchEventEvent) error {
-	// Allowed any
-	return nil
+// ValidateEventBridgeRuleEvent checks CloudWatchEvent and EventBridgeRule events,
+// both are unmarshalled into EventBridgeRuleEvent which includes EventBusName
+func ValidateEventBridgeRuleEvent(
+	template *cloudformation.Template,
+	projectName, configName, accountId string,
+	event *serverless.Function_EventBridgeRuleEvent,
+	limits Limits,
+	ebc aws.EBAPI,
+) error {
+	if event == nil {
+		return fmt.Errorf("Event Properties nil")
+	}
+
+	if err := ValidateEventBusName(template, projectName, configName, accountId, event.EventBusName, ebc); err != nil {
+		return err
+	}
+
+	if err := ValidateEventInput(event.Input, event.InputPath); err != nil {
+		return err
+	}
+
+	return ValidateEventPattern(event.Pattern, isSharedBus(template, event.EventBusName), limits)
 }
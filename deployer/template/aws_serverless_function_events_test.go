@@ -2,6 +2,7 @@ package template
 
 import (
 	"testing"
+	"time"
 
 	"github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/cloudformation/serverless"
@@ -18,14 +19,54 @@ func TestValidateAPIEventWorks(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateHttpApiEvent(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/http_api.yml")
+	assert.NoError(t, err)
+
+	fn, err := template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+
+	passthrough, err := GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Len(t, passthrough.Events, 3)
+
+	for _, event := range passthrough.Events {
+		assert.NoError(t, ValidateHttpApiEvent(template, event))
+	}
+
+	event := passthrough.Events["Post"]
+	event.Properties.Auth.Authorizer = "missing"
+	assert.EqualError(t, ValidateHttpApiEvent(template, event), `Auth.Authorizer "missing" is not an Authorizer of "helloHttpApi"`)
+
+	event.Properties.Auth.Authorizer = "AWS_IAM"
+	assert.NoError(t, ValidateHttpApiEvent(template, event))
+
+	event.Properties.ApiId = cloudformation.Ref("hello")
+	assert.EqualError(t, ValidateHttpApiEvent(template, event), `ApiId Reference "hello" not found`)
+
+	event.Properties.ApiId = "api-id"
+	assert.EqualError(t, ValidateHttpApiEvent(template, event), `ApiId must be !Ref`)
+
+	event.Properties.ApiId = ""
+	assert.EqualError(t, ValidateHttpApiEvent(template, event), `ApiId must be explicitly defined`)
+}
+
 func TestValidateS3EventWorks(t *testing.T) {
 
 	awsc := MockAwsClients()
-	err := ValidateS3Event("project", "development", &serverless.Function_S3Event{
+	err := ValidateS3Event(&cloudformation.Template{}, "project", "development", &serverless.Function_S3Event{
 		Bucket: "bucket",
 	}, awsc.S3(nil, nil, nil))
 	assert.NoError(t, err)
 
+	template, err := MockTemplate("../../examples/tests/allowed/s3_bucket.yml")
+	assert.NoError(t, err)
+
+	err = ValidateS3Event(template, "project", "development", &serverless.Function_S3Event{
+		Bucket: cloudformation.Ref("Uploads"),
+	}, awsc.S3(nil, nil, nil))
+	assert.NoError(t, err)
+
 }
 
 func TestValidateKinesisEventWorks(t *testing.T) {
@@ -40,12 +81,24 @@ func TestValidateKinesisEventWorks(t *testing.T) {
 
 func TestValidateDynamoDBEventWorks(t *testing.T) {
 
+	template, err := MockTemplate("../../examples/tests/allowed/dynamodb_table.yml")
+	assert.NoError(t, err)
+
 	awsc := MockAwsClients()
-	err := ValidateDynamoDBEvent("project", "development", &serverless.Function_DynamoDBEvent{
+	err = ValidateDynamoDBEvent(template, "project", "development", &serverless.Function_DynamoDBEvent{
 		Stream: "db",
 	}, awsc.DDB(nil, nil, nil))
 	assert.NoError(t, err)
 
+	err = ValidateDynamoDBEvent(template, "project", "development", &serverless.Function_DynamoDBEvent{
+		Stream: cloudformation.GetAtt("table", "StreamArn"),
+	}, awsc.DDB(nil, nil, nil))
+	assert.NoError(t, err)
+
+	err = ValidateDynamoDBEvent(template, "project", "development", &serverless.Function_DynamoDBEvent{
+		Stream: cloudformation.GetAtt("hello", "Arn"),
+	}, awsc.DDB(nil, nil, nil))
+	assert.Error(t, err)
 }
 
 func TestValidateSQSEventWorks(t *testing.T) {
@@ -62,11 +115,24 @@ func TestValidateSQSEventWorks(t *testing.T) {
 
 func TestValidateSNSEventWorks(t *testing.T) {
 
+	template, err := MockTemplate("../../examples/tests/allowed/sns_topic.yml")
+	assert.NoError(t, err)
+
 	awsc := MockAwsClients()
-	err := ValidateSNSEvent("project", "development", "region", "accountID", &serverless.Function_SNSEvent{
+	err = ValidateSNSEvent(template, "project", "development", "region", "accountID", &serverless.Function_SNSEvent{
 		Topic: "arn:aws:sns:us-east-1:000000000000:test-topic",
 	}, awsc.SNS(nil, nil, nil))
 	assert.NoError(t, err)
+
+	err = ValidateSNSEvent(template, "project", "development", "region", "accountID", &serverless.Function_SNSEvent{
+		Topic: cloudformation.Ref("topic"),
+	}, awsc.SNS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	err = ValidateSNSEvent(template, "project", "development", "region", "accountID", &serverless.Function_SNSEvent{
+		Topic: cloudformation.Ref("queue"),
+	}, awsc.SNS(nil, nil, nil))
+	assert.Error(t, err)
 }
 
 func TestValidateSNSEventWorksWithName(t *testing.T) {
@@ -75,7 +141,7 @@ func TestValidateSNSEventWorksWithName(t *testing.T) {
 	event := serverless.Function_SNSEvent{
 		Topic: "test-topic",
 	}
-	err := ValidateSNSEvent("project", "development", "region", "accountID", &event, awsc.SNS(nil, nil, nil))
+	err := ValidateSNSEvent(&cloudformation.Template{}, "project", "development", "region", "accountID", &event, awsc.SNS(nil, nil, nil))
 	assert.NoError(t, err)
 	assert.Equal(t, "arn:aws:sns:region:accountID:test-topic", event.Topic)
 }
@@ -83,7 +149,7 @@ func TestValidateSNSEventWorksWithName(t *testing.T) {
 func TestValidateSNSEventDoesntWorkWithIncorrectTags(t *testing.T) {
 
 	awsc := MockAwsClients()
-	err := ValidateSNSEvent("project", "wrong_config", "region", "accountID", &serverless.Function_SNSEvent{
+	err := ValidateSNSEvent(&cloudformation.Template{}, "project", "wrong_config", "region", "accountID", &serverless.Function_SNSEvent{
 		Topic: "arn:aws:sns:us-east-1:000000000000:test-topic",
 	}, awsc.SNS(nil, nil, nil))
 	assert.Error(t, err)
@@ -91,14 +157,129 @@ func TestValidateSNSEventDoesntWorkWithIncorrectTags(t *testing.T) {
 
 // ScheduleEvent
 
-func TestValidateScheduleEventWorks(t *testing.T) {
-	err := ValidateScheduleEvent(&serverless.Function_ScheduleEvent{})
-	assert.NoError(t, err)
+func TestValidateScheduleEvent(t *testing.T) {
+	for _, schedule := range []string{
+		"rate(1 minute)",
+		"rate(5 minutes)",
+		"rate(1 day)",
+		"cron(0 12 * * ? *)",
+		"cron(0/15 * ? * MON-FRI *)",
+		"cron(0 8,20 ? * * *)",
+	} {
+		err := ValidateScheduleEvent(&serverless.Function_ScheduleEvent{Schedule: schedule}, MockLimits())
+		assert.NoError(t, err, schedule)
+	}
+
+	for _, schedule := range []string{
+		"",
+		"rate(0 minutes)",
+		"rate(1 minutes)",
+		"rate(5 minute)",
+		"rate(30 seconds)",
+		"cron(* * * * ? *)",
+		"cron(0,30 * * * ? *)",
+		"cron(0 12 * * *)",
+		"cron(0 12 * * * *)",
+		"cron(61 * * * ? *)",
+		"every 5 minutes",
+	} {
+		err := ValidateScheduleEvent(&serverless.Function_ScheduleEvent{Schedule: schedule}, Limits{MinScheduleRate: time.Hour})
+		assert.Error(t, err, schedule)
+	}
+
+	err := ValidateScheduleEvent(&serverless.Function_ScheduleEvent{Schedule: "rate(1 hour)", Input: "{bad"}, MockLimits())
+	assert.Error(t, err)
 }
 
-func TestValidateCloudWatchEventEventWorks(t *testing.T) {
+func TestValidateEventBridgeRuleEvent(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/events_rule.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	awsc.EBClient.AddEventBus("shared", "arn:aws:events:us-east-1:111111111111:event-bus/shared", map[string]string{
+		"FenrirAllowed:project:development": "true",
+	})
+	awsc.EBClient.AddEventBus("other", "arn:aws:events:us-east-1:111111111111:event-bus/other", map[string]string{
+		"ProjectName": "project",
+		"ConfigName":  "development",
+	})
+
+	for _, bus := range []string{
+		"",
+		"default",
+		"team",
+		cloudformation.Ref("bus"),
+		"arn:aws:events:us-east-1:111111111111:event-bus/shared",
+	} {
+		err = ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", &serverless.Function_EventBridgeRuleEvent{
+			EventBusName: bus,
+			Pattern:      map[string]interface{}{"source": []interface{}{"aws.ec2"}},
+		}, MockLimits(), awsc.EB(nil, nil, nil))
+		assert.NoError(t, err, bus)
+	}
 
-	err := ValidateCloudWatchEventEvent(&serverless.Function_CloudWatchEventEvent{})
+	// Another accounts bus must explicitly allow the project
+	for _, bus := range []string{
+		"unknown",
+		cloudformation.Ref("queue"),
+		"arn:aws:events:us-east-1:111111111111:event-bus/other",
+	} {
+		err = ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", &serverless.Function_EventBridgeRuleEvent{
+			EventBusName: bus,
+			Pattern:      map[string]interface{}{"source": []interface{}{"aws.ec2"}},
+		}, MockLimits(), awsc.EB(nil, nil, nil))
+		assert.Error(t, err, bus)
+	}
+}
+
+func TestValidateEventBridgeRuleEventPattern(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/events_rule.yml")
 	assert.NoError(t, err)
 
+	awsc := MockAwsClients()
+
+	valid := []*serverless.Function_EventBridgeRuleEvent{
+		{Pattern: `{"source": ["aws.ec2"], "detail": {"state": ["terminated"]}}`},
+		{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}, Input: `{"a": 1}`},
+		{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}, InputPath: "$.detail"},
+		// Buses in the template only receive the projects events
+		{EventBusName: cloudformation.Ref("bus"), Pattern: map[string]interface{}{"source": []interface{}{"orders"}}},
+	}
+
+	for _, event := range valid {
+		err = ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", event, MockLimits(), awsc.EB(nil, nil, nil))
+		assert.NoError(t, err, event.Pattern)
+	}
+
+	invalid := []*serverless.Function_EventBridgeRuleEvent{
+		{},
+		{Pattern: `{"source": `},
+		{Pattern: map[string]interface{}{}},
+		{Pattern: map[string]interface{}{"detail": map[string]interface{}{"state": []interface{}{"terminated"}}}},
+		{Pattern: map[string]interface{}{"source": []interface{}{}}},
+		{Pattern: map[string]interface{}{"source": []interface{}{map[string]interface{}{"prefix": ""}}}},
+		{Pattern: map[string]interface{}{"source": []interface{}{"orders"}}},
+		{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}, Input: "{bad"},
+		{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}, InputPath: "detail"},
+		{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}, Input: "{}", InputPath: "$"},
+	}
+
+	for _, event := range invalid {
+		err = ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", event, MockLimits(), awsc.EB(nil, nil, nil))
+		assert.Error(t, err, event.Pattern)
+	}
+
+	// Without Sources any exact source can be matched, broad patterns are still rejected
+	limits := MockLimits()
+	limits.Sources = nil
+
+	event := &serverless.Function_EventBridgeRuleEvent{Pattern: map[string]interface{}{"source": []interface{}{"orders"}}}
+	assert.NoError(t, ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", event, limits, awsc.EB(nil, nil, nil)))
+
+	event = &serverless.Function_EventBridgeRuleEvent{Pattern: map[string]interface{}{"source": []interface{}{map[string]interface{}{"prefix": ""}}}}
+	assert.Error(t, ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", event, limits, awsc.EB(nil, nil, nil)))
+
+	limits.Sources = []string{}
+	event = &serverless.Function_EventBridgeRuleEvent{Pattern: map[string]interface{}{"source": []interface{}{"aws.ec2"}}}
+	assert.Regexp(t, `Pattern source "aws.ec2" is not allowed`, ValidateEventBridgeRuleEvent(template, "project", "development", "000000000000", event, limits, awsc.EB(nil, nil, nil)))
 }
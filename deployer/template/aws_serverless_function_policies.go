@@ -0,0 +1,388 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/cwl"
+	"github.com/coinbase/fenrir/aws/iam"
+	"github.com/coinbase/fenrir/aws/kms"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/coinbase/step/utils/to"
+)
+
+// ValidateFunctionPolicyDocuments checks every inline statement is least privilege
+// and only grants access to resources in the template or tagged for the project
+func ValidateFunctionPolicyDocuments(
+	template *cloudformation.Template,
+	projectName, configName, resourceName string,
+	res cloudformation.Resource,
+	docs []serverless.Function_IAMPolicyDocument,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) error {
+	docStatements := []interface{}{}
+	for _, doc := range docs {
+		docStatements = append(docStatements, doc.Statement)
+	}
+
+	return validatePolicyDocuments(template, projectName, configName, resourceName, res, docStatements, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+}
+
+// validatePolicyDocuments checks the Statement of each policy document of res
+func validatePolicyDocuments(
+	template *cloudformation.Template,
+	projectName, configName, resourceName string,
+	res cloudformation.Resource,
+	docStatements []interface{},
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) error {
+	errs := ValidationErrors{}
+
+	for i, docStatement := range docStatements {
+		statements, err := iam.ParseStatements(docStatement)
+		if err != nil {
+			errs = append(errs, resourceError(res, resourceName, fmt.Sprintf("Policies[%v].Statement", i), "InvalidPolicy", err.Error()))
+			continue
+		}
+
+		for j, statement := range statements {
+			path := fmt.Sprintf("Policies[%v].Statement[%v]", i, j)
+
+			for _, err := range statement.Analyze() {
+				errs = append(errs, resourceError(res, resourceName, path, "NotLeastPrivilege", err.Error()))
+			}
+
+			if statement.Effect != "Allow" {
+				continue
+			}
+
+			for _, resource := range statement.NormalizedResource() {
+				if resource == "*" {
+					continue // reported by Analyze
+				}
+
+				err := validatePolicyResource(template, projectName, configName, resource, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+				if err != nil {
+					errs = append(errs, resourceError(res, resourceName, fmt.Sprintf("%v.Resource", path), "UnsupportedResource", err.Error()))
+				}
+			}
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// validatePolicyResource allows a local !Ref or !GetAtt, or an ARN with the correct tags
+func validatePolicyResource(
+	template *cloudformation.Template,
+	projectName, configName, resource string,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) error {
+	if ref, err := decodeRef(resource); err == nil {
+		if _, ok := template.Resources[ref]; !ok {
+			return fmt.Errorf("Resource !Ref %v is not in the template", ref)
+		}
+		return nil
+	}
+
+	if getAtt, err := decodeGetAtt(resource); err == nil {
+		if _, ok := template.Resources[getAtt[0]]; !ok {
+			return fmt.Errorf("Resource !GetAtt %v is not in the template", getAtt[0])
+		}
+		return nil
+	}
+
+	if !strings.HasPrefix(resource, "arn:") {
+		return fmt.Errorf("Resource must be !Ref, !GetAtt or an ARN")
+	}
+
+	tags, err := arnTags(resource, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc)
+	if err != nil {
+		return fmt.Errorf("Resource %v %v", resource, err.Error())
+	}
+
+	if err := hasCorrectTags(projectName, configName, tags); err != nil {
+		return fmt.Errorf("Resource %v %v", resource, err.Error())
+	}
+
+	return nil
+}
+
+// arnTags returns the tags of the resource an ARN targets
+// e.g. the bucket of an S3 object ARN or the table of a DynamoDB index ARN
+func arnTags(
+	arn string,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) (map[string]string, error) {
+	// arn:partition:service:region:account:resource
+	split := strings.SplitN(arn, ":", 6)
+	if len(split) != 6 {
+		return nil, fmt.Errorf("incorrect ARN")
+	}
+
+	service, resource := split[2], split[5]
+
+	// the target resource name cannot have wildcards
+	target := func(name string) (string, error) {
+		if name == "" || strings.ContainsAny(name, "*?") {
+			return "", fmt.Errorf("must not have wildcards in the %v resource name", service)
+		}
+		return name, nil
+	}
+
+	tags := map[string]string{}
+
+	switch service {
+	case "s3":
+		// bucket or bucket/key
+		bucket, err := target(strings.SplitN(resource, "/", 2)[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return s3.GetBucketTags(s3c, to.Strp(bucket))
+	case "dynamodb":
+		// table/name or table/name/index/... or table/name/stream/...
+		typeName := strings.SplitN(resource, "/", 3)
+		if len(typeName) < 2 || typeName[0] != "table" {
+			return nil, fmt.Errorf("must be a table")
+		}
+
+		table, err := target(typeName[1])
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := ddbc.ListTagsOfResource(&dynamodb.ListTagsOfResourceInput{
+			ResourceArn: to.Strp(fmt.Sprintf("%v:table/%v", strings.Join(split[:5], ":"), table)),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.Tags {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	case "sqs":
+		queue, err := target(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := sqsc.ListQueueTags(&sqs.ListQueueTagsInput{
+			QueueUrl: to.Strp(fmt.Sprintf("https://sqs.%v.amazonaws.com/%v/%v", split[3], split[4], queue)),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range out.Tags {
+			tags[key] = to.Strs(value)
+		}
+	case "sns":
+		if _, err := target(resource); err != nil {
+			return nil, err
+		}
+
+		out, err := snsc.ListTagsForResource(&sns.ListTagsForResourceInput{
+			ResourceArn: to.Strp(arn),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.Tags {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	case "kinesis":
+		// stream/name
+		typeName := strings.SplitN(resource, "/", 3)
+		if len(typeName) < 2 || typeName[0] != "stream" {
+			return nil, fmt.Errorf("must be a stream")
+		}
+
+		stream, err := target(typeName[1])
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := kinc.ListTagsForStream(&kinesis.ListTagsForStreamInput{
+			StreamName: to.Strp(stream),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.Tags {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	case "kms":
+		if _, err := target(strings.TrimPrefix(resource, "key/")); err != nil {
+			return nil, err
+		}
+
+		key, err := kms.FindKey(kmsc, arn)
+		if err != nil {
+			return nil, err
+		}
+
+		return key.Tags, nil
+	case "logs":
+		// log-group:name or log-group:name:*
+		typeName := strings.SplitN(resource, ":", 3)
+		if len(typeName) < 2 || typeName[0] != "log-group" {
+			return nil, fmt.Errorf("must be a log-group")
+		}
+
+		logGroup, err := target(typeName[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return cwl.ListLogGroupTags(cwlc, to.Strp(logGroup))
+	case "states":
+		// stateMachine:name
+		typeName := strings.SplitN(resource, ":", 2)
+		if len(typeName) < 2 || typeName[0] != "stateMachine" {
+			return nil, fmt.Errorf("must be a stateMachine")
+		}
+
+		if _, err := target(typeName[1]); err != nil {
+			return nil, err
+		}
+
+		out, err := sfnc.ListTagsForResource(&sfn.ListTagsForResourceInput{
+			ResourceArn: to.Strp(arn),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.Tags {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	case "ssm":
+		// parameter/name, hierarchical names drop their leading slash
+		typeName := strings.SplitN(resource, "/", 2)
+		if len(typeName) < 2 || typeName[0] != "parameter" {
+			return nil, fmt.Errorf("must be a parameter")
+		}
+
+		parameter, err := target(typeName[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.Contains(parameter, "/") {
+			parameter = "/" + parameter
+		}
+
+		out, err := ssmc.ListTagsForResource(&ssm.ListTagsForResourceInput{
+			ResourceType: to.Strp(ssm.ResourceTypeForTaggingParameter),
+			ResourceId:   to.Strp(parameter),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.TagList {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	case "secretsmanager":
+		// secret:name-suffix
+		typeName := strings.SplitN(resource, ":", 2)
+		if len(typeName) < 2 || typeName[0] != "secret" {
+			return nil, fmt.Errorf("must be a secret")
+		}
+
+		if _, err := target(typeName[1]); err != nil {
+			return nil, err
+		}
+
+		out, err := smc.DescribeSecret(&secretsmanager.DescribeSecretInput{
+			SecretId: to.Strp(arn),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range out.Tags {
+			if tag.Key == nil {
+				continue
+			}
+			tags[*tag.Key] = to.Strs(tag.Value)
+		}
+	default:
+		return nil, fmt.Errorf("service %q not supported", service)
+	}
+
+	return tags, nil
+}
@@ -1,8 +1,12 @@
 package template
 
 import (
+	"encoding/base64"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,6 +26,8 @@ func TestValidateAWSServerlessFunctionWorks(t *testing.T) {
 		map[string]string{
 			"s3://bucket/path.zip": MockS3SHA(),
 		},
+		map[string]string{},
+		MockLimits(),
 		awsc.IAM(nil, nil, nil),
 		awsc.EC2(nil, nil, nil),
 		awsc.S3(nil, nil, nil),
@@ -31,7 +37,316 @@ func TestValidateAWSServerlessFunctionWorks(t *testing.T) {
 		awsc.SNS(nil, nil, nil),
 		awsc.KMS(nil, nil, nil),
 		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
 	)
 
 	assert.NoError(t, err)
 }
+
+func TestValidateFunctionPolicyDocuments(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/not/bad_function_policy_document.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	err = ValidateTemplateResources(
+		"project", "development", "region", "account",
+		template,
+		map[string]string{"s3://bucket/path.zip": MockS3SHA()},
+		map[string]string{},
+		MockLimits(),
+		awsc.IAM(nil, nil, nil),
+		awsc.EC2(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	)
+
+	verrs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 12, len(verrs))
+
+	for _, msg := range []string{
+		`Action "\*" not allowed \(rule: NotLeastPrivilege, path: Policies\[0\].Statement\[0\]\)`,
+		`Action "iam:PassRole" not allowed, matches "iam:\*"`,
+		`Action "sts:Assume\*" not allowed, matches "sts:AssumeRole"`,
+		`NotAction not supported`,
+		`arn:aws:s3:::\* must not have wildcards`,
+		`service "ec2" not supported`,
+		`!Ref Missing is not in the template`,
+		`Resource must be !Ref, !GetAtt or an ARN`,
+	} {
+		assert.Regexp(t, msg, err.Error())
+	}
+}
+
+func TestValidateFunctionPolicyDocumentsWorks(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/function_w_policy_document.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	fn, err := template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+
+	err = ValidateFunctionIAM(
+		template,
+		"project", "development", "region", "account", "hello",
+		fn,
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+	)
+	assert.NoError(t, err)
+
+	// The statements are kept when the template is marshalled
+	raw, err := template.JSON()
+	assert.NoError(t, err)
+	assert.Regexp(t, "dynamodb:GetItem", string(raw))
+	assert.Regexp(t, "aws:SecureTransport", string(raw))
+
+	// SAM policy templates and policy documents cannot be mixed
+	docs := *fn.Policies.IAMPolicyDocumentArray
+	fn.Policies = &serverless.Function_Policies{
+		IAMPolicyDocumentArray: &[]serverless.Function_IAMPolicyDocument{docs[0], {}},
+		SAMPolicyTemplateArray: &[]serverless.Function_SAMPolicyTemplate{{}, {VPCAccessPolicy: &serverless.Function_EmptySAMPT{}}},
+	}
+
+	err = ValidateFunctionIAM(
+		template,
+		"project", "development", "region", "account", "hello",
+		fn,
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+	)
+	assert.Regexp(t, "cannot mix", err)
+}
+
+func TestValidateFunctionIAMPolicyTemplateTargets(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/function_w_more_policies.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	fn, err := template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+
+	validate := func() error {
+		return ValidateFunctionIAM(
+			template,
+			"project", "development", "region", "account", "hello",
+			fn,
+			awsc.IAM(nil, nil, nil),
+			awsc.S3(nil, nil, nil),
+			awsc.KIN(nil, nil, nil),
+			awsc.DDB(nil, nil, nil),
+			awsc.SQS(nil, nil, nil),
+			awsc.SNS(nil, nil, nil),
+			awsc.KMS(nil, nil, nil),
+			awsc.CWL(nil, nil, nil),
+			awsc.SSM(nil, nil, nil),
+			awsc.SM(nil, nil, nil),
+			awsc.SFN(nil, nil, nil),
+		)
+	}
+
+	assert.NoError(t, validate())
+
+	policies := *fn.Policies.SAMPolicyTemplateArray
+
+	// External bucket without the correct tags
+	awsc.S3Client.SetBucketTags("other", map[string]string{"ProjectName": "other"}, nil)
+	policies[4].S3ReadPolicy.BucketName = "other"
+	assert.Regexp(t, "Policies.S3ReadPolicy.BucketName other ProjectName", validate())
+
+	// Ref must be in the template
+	policies[4].S3ReadPolicy.BucketName = "bucket"
+	policies[0].DynamoDBReadPolicy.TableName = base64.StdEncoding.EncodeToString([]byte(`{"Ref":"Missing"}`))
+	assert.Regexp(t, "TableName !Ref Missing is not in the template", validate())
+	policies[0].DynamoDBReadPolicy.TableName = "external-table"
+
+	// Refs must be to a resource of the policies type
+	policies[4].S3ReadPolicy.BucketName = base64.StdEncoding.EncodeToString([]byte(`{"Ref":"Queue"}`))
+	assert.Regexp(t, "BucketName !Ref Queue is not a AWS::S3::Bucket", validate())
+	policies[4].S3ReadPolicy.BucketName = "bucket"
+
+	// SSMParameterReadPolicy and SecretsManagerGetSecretValuePolicy are passed through the Metadata
+	passthrough, err := GetPassthrough(fn.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Len(t, policies, 7)
+	assert.Len(t, passthrough.Policies, 2)
+
+	// Parameters must have the correct tags
+	awsc.SSMClient.SetParameterTags("/project/development/parameter", map[string]string{"ProjectName": "other"})
+	assert.Regexp(t, "Policies.SSMParameterReadPolicy.ParameterName project/development/parameter ProjectName", validate())
+	awsc.SSMClient.SetParameterTags("/project/development/parameter", map[string]string{"FenrirAllAllowed": "true"})
+	assert.NoError(t, validate())
+
+	// Secrets cannot be in the template
+	fn.AWSCloudFormationMetadata[PassthroughKey] = map[string]interface{}{
+		"Policies": []interface{}{map[string]interface{}{
+			"SecretsManagerGetSecretValuePolicy": map[string]interface{}{
+				"SecretArn": base64.StdEncoding.EncodeToString([]byte(`{"Ref":"Queue"}`)),
+			},
+		}},
+	}
+	assert.Regexp(t, "SecretArn must be a literal name or ARN", validate())
+
+	fn.Policies.SAMPolicyTemplateArray = &[]serverless.Function_SAMPolicyTemplate{
+		{StepFunctionsExecutionPolicy: &serverless.Function_StateMachineSAMPT{StateMachineName: "external"}},
+	}
+	delete(fn.AWSCloudFormationMetadata, PassthroughKey)
+	assert.NoError(t, validate())
+
+	// External state machines must have the correct tags
+	awsc.SFNClient.SetStateMachineTags("arn:aws:states:region:account:stateMachine:external", map[string]string{"ProjectName": "other"})
+	assert.Regexp(t, "Policies.StepFunctionsExecutionPolicy.StateMachineName external ProjectName", validate())
+}
+
+func TestValidateFunctionIAMDynamoDBCrudPolicyStreamArn(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/dynamodb_table.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	fn, err := template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+
+	validate := func() error {
+		return ValidateFunctionIAM(
+			template,
+			"project", "development", "region", "account", "hello",
+			fn,
+			awsc.IAM(nil, nil, nil),
+			awsc.S3(nil, nil, nil),
+			awsc.KIN(nil, nil, nil),
+			awsc.DDB(nil, nil, nil),
+			awsc.SQS(nil, nil, nil),
+			awsc.SNS(nil, nil, nil),
+			awsc.KMS(nil, nil, nil),
+			awsc.CWL(nil, nil, nil),
+			awsc.SSM(nil, nil, nil),
+			awsc.SM(nil, nil, nil),
+			awsc.SFN(nil, nil, nil),
+		)
+	}
+
+	policy := (*fn.Policies.SAMPolicyTemplateArray)[0].DynamoDBCrudPolicy
+
+	// The StreamArn of a table in the template is replaced with its !Ref
+	policy.TableName = base64.StdEncoding.EncodeToString([]byte(`{"Fn::GetAtt":["table","StreamArn"]}`))
+	assert.NoError(t, validate())
+	ref, err := decodeRef(policy.TableName)
+	assert.NoError(t, err)
+	assert.Equal(t, "table", ref)
+
+	policy.TableName = base64.StdEncoding.EncodeToString([]byte(`{"Fn::GetAtt":["hello","StreamArn"]}`))
+	assert.Regexp(t, "TableName !GetAtt hello is not a AWS::DynamoDB::Table", validate())
+
+	policy.TableName = base64.StdEncoding.EncodeToString([]byte(`{"Fn::GetAtt":["table","Arn"]}`))
+	assert.Regexp(t, "Policies.DynamoDBCrudPolicy.TableName must be !Ref", validate())
+}
+
+func TestValidateRuntime(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+	warning, err := ValidateRuntime("provided.al2023", now)
+	assert.NoError(t, err)
+	assert.Empty(t, warning)
+
+	warning, err = ValidateRuntime("python3.12", now)
+	assert.NoError(t, err)
+	assert.Empty(t, warning)
+
+	// dotnet8 support ends within RuntimeWarning
+	warning, err = ValidateRuntime("dotnet8", now)
+	assert.NoError(t, err)
+	assert.Equal(t, `Runtime "dotnet8" support ends 2026-11-10`, warning)
+
+	_, err = ValidateRuntime("go1.x", now)
+	assert.EqualError(t, err, `Runtime "go1.x" support ended 2024-01-08`)
+
+	// provided.al2 is past end of support but not blocked
+	warning, err = ValidateRuntime("provided.al2", now)
+	assert.NoError(t, err)
+	assert.Regexp(t, `Runtime "provided.al2" support ended 2026-06-30`, warning)
+
+	_, err = ValidateRuntime("nodejs6.10", now)
+	assert.EqualError(t, err, `Runtime "nodejs6.10" is not an allowed runtime`)
+}
+
+func TestValidateArchitectures(t *testing.T) {
+	assert.NoError(t, ValidateArchitectures([]string{"x86_64"}))
+	assert.NoError(t, ValidateArchitectures([]string{"arm64"}))
+	assert.EqualError(t, ValidateArchitectures([]string{"arm"}), `Architecture "arm" must be one of [x86_64 arm64]`)
+	assert.Error(t, ValidateArchitectures([]string{}))
+	assert.Error(t, ValidateArchitectures([]string{"x86_64", "arm64"}))
+}
+
+func TestValidateFunctionPackageImage(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/not/bad_image.yml")
+	assert.NoError(t, err)
+
+	fn, err := template.GetServerlessFunctionWithName("hello")
+	assert.NoError(t, err)
+	assert.True(t, IsImageFunction(fn))
+
+	digest := "sha256:" + MockS3SHA()
+	awsc := MockAwsClients()
+	awsc.ECRClient.AddRepository("hello", map[string]string{"ProjectName": "project", "ConfigName": "development"})
+	awsc.ECRClient.AddImage("hello", "development", digest)
+
+	validate := func(accountId string, imageDigests map[string]string) error {
+		return ValidateFunctionPackage("project", "development", "us-east-1", accountId, "hello", fn, map[string]string{}, imageDigests, awsc.ECR(nil, nil, nil))
+	}
+
+	// A tag is not pinned
+	assert.Regexp(t, "must be an ECR image pinned by @sha256: digest", validate("000000000000", map[string]string{}))
+
+	pinned := "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@" + digest
+	assert.NoError(t, SetPassthroughProperty(fn.AWSCloudFormationMetadata, "ImageUri", pinned))
+
+	assert.NoError(t, validate("000000000000", map[string]string{pinned: digest}))
+	assert.Regexp(t, "not included in the image digests map", validate("000000000000", map[string]string{}))
+	assert.Regexp(t, "must be an ECR repository in us-east-1 111111111111", validate("111111111111", map[string]string{pinned: digest}))
+
+	missing := "000000000000.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:" + strings.Repeat("0", 64)
+	assert.NoError(t, SetPassthroughProperty(fn.AWSCloudFormationMetadata, "ImageUri", missing))
+	assert.Regexp(t, "ImageNotFoundException", validate("000000000000", map[string]string{missing: "sha256:" + strings.Repeat("0", 64)}))
+
+	assert.NoError(t, SetPassthroughProperty(fn.AWSCloudFormationMetadata, "ImageUri", pinned))
+	fn.Runtime = "provided.al2023"
+	fn.Handler = "bootstrap"
+	assert.Regexp(t, "Runtime, Handler not supported with PackageType Image", validate("000000000000", map[string]string{pinned: digest}))
+}
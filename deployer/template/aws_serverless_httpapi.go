@@ -0,0 +1,223 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+)
+
+// HttpApi is the AWS::Serverless::HttpApi properties Fenrir supports,
+// any other property is an error as goformation cannot check it
+type HttpApi struct {
+	Name                      string
+	StageName                 string
+	StageVariables            map[string]interface{}
+	Description               string
+	DefinitionUri             interface{}
+	DefinitionBody            interface{}
+	Tags                      map[string]string
+	CorsConfiguration         interface{}
+	DefaultRouteSettings      interface{}
+	RouteSettings             interface{}
+	FailOnWarnings            bool
+	DisableExecuteApiEndpoint bool
+
+	Auth *struct {
+		Authorizers         map[string]HttpApiAuthorizer
+		DefaultAuthorizer   string
+		EnableIamAuthorizer bool
+	}
+
+	Domain *struct {
+		DomainName            string
+		CertificateArn        string
+		EndpointConfiguration string
+		BasePath              interface{}
+		SecurityPolicy        string
+	}
+}
+
+// HttpApiAuthorizer is either a JWT or a Lambda authorizer
+type HttpApiAuthorizer struct {
+	JwtConfiguration *struct {
+		Issuer   string
+		Audience []string
+	}
+	IdentitySource      string
+	AuthorizationScopes []string
+
+	FunctionArn                    string
+	AuthorizerPayloadFormatVersion interface{}
+	EnableSimpleResponses          bool
+	Identity                       interface{}
+}
+
+// GetHttpApi decodes the properties of the HttpApi resource name
+func GetHttpApi(template *cloudformation.Template, name string) (*HttpApi, error) {
+	res, ok := template.Resources[name].(*cloudformation.CustomResource)
+	if !ok || res.Type != HttpApiType {
+		return nil, fmt.Errorf("resource %q of type AWS::Serverless::HttpApi not found", name)
+	}
+
+	b, err := json.Marshal(res.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	var api HttpApi
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&api); err != nil {
+		return nil, err
+	}
+
+	return &api, nil
+}
+
+func ValidateAWSServerlessHttpApi(
+	projectName, configName, region, accountId, resourceName string,
+	template *cloudformation.Template,
+	res *cloudformation.CustomResource,
+	s3shas map[string]string,
+	limits Limits,
+) error {
+	api, err := GetHttpApi(template, resourceName)
+	if err != nil {
+		return resourceError(res, resourceName, "Properties", "UnsupportedProperty", err.Error())
+	}
+
+	if api.Name != "" {
+		return resourceError(res, resourceName, "Name", "NameOverwritten", "Names are overwritten")
+	}
+
+	if res.Properties == nil {
+		res.Properties = map[string]interface{}{}
+	}
+
+	res.Properties["Name"] = normalizeName("fenrir", projectName, configName, resourceName, 128)
+
+	if api.Tags == nil {
+		api.Tags = map[string]string{}
+	}
+
+	api.Tags["ProjectName"] = projectName
+	api.Tags["ConfigName"] = configName
+	api.Tags["ServiceName"] = resourceName
+	res.Properties["Tags"] = api.Tags
+
+	errs := ValidationErrors{}
+
+	if api.DefinitionUri != nil {
+		s3URI, ok := api.DefinitionUri.(string)
+		if !ok {
+			errs = append(errs, resourceError(res, resourceName, "DefinitionUri", "UnsupportedProperty", "DefinitionUri must be a string"))
+		} else if _, ok := s3shas[s3URI]; !ok {
+			errs = append(errs, resourceError(res, resourceName, "DefinitionUri", "MissingArtifact", fmt.Sprintf("DefinitionUri %v not included in the SHA256s map", s3URI)))
+		}
+	}
+
+	if api.Auth != nil {
+		names := []string{}
+		for name := range api.Auth.Authorizers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if err := ValidateHttpApiAuthorizer(template, api.Auth.Authorizers[name]); err != nil {
+				errs = append(errs, resourceError(res, resourceName, fmt.Sprintf("Auth.Authorizers.%v", name), "InvalidAuthorizer", fmt.Sprintf("Authorizer %q %v", name, err.Error())))
+			}
+		}
+
+		if d := api.Auth.DefaultAuthorizer; d != "" && !hasHttpApiAuthorizer(api, d) {
+			errs = append(errs, resourceError(res, resourceName, "Auth.DefaultAuthorizer", "ResourceNotFound", fmt.Sprintf("DefaultAuthorizer %q is not in Authorizers", d)))
+		}
+	}
+
+	if api.Domain != nil {
+		if err := ValidateHttpApiDomain(region, accountId, api, limits); err != nil {
+			errs = append(errs, resourceError(res, resourceName, "Domain", "InvalidDomain", err.Error()))
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// ValidateHttpApiAuthorizer checks a JWT authorizer trusts an https issuer
+// and a Lambda authorizer is a function in the template
+func ValidateHttpApiAuthorizer(template *cloudformation.Template, authorizer HttpApiAuthorizer) error {
+	jwt, lambda := authorizer.JwtConfiguration != nil, authorizer.FunctionArn != ""
+	if jwt == lambda {
+		return fmt.Errorf("must have exactly one of JwtConfiguration or FunctionArn")
+	}
+
+	if jwt {
+		if !strings.HasPrefix(authorizer.JwtConfiguration.Issuer, "https://") {
+			return fmt.Errorf("JwtConfiguration issuer must be an https URL")
+		}
+
+		if len(authorizer.JwtConfiguration.Audience) == 0 {
+			return fmt.Errorf("JwtConfiguration audience must be defined")
+		}
+
+		return nil
+	}
+
+	getAtt, err := decodeGetAtt(authorizer.FunctionArn)
+	if err != nil || len(getAtt) != 2 || getAtt[1] != "Arn" {
+		return fmt.Errorf("FunctionArn must be !GetAtt <function>.Arn")
+	}
+
+	if res, ok := template.Resources[getAtt[0]]; !ok || res.AWSCloudFormationType() != "AWS::Serverless::Function" {
+		return fmt.Errorf("FunctionArn !GetAtt %v is not an AWS::Serverless::Function in the template", getAtt[0])
+	}
+
+	return nil
+}
+
+// ValidateHttpApiDomain checks the custom domain is one the project can use
+// and its certificate is in the account
+func ValidateHttpApiDomain(region, accountId string, api *HttpApi, limits Limits) error {
+	domain := api.Domain.DomainName
+	if domain == "" || isIntrinsic(domain) {
+		return fmt.Errorf("DomainName must be a literal domain")
+	}
+
+	allowed := false
+	for _, d := range limits.Domains {
+		allowed = allowed || domain == d || strings.HasSuffix(domain, "."+d)
+	}
+
+	if !allowed {
+		return fmt.Errorf("DomainName %q is not an allowed domain", domain)
+	}
+
+	prefix := fmt.Sprintf("arn:aws:acm:%v:%v:certificate/", region, accountId)
+	if !strings.HasPrefix(api.Domain.CertificateArn, prefix) {
+		return fmt.Errorf("CertificateArn must be an ACM certificate ARN in %v %v", region, accountId)
+	}
+
+	if e := api.Domain.EndpointConfiguration; e != "" && e != "REGIONAL" {
+		return fmt.Errorf("EndpointConfiguration must equal REGIONAL")
+	}
+
+	return nil
+}
+
+// hasHttpApiAuthorizer is true if name is an authorizer of the api, or AWS_IAM when it is enabled
+func hasHttpApiAuthorizer(api *HttpApi, name string) bool {
+	if api.Auth == nil {
+		return false
+	}
+
+	if name == "AWS_IAM" {
+		return api.Auth.EnableIamAuthorizer
+	}
+
+	_, ok := api.Auth.Authorizers[name]
+	return ok
+}
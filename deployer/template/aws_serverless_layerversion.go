@@ -17,17 +17,21 @@ func ValidateAWSServerlessLayerVersion(
 ) error {
 
 	if res.LayerName != "" {
-		return resourceError(res, resourceName, "Names are overwritten")
+		return resourceError(res, resourceName, "LayerName", "NameOverwritten", "Names are overwritten")
 	}
 
 	res.LayerName = normalizeName("layer", projectName, configName, resourceName, 64)
 
-	if res.ContentUri == "" {
-		return resourceError(res, resourceName, "ContentUri is empty")
+	if res.ContentUri == nil || (res.ContentUri.String == nil && res.ContentUri.S3Location == nil) {
+		return resourceError(res, resourceName, "ContentUri", "RequiredProperty", "ContentUri is empty")
 	}
 
-	if _, ok := s3shas[res.ContentUri]; !ok {
-		return fmt.Errorf("ContentUri %v not included in the SHA256s map", res.ContentUri)
+	if res.ContentUri.S3Location != nil {
+		return resourceError(res, resourceName, "ContentUri", "UnsupportedProperty", "ContentUri.S3Location not supported")
+	}
+
+	if _, ok := s3shas[*res.ContentUri.String]; !ok {
+		return resourceError(res, resourceName, "ContentUri", "MissingArtifact", fmt.Sprintf("ContentUri %v not included in the SHA256s map", *res.ContentUri.String))
 	}
 
 	return nil
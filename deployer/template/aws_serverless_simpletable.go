@@ -19,7 +19,7 @@ func ValidateAWSServerlessSimpleTable(
 	}
 
 	if res.TableName != "" {
-		return resourceError(res, resourceName, "Names are overwritten")
+		return resourceError(res, resourceName, "TableName", "NameOverwritten", "Names are overwritten")
 	}
 
 	res.TableName = normalizeName("fenrir", projectName, configName, resourceName, 255)
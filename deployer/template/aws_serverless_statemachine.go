@@ -0,0 +1,381 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/lambda"
+	"github.com/coinbase/step/aws/s3"
+	"github.com/sanathkr/yaml"
+)
+
+// AWS::Serverless::StateMachine
+
+func ValidateAWSServerlessStateMachine(
+	projectName, configName, region, accountId, resourceName string,
+	template *cloudformation.Template,
+	res *serverless.StateMachine,
+	s3shas map[string]string,
+	iamc aws.IAMAPI,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+	lambdac aws.LambdaAPI,
+) error {
+
+	if res.Name != "" {
+		return resourceError(res, resourceName, "Name", "NameOverwritten", "Names are overwritten")
+	}
+
+	res.Name = normalizeName("fenrir", projectName, configName, resourceName, 80)
+
+	if res.Tags == nil {
+		res.Tags = map[string]string{}
+	}
+
+	res.Tags["ProjectName"] = projectName
+	res.Tags["ConfigName"] = configName
+	res.Tags["ServiceName"] = resourceName
+
+	if len(res.Events) > 0 {
+		return resourceError(res, resourceName, "Events", "UnsupportedProperty", "Events not supported")
+	}
+
+	if res.Logging != nil {
+		return resourceError(res, resourceName, "Logging", "UnsupportedProperty", "Logging not supported")
+	}
+
+	// IAM and the Definition are independent so report all their errors
+	errs := ValidationErrors{}
+
+	if err := ValidateStateMachineIAM(template, projectName, configName, region, accountId, resourceName, res, iamc, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc); err != nil {
+		errs = append(errs, err)
+	}
+
+	definition, err := stateMachineDefinition(resourceName, res, s3shas, s3c)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		switch err := ValidateStateMachineDefinition(template, projectName, configName, resourceName, res, definition, lambdac).(type) {
+		case nil:
+		case ValidationErrors:
+			errs = append(errs, err...)
+		default:
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// ValidateStateMachineIAM requires either a Role XOR Policies, which get the same checks as a functions Policies.
+// The role SAM creates for Policies gets the permissions boundary through the FenrirPassthrough Metadata
+func ValidateStateMachineIAM(
+	template *cloudformation.Template,
+	projectName, configName, region, accountId, resourceName string,
+	res *serverless.StateMachine,
+	iamc aws.IAMAPI,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	cwlc aws.CWLAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+) error {
+	passthrough, err := GetPassthrough(res.AWSCloudFormationMetadata)
+	if err != nil {
+		return resourceError(res, resourceName, "Metadata", "InvalidPassthrough", err.Error())
+	}
+
+	if passthrough == nil {
+		passthrough = &Passthrough{}
+	}
+
+	hasPolicies := res.Policies != nil || len(passthrough.Policies) > 0
+
+	if res.Role != "" && !hasPolicies {
+		return validateRole(res, projectName, configName, resourceName, "Role", &res.Role, iamc)
+	}
+
+	if res.Role != "" || !hasPolicies {
+		return resourceError(res, resourceName, "Role", "RoleXorPolicies", "Must define either Role or Policies, not both")
+	}
+
+	policies, err := stateMachineFunctionPolicies(res.Policies)
+	if err != nil {
+		return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", fmt.Sprintf("Policies: %v", err.Error()))
+	}
+
+	if policies.IAMPolicyDocument != nil && len(passthrough.Policies) > 0 {
+		return resourceError(res, resourceName, "Policies", "UnsupportedPolicy", "Policies: cannot mix SAMPolicyTemplates and policy documents")
+	}
+
+	// The function policy templates goformation only has for functions are checked with the others
+	stashed := []PassthroughPolicy{}
+	for _, p := range passthrough.Policies {
+		if p.SSMParameterReadPolicy != nil || p.SecretsManagerGetSecretValuePolicy != nil {
+			stashed = append(stashed, p)
+			continue
+		}
+
+		if policies.SAMPolicyTemplateArray == nil {
+			policies.SAMPolicyTemplateArray = &[]serverless.Function_SAMPolicyTemplate{}
+		}
+		*policies.SAMPolicyTemplateArray = append(*policies.SAMPolicyTemplateArray, p.Function_SAMPolicyTemplate)
+	}
+
+	if err := validatePolicies(template, projectName, configName, region, accountId, resourceName, res, policies, stashed, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc); err != nil {
+		return err
+	}
+
+	// Only one of the arrays is marshalled, as validatePolicies leaves them
+	if res.Policies != nil {
+		if policies.IAMPolicyDocumentArray == nil {
+			res.Policies.IAMPolicyDocumentArray = nil
+		}
+		if policies.SAMPolicyTemplateArray == nil {
+			res.Policies.SAMPolicyTemplateArray = nil
+		}
+		if policies.StringArray == nil {
+			res.Policies.StringArray = nil
+		}
+	}
+
+	// The passthrough policies can be updated, e.g. a KMSDecryptPolicy alias replaced with the key id
+	passthrough.PermissionsBoundary = permissionsBoundary(accountId)
+	if res.AWSCloudFormationMetadata == nil {
+		res.AWSCloudFormationMetadata = map[string]interface{}{}
+	}
+	res.AWSCloudFormationMetadata[PassthroughKey] = passthrough
+
+	return nil
+}
+
+// stateMachineFunctionPolicies converts state machine Policies to function Policies, which have the same JSON
+func stateMachineFunctionPolicies(policies *serverless.StateMachine_Policies) (*serverless.Function_Policies, error) {
+	functionPolicies := &serverless.Function_Policies{}
+	if policies == nil {
+		return functionPolicies, nil
+	}
+
+	functionPolicies.String = policies.String
+	functionPolicies.StringArray = policies.StringArray
+
+	if policies.IAMPolicyDocument != nil {
+		functionPolicies.IAMPolicyDocument = &serverless.Function_IAMPolicyDocument{Statement: policies.IAMPolicyDocument.Statement}
+	}
+
+	if policies.IAMPolicyDocumentArray != nil {
+		docs := []serverless.Function_IAMPolicyDocument{}
+		for _, doc := range *policies.IAMPolicyDocumentArray {
+			docs = append(docs, serverless.Function_IAMPolicyDocument{Statement: doc.Statement})
+		}
+		functionPolicies.IAMPolicyDocumentArray = &docs
+	}
+
+	if policies.SAMPolicyTemplateArray != nil {
+		b, err := json.Marshal(*policies.SAMPolicyTemplateArray)
+		if err != nil {
+			return nil, err
+		}
+
+		samPolicies := []serverless.Function_SAMPolicyTemplate{}
+		if err := json.Unmarshal(b, &samPolicies); err != nil {
+			return nil, err
+		}
+		functionPolicies.SAMPolicyTemplateArray = &samPolicies
+	}
+
+	return functionPolicies, nil
+}
+
+// stateMachineDefinition returns the inline Definition or the parsed DefinitionUri file
+func stateMachineDefinition(
+	resourceName string,
+	res *serverless.StateMachine,
+	s3shas map[string]string,
+	s3c aws.S3API,
+) (interface{}, error) {
+	if res.Definition != nil && res.DefinitionUri != nil {
+		return nil, resourceError(res, resourceName, "Definition", "DefinitionXorDefinitionUri", "Must define either Definition or DefinitionUri, not both")
+	}
+
+	if res.Definition != nil {
+		return res.Definition, nil
+	}
+
+	if res.DefinitionUri == nil {
+		return nil, resourceError(res, resourceName, "Definition", "RequiredProperty", "Definition or DefinitionUri required")
+	}
+
+	if res.DefinitionUri.S3Location != nil {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "UnsupportedProperty", "DefinitionUri.S3Location not supported")
+	}
+
+	if res.DefinitionUri.String == nil {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "RequiredProperty", "DefinitionUri nil")
+	}
+
+	s3URI := *res.DefinitionUri.String
+	if _, ok := s3shas[s3URI]; !ok {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "MissingArtifact", fmt.Sprintf("DefinitionUri %v not included in the SHA256s map", s3URI))
+	}
+
+	// Its SHA is checked so the file is what was released
+	bucketPath := strings.SplitN(strings.TrimPrefix(s3URI, "s3://"), "/", 2)
+	if len(bucketPath) != 2 {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "InvalidDefinition", fmt.Sprintf("DefinitionUri %v incorrect", s3URI))
+	}
+
+	raw, err := s3.Get(s3c, &bucketPath[0], &bucketPath[1])
+	if err != nil {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "ResourceNotFound", fmt.Sprintf("DefinitionUri %v %v", s3URI, err.Error()))
+	}
+
+	// YAML is a superset of JSON
+	definitionJSON, err := yaml.YAMLToJSON(*raw)
+	if err != nil {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "InvalidDefinition", fmt.Sprintf("DefinitionUri %v %v", s3URI, err.Error()))
+	}
+
+	var definition interface{}
+	if err := json.Unmarshal(definitionJSON, &definition); err != nil {
+		return nil, resourceError(res, resourceName, "DefinitionUri", "InvalidDefinition", fmt.Sprintf("DefinitionUri %v %v", s3URI, err.Error()))
+	}
+
+	return definition, nil
+}
+
+// ValidateStateMachineDefinition checks every Task invokes a function in the template
+// or a Lambda with the correct tags, including Tasks in Parallel branches and Map iterators
+func ValidateStateMachineDefinition(
+	template *cloudformation.Template,
+	projectName, configName, resourceName string,
+	res *serverless.StateMachine,
+	definition interface{},
+	lambdac aws.LambdaAPI,
+) error {
+	errs := ValidationErrors{}
+
+	target := func(path string, value interface{}) {
+		if err := stateMachineTarget(template, projectName, configName, res.DefinitionSubstitutions, value, lambdac); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path, "UnsupportedResource", fmt.Sprintf("%v %v", path, err.Error())))
+		}
+	}
+
+	var validateStates func(path string, definition interface{})
+	validateStates = func(path string, definition interface{}) {
+		def, _ := definition.(map[string]interface{})
+		states, ok := def["States"].(map[string]interface{})
+		if !ok {
+			errs = append(errs, resourceError(res, resourceName, path, "InvalidDefinition", fmt.Sprintf("%v.States must be an object", path)))
+			return
+		}
+
+		for _, name := range sortedKeys(states) {
+			state, _ := states[name].(map[string]interface{})
+			statePath := fmt.Sprintf("%v.States.%v", path, name)
+
+			switch state["Type"] {
+			case "Task":
+				resource, _ := state["Resource"].(string)
+				switch {
+				case strings.HasPrefix(resource, "arn:aws:states:::lambda:invoke"):
+					params, _ := state["Parameters"].(map[string]interface{})
+					target(fmt.Sprintf("%v.Parameters.FunctionName", statePath), params["FunctionName"])
+				case strings.HasPrefix(resource, "arn:aws:states:::"):
+					errs = append(errs, resourceError(res, resourceName, fmt.Sprintf("%v.Resource", statePath), "UnsupportedResource", fmt.Sprintf("%v.Resource integration %v not supported", statePath, resource)))
+				default:
+					target(fmt.Sprintf("%v.Resource", statePath), state["Resource"])
+				}
+			case "Parallel":
+				branches, _ := state["Branches"].([]interface{})
+				for i, branch := range branches {
+					validateStates(fmt.Sprintf("%v.Branches[%v]", statePath, i), branch)
+				}
+			case "Map":
+				for _, key := range []string{"Iterator", "ItemProcessor"} {
+					if iterator, ok := state[key]; ok {
+						validateStates(fmt.Sprintf("%v.%v", statePath, key), iterator)
+					}
+				}
+			}
+		}
+	}
+
+	validateStates("Definition", definition)
+
+	return errs.OrNil()
+}
+
+var substitutionRegexp = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+
+// stateMachineTarget allows a !Ref or !GetAtt to a function in the template,
+// or a Lambda with the correct tags, optionally via a DefinitionSubstitutions ${name}
+func stateMachineTarget(
+	template *cloudformation.Template,
+	projectName, configName string,
+	substitutions map[string]string,
+	value interface{},
+	lambdac aws.LambdaAPI,
+) error {
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return fmt.Errorf("must be a Lambda function")
+	}
+
+	if match := substitutionRegexp.FindStringSubmatch(str); match != nil {
+		substitution, ok := substitutions[match[1]]
+		if !ok {
+			return fmt.Errorf("DefinitionSubstitutions %v not defined", match[1])
+		}
+		str = substitution
+	}
+
+	if strings.Contains(str, "${") {
+		return fmt.Errorf("%v must be a single DefinitionSubstitutions ${name}", str)
+	}
+
+	name := ""
+	if ref, err := decodeRef(str); err == nil {
+		name = ref
+	} else if getAtt, err := decodeGetAtt(str); err == nil {
+		name = getAtt[0]
+	} else if isIntrinsic(str) {
+		return fmt.Errorf("must be !Ref, !GetAtt or a Lambda ARN")
+	}
+
+	if name != "" {
+		if res, ok := template.Resources[name]; !ok || res.AWSCloudFormationType() != "AWS::Serverless::Function" {
+			return fmt.Errorf("%v is not a function in the template", name)
+		}
+		return nil
+	}
+
+	fun, err := lambda.FindFunction(lambdac, str)
+	if err != nil {
+		return fmt.Errorf("Lambda %v %v", str, err.Error())
+	}
+
+	if err := hasCorrectTags(projectName, configName, convTagMap(fun.Tags)); err != nil {
+		return fmt.Errorf("Lambda %v %v", str, err.Error())
+	}
+
+	return nil
+}
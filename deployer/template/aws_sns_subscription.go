@@ -0,0 +1,75 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/sns"
+)
+
+// AWS::SNS::Subscription
+
+func ValidateAWSSNSSubscription(
+	projectName, configName, resourceName string,
+	template *cloudformation.Template,
+	res *sns.Subscription,
+	limits Limits,
+) error {
+
+	ref, err := decodeRef(res.TopicArn)
+	if err != nil || ref == "" {
+		return resourceError(res, resourceName, "TopicArn", "MustBeRef", "TopicArn must be !Ref")
+	}
+
+	if topic, ok := template.Resources[ref]; !ok || topic.AWSCloudFormationType() != "AWS::SNS::Topic" {
+		return resourceError(res, resourceName, "TopicArn", "ResourceNotFound", fmt.Sprintf("TopicArn !Ref %v is not an AWS::SNS::Topic in the template", ref))
+	}
+
+	if res.Region != "" {
+		return resourceError(res, resourceName, "Region", "UnsupportedProperty", "Region not supported")
+	}
+
+	if res.SubscriptionRoleArn != "" {
+		return resourceError(res, resourceName, "SubscriptionRoleArn", "UnsupportedProperty", "SubscriptionRoleArn not supported")
+	}
+
+	if err := validateSubscriptionEndpoint(template, res.Protocol, res.Endpoint, limits); err != nil {
+		return resourceError(res, resourceName, "Endpoint", "UnsupportedEndpoint", fmt.Sprintf("Endpoint %v", err.Error()))
+	}
+
+	return nil
+}
+
+// validateSubscriptionEndpoint allows lambda and sqs subscriptions to resources in the template,
+// and any other protocol only to endpoints allowed by the limits
+func validateSubscriptionEndpoint(template *cloudformation.Template, protocol, endpoint string, limits Limits) error {
+	local := map[string]string{
+		"lambda": "AWS::Serverless::Function",
+		"sqs":    "AWS::SQS::Queue",
+	}
+
+	if resourceType, ok := local[protocol]; ok {
+		getAtt, err := decodeGetAtt(endpoint)
+		if err != nil || len(getAtt) != 2 || getAtt[1] != "Arn" {
+			return fmt.Errorf("for protocol %v must be !GetAtt <%v>.Arn", protocol, resourceType)
+		}
+
+		if res, ok := template.Resources[getAtt[0]]; !ok || res.AWSCloudFormationType() != resourceType {
+			return fmt.Errorf("%v is not an %v in the template", getAtt[0], resourceType)
+		}
+
+		return nil
+	}
+
+	if endpoint == "" || isIntrinsic(endpoint) {
+		return fmt.Errorf("for protocol %q must be a literal endpoint", protocol)
+	}
+
+	for _, allowed := range limits.SNSEndpoints {
+		if endpoint == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%v is not an allowed SNS endpoint", endpoint)
+}
@@ -0,0 +1,55 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/sns"
+	"github.com/coinbase/fenrir/aws"
+	"github.com/coinbase/fenrir/aws/kms"
+)
+
+// AWS::SNS::Topic
+
+func ValidateAWSSNSTopic(
+	projectName, configName, resourceName string,
+	template *cloudformation.Template,
+	res *sns.Topic,
+	limits Limits,
+	kmsc aws.KMSAPI,
+) error {
+
+	if res.TopicName != "" {
+		return resourceError(res, resourceName, "TopicName", "NameOverwritten", "Names are overwritten")
+	}
+
+	// FIFO topic names must end in .fifo
+	if res.FifoTopic {
+		res.TopicName = normalizeName("fenrir", projectName, configName, resourceName, 256-len(".fifo")) + ".fifo"
+	} else {
+		res.TopicName = normalizeName("fenrir", projectName, configName, resourceName, 256)
+	}
+
+	res.Tags = forceTags(res.Tags, projectName, configName, resourceName)
+
+	if res.KmsMasterKeyId != "" {
+		key, err := kms.FindKey(kmsc, res.KmsMasterKeyId)
+		if err != nil {
+			return resourceError(res, resourceName, "KmsMasterKeyId", "ResourceNotFound", fmt.Sprintf("KmsMasterKeyId %v", err.Error()))
+		}
+
+		if err := hasCorrectTags(projectName, configName, key.Tags); err != nil {
+			return resourceError(res, resourceName, "KmsMasterKeyId", "IncorrectTags", fmt.Sprintf("KmsMasterKeyId %v", err.Error()))
+		}
+	}
+
+	errs := ValidationErrors{}
+	for i, sub := range res.Subscription {
+		path := fmt.Sprintf("Subscription[%v]", i)
+		if err := validateSubscriptionEndpoint(template, sub.Protocol, sub.Endpoint, limits); err != nil {
+			errs = append(errs, resourceError(res, resourceName, path, "UnsupportedEndpoint", fmt.Sprintf("%v %v", path, err.Error())))
+		}
+	}
+
+	return errs.OrNil()
+}
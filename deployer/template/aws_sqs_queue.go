@@ -19,7 +19,7 @@ func ValidateAWSSQSQueue(
 	}
 
 	if res.QueueName != "" {
-		return resourceError(res, resourceName, "Names are overwritten")
+		return resourceError(res, resourceName, "QueueName", "NameOverwritten", "Names are overwritten")
 	}
 
 	res.QueueName = normalizeName("fenrir", projectName, configName, resourceName, 255)
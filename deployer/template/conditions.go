@@ -0,0 +1,509 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StaticValues are the values known before the release is deployed
+// that Mappings and Conditions can be evaluated against
+type StaticValues struct {
+	ProjectName string
+	ConfigName  string
+	Region      string
+	AccountID   string
+	Parameters  map[string]string
+}
+
+// ResolveConditions evaluates Fn::FindInMap, Fn::If, Fn::Equals and Condition in the JSON template
+// removing resources and outputs whose Condition is false along with the Mappings and Conditions sections.
+// A !Ref to a parameter with a value, or a Default, is replaced with the value.
+// Intrinsics can be objects or goformation's base64 encoded strings, which are left encoded
+func ResolveConditions(templateJSON []byte, values StaticValues) ([]byte, error) {
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal(templateJSON, &tmpl); err != nil {
+		return nil, err
+	}
+
+	r := newResolver(tmpl, values)
+	errs := ValidationErrors{}
+
+	// Every condition must resolve even if nothing uses it
+	for _, name := range sortedKeys(r.conditions) {
+		if _, err := r.condition(name); err != nil {
+			errs = append(errs, conditionError(name, "", err.Error()))
+		}
+	}
+
+	for _, section := range []string{"Resources", "Outputs"} {
+		entries, _ := tmpl[section].(map[string]interface{})
+		for _, name := range sortedKeys(entries) {
+			if err := r.resolveEntry(section, entries, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		// Every entry may have been removed
+		if entries != nil && len(entries) == 0 {
+			delete(tmpl, section)
+		}
+	}
+
+	if globals, ok := tmpl["Globals"]; ok {
+		resolved, _, err := r.resolve(globals, "")
+		if err != nil {
+			err.ResourceName, err.ResourceType = "Globals", "Globals"
+			errs = append(errs, err)
+		}
+		tmpl["Globals"] = resolved
+	}
+
+	if err := errs.OrNil(); err != nil {
+		return nil, err
+	}
+
+	delete(tmpl, "Conditions")
+	delete(tmpl, "Mappings")
+
+	return json.Marshal(tmpl)
+}
+
+type resolver struct {
+	mappings       map[string]interface{}
+	conditions     map[string]interface{}
+	parameters     map[string]string
+	parameterTypes map[string]string
+	values         StaticValues
+
+	evaluated  map[string]bool
+	evaluating map[string]bool
+}
+
+func newResolver(tmpl map[string]interface{}, values StaticValues) *resolver {
+	r := &resolver{
+		parameters:     map[string]string{},
+		parameterTypes: map[string]string{},
+		values:         values,
+		evaluated:      map[string]bool{},
+		evaluating:     map[string]bool{},
+	}
+
+	r.mappings, _ = tmpl["Mappings"].(map[string]interface{})
+	r.conditions, _ = tmpl["Conditions"].(map[string]interface{})
+
+	// Parameter values, or their defaults
+	parameters, _ := tmpl["Parameters"].(map[string]interface{})
+	for name, raw := range parameters {
+		param, _ := raw.(map[string]interface{})
+		r.parameterTypes[name], _ = param["Type"].(string)
+
+		if value, ok := values.Parameters[name]; ok {
+			r.parameters[name] = value
+			continue
+		}
+
+		if d, ok := param["Default"]; ok {
+			r.parameters[name] = fmt.Sprintf("%v", d)
+		}
+	}
+
+	return r
+}
+
+// resolveEntry resolves a resource or output, removing it if its Condition is false
+func (r *resolver) resolveEntry(section string, entries map[string]interface{}, name string) error {
+	entry, ok := entries[name].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	resourceType, _ := entry["Type"].(string)
+	if section == "Outputs" {
+		resourceType = "Output"
+	}
+
+	entryError := func(path, msg string) *ValidationError {
+		return &ValidationError{
+			ResourceName: name,
+			ResourceType: resourceType,
+			Path:         path,
+			Rule:         "Unresolvable",
+			Message:      msg,
+		}
+	}
+
+	if raw, ok := entry["Condition"]; ok {
+		condName, ok := raw.(string)
+		if !ok {
+			return entryError("Condition", "Condition must be a string")
+		}
+
+		enabled, err := r.condition(condName)
+		if err != nil {
+			return entryError("Condition", err.Error())
+		}
+
+		if !enabled {
+			delete(entries, name)
+			return nil
+		}
+
+		delete(entry, "Condition")
+	}
+
+	for _, key := range sortedKeys(entry) {
+		resolved, removed, err := r.resolve(entry[key], key)
+		if err != nil {
+			err.ResourceName, err.ResourceType = name, resourceType
+			return err
+		}
+
+		if removed {
+			delete(entry, key)
+			continue
+		}
+
+		entry[key] = resolved
+	}
+
+	return nil
+}
+
+// resolve replaces Fn::If, Fn::FindInMap, Fenrir pseudo parameters and parameters with values in value.
+// removed is true if the value is AWS::NoValue
+func (r *resolver) resolve(value interface{}, path string) (interface{}, bool, *ValidationError) {
+	unresolvable := func(errStr string) (interface{}, bool, *ValidationError) {
+		return nil, false, &ValidationError{Path: path, Rule: "Unresolvable", Message: errStr}
+	}
+
+	if fn, args, ok := intrinsic(value); ok {
+		switch fn {
+		case "Fn::If":
+			list, ok := args.([]interface{})
+			if !ok || len(list) != 3 {
+				return unresolvable("Fn::If must have a condition and two values")
+			}
+
+			condName, ok := list[0].(string)
+			if !ok {
+				return unresolvable("Fn::If condition must be a name")
+			}
+
+			enabled, err := r.condition(condName)
+			if err != nil {
+				return unresolvable(err.Error())
+			}
+
+			if enabled {
+				return r.resolve(list[1], path)
+			}
+			return r.resolve(list[2], path)
+		case "Fn::FindInMap":
+			found, err := r.findInMap(args)
+			if err != nil {
+				return unresolvable(err.Error())
+			}
+			return found, false, nil
+		case "Ref":
+			switch args {
+			case "AWS::NoValue":
+				return nil, true, nil
+			case "Fenrir::ProjectName":
+				return r.values.ProjectName, false, nil
+			case "Fenrir::ConfigName":
+				return r.values.ConfigName, false, nil
+			}
+
+			// Parameters are substituted so goformation parses them into number properties
+			if name, ok := args.(string); ok {
+				if _, ok := r.parameters[name]; ok {
+					substituted, err := r.parameter(name)
+					if err != nil {
+						return unresolvable(err.Error())
+					}
+					return substituted, false, nil
+				}
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			resolved, removed, err := r.resolve(v[key], joinPath(path, key))
+			if err != nil {
+				return nil, false, err
+			}
+
+			if removed {
+				delete(v, key)
+				continue
+			}
+
+			v[key] = resolved
+		}
+
+		return v, false, nil
+	case []interface{}:
+		list := []interface{}{}
+		for i, item := range v {
+			resolved, removed, err := r.resolve(item, fmt.Sprintf("%v[%v]", path, i))
+			if err != nil {
+				return nil, false, err
+			}
+
+			if !removed {
+				list = append(list, resolved)
+			}
+		}
+
+		return list, false, nil
+	}
+
+	return value, false, nil
+}
+
+// condition evaluates the named condition once
+func (r *resolver) condition(name string) (bool, error) {
+	if value, ok := r.evaluated[name]; ok {
+		return value, nil
+	}
+
+	expr, ok := r.conditions[name]
+	if !ok {
+		return false, fmt.Errorf("Condition %q not found", name)
+	}
+
+	if r.evaluating[name] {
+		return false, fmt.Errorf("Condition %q is circular", name)
+	}
+
+	r.evaluating[name] = true
+	value, err := r.evaluate(expr)
+	r.evaluating[name] = false
+
+	if err != nil {
+		return false, err
+	}
+
+	r.evaluated[name] = value
+	return value, nil
+}
+
+// evaluate a condition function
+func (r *resolver) evaluate(expr interface{}) (bool, error) {
+	fn, args, ok := intrinsic(expr)
+	if !ok {
+		return false, fmt.Errorf("condition must be a single condition function")
+	}
+
+	if fn == "Condition" {
+		name, ok := args.(string)
+		if !ok {
+			return false, fmt.Errorf("Condition must be a name")
+		}
+		return r.condition(name)
+	}
+
+	list, ok := args.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%v must have a list of arguments", fn)
+	}
+
+	switch fn {
+	case "Fn::Equals":
+		if len(list) != 2 {
+			return false, fmt.Errorf("Fn::Equals must have two values")
+		}
+
+		left, err := r.static(list[0])
+		if err != nil {
+			return false, err
+		}
+
+		right, err := r.static(list[1])
+		if err != nil {
+			return false, err
+		}
+
+		return left == right, nil
+	case "Fn::Not":
+		if len(list) != 1 {
+			return false, fmt.Errorf("Fn::Not must have one condition")
+		}
+
+		value, err := r.evaluate(list[0])
+		return !value, err
+	case "Fn::And", "Fn::Or":
+		if len(list) < 2 {
+			return false, fmt.Errorf("%v must have at least two conditions", fn)
+		}
+
+		// Evaluate every condition so none are left unchecked
+		and, or := true, false
+		for _, item := range list {
+			value, err := r.evaluate(item)
+			if err != nil {
+				return false, err
+			}
+			and, or = and && value, or || value
+		}
+
+		if fn == "Fn::And" {
+			return and, nil
+		}
+		return or, nil
+	}
+
+	return false, fmt.Errorf("%v cannot be resolved statically", fn)
+}
+
+// static returns the string value of a literal, a known !Ref or a Fn::FindInMap
+func (r *resolver) static(value interface{}) (string, error) {
+	if fn, args, ok := intrinsic(value); ok {
+		switch fn {
+		case "Ref":
+			name, _ := args.(string)
+			return r.ref(name)
+		case "Fn::FindInMap":
+			found, err := r.findInMap(args)
+			if err != nil {
+				return "", err
+			}
+			return r.static(found)
+		}
+
+		return "", fmt.Errorf("%v cannot be resolved statically", fn)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	return "", fmt.Errorf("value %v cannot be resolved statically", value)
+}
+
+// ref returns the known value of a pseudo parameter or template parameter
+func (r *resolver) ref(name string) (string, error) {
+	switch name {
+	case "AWS::Region":
+		return r.values.Region, nil
+	case "AWS::AccountId":
+		return r.values.AccountID, nil
+	case "Fenrir::ProjectName":
+		return r.values.ProjectName, nil
+	case "Fenrir::ConfigName":
+		return r.values.ConfigName, nil
+	}
+
+	if value, ok := r.parameters[name]; ok {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("!Ref %v cannot be resolved statically", name)
+}
+
+// parameter returns the value of a template parameter as its Type,
+// a number for Number and a list of strings for CommaDelimitedList
+func (r *resolver) parameter(name string) (interface{}, error) {
+	value := r.parameters[name]
+
+	switch r.parameterTypes[name] {
+	case "Number":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Parameter %v value %q is not a Number", name, value)
+		}
+		return number, nil
+	case "CommaDelimitedList":
+		list := []interface{}{}
+		for _, item := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list, nil
+	}
+
+	return value, nil
+}
+
+// findInMap returns the value of Mappings[map][top][second]
+func (r *resolver) findInMap(args interface{}) (interface{}, error) {
+	list, ok := args.([]interface{})
+	if !ok || len(list) != 3 {
+		return nil, fmt.Errorf("Fn::FindInMap must have a map name and two keys")
+	}
+
+	keys := []string{}
+	for _, arg := range list {
+		key, err := r.static(arg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	var value interface{} = r.mappings
+	for i, key := range keys {
+		m, _ := value.(map[string]interface{})
+		if value, ok = m[key]; !ok {
+			return nil, fmt.Errorf("Fn::FindInMap %v not found in Mappings", keys[:i+1])
+		}
+	}
+
+	return value, nil
+}
+
+// intrinsic returns the function and arguments if value is a single key intrinsic object
+// or a base64 encoded one
+func intrinsic(value interface{}) (string, interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if str, isStr := value.(string); isStr {
+		m, ok = decodeIntrinsic(str)
+	}
+
+	if !ok || len(m) != 1 {
+		return "", nil, false
+	}
+
+	for fn, args := range m {
+		if fn == "Ref" || fn == "Condition" || strings.HasPrefix(fn, "Fn::") {
+			return fn, args, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return fmt.Sprintf("%v.%v", path, key)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func conditionError(name, path, errStr string) *ValidationError {
+	return &ValidationError{
+		ResourceName: name,
+		ResourceType: "Condition",
+		Path:         path,
+		Rule:         "Unresolvable",
+		Message:      errStr,
+	}
+}
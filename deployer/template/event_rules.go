@@ -0,0 +1,213 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rateRegexp = regexp.MustCompile(`^rate\((\S+) (\S+)\)$`)
+var cronRegexp = regexp.MustCompile(`^cron\((.*)\)$`)
+var cronFieldRegexp = regexp.MustCompile(`^[0-9A-Za-z*?,/#\-]+$`)
+
+// ValidateScheduleExpression parses rate(...) and cron(...) expressions and
+// checks they run no more often than MinScheduleRate
+func ValidateScheduleExpression(expression string, limits Limits) error {
+	interval, err := scheduleInterval(expression)
+	if err != nil {
+		return err
+	}
+
+	if interval < limits.MinScheduleRate {
+		return fmt.Errorf("Schedule %v runs every %v, more often than the minimum %v", expression, interval, limits.MinScheduleRate)
+	}
+
+	return nil
+}
+
+// scheduleInterval returns the shortest time between runs of the schedule
+func scheduleInterval(expression string) (time.Duration, error) {
+	if match := rateRegexp.FindStringSubmatch(expression); match != nil {
+		value, err := strconv.Atoi(match[1])
+		if err != nil || value < 1 {
+			return 0, fmt.Errorf("Schedule %v rate must be a positive number", expression)
+		}
+
+		units := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+		unit, ok := units[strings.TrimSuffix(match[2], "s")]
+		if !ok {
+			return 0, fmt.Errorf("Schedule %v unit must be minutes, hours or days", expression)
+		}
+
+		// rate(1 minute) is singular, every other value plural
+		if (value == 1) == strings.HasSuffix(match[2], "s") {
+			return 0, fmt.Errorf("Schedule %v unit must be singular only for 1", expression)
+		}
+
+		return time.Duration(value) * unit, nil
+	}
+
+	match := cronRegexp.FindStringSubmatch(expression)
+	if match == nil {
+		return 0, fmt.Errorf("Schedule %v must be rate(...) or cron(...)", expression)
+	}
+
+	// Minutes Hours Day-of-month Month Day-of-week Year
+	fields := strings.Fields(match[1])
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("Schedule %v cron must have 6 fields", expression)
+	}
+
+	for _, field := range fields {
+		if !cronFieldRegexp.MatchString(field) {
+			return 0, fmt.Errorf("Schedule %v cron field %q invalid", expression, field)
+		}
+	}
+
+	if (fields[2] == "?") == (fields[4] == "?") {
+		return 0, fmt.Errorf("Schedule %v cron must use ? in one of Day-of-month or Day-of-week", expression)
+	}
+
+	minutes, err := cronValues(fields[0], 59)
+	if err != nil {
+		return 0, fmt.Errorf("Schedule %v cron Minutes %v", expression, err.Error())
+	}
+
+	hours, err := cronValues(fields[1], 23)
+	if err != nil {
+		return 0, fmt.Errorf("Schedule %v cron Hours %v", expression, err.Error())
+	}
+
+	if len(minutes) > 1 {
+		return minGap(minutes, 60) * time.Minute, nil
+	}
+
+	if len(hours) > 1 {
+		return minGap(hours, 24) * time.Hour, nil
+	}
+
+	return 24 * time.Hour, nil
+}
+
+// cronValues expands a cron field of *, values, ranges and /steps into the values it matches
+func cronValues(field string, max int) ([]int, error) {
+	set := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		rangeStep := strings.SplitN(part, "/", 2)
+		start, end, step := 0, max, 1
+
+		if len(rangeStep) == 2 {
+			s, err := strconv.Atoi(rangeStep[1])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("step %q invalid", rangeStep[1])
+			}
+			step = s
+		}
+
+		switch bounds := strings.SplitN(rangeStep[0], "-", 2); {
+		case rangeStep[0] == "*":
+		case len(bounds) == 2:
+			s, err1 := strconv.Atoi(bounds[0])
+			e, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("range %q invalid", rangeStep[0])
+			}
+			start, end = s, e
+		default:
+			s, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("value %q invalid", rangeStep[0])
+			}
+
+			// A single value with a step runs from it to the max
+			start = s
+			if len(rangeStep) == 1 {
+				end = s
+			}
+		}
+
+		if start < 0 || end > max || start > end {
+			return nil, fmt.Errorf("%q out of range 0-%v", part, max)
+		}
+
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+
+	values := []int{}
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	return values, nil
+}
+
+// minGap is the smallest difference between sorted values, wrapping around at cycle
+func minGap(values []int, cycle int) time.Duration {
+	gap := values[0] + cycle - values[len(values)-1]
+	for i := 1; i < len(values); i++ {
+		if d := values[i] - values[i-1]; d < gap {
+			gap = d
+		}
+	}
+	return time.Duration(gap)
+}
+
+// ValidateEventInput checks Input is JSON and InputPath is a JSON path, only one can be set
+func ValidateEventInput(input, inputPath string) error {
+	if input != "" && inputPath != "" {
+		return fmt.Errorf("only one of Input and InputPath can be set")
+	}
+
+	if input != "" && !json.Valid([]byte(input)) {
+		return fmt.Errorf("Input must be valid JSON")
+	}
+
+	if inputPath != "" && !strings.HasPrefix(inputPath, "$") {
+		return fmt.Errorf("InputPath must be a JSON path starting with $")
+	}
+
+	return nil
+}
+
+// ValidateEventPattern checks the pattern matches specific sources so it cannot match every event on the bus.
+// Patterns on a shared bus can only match the Sources allowed for the project, if they are limited
+func ValidateEventPattern(pattern interface{}, sharedBus bool, limits Limits) error {
+	// The pattern can be a JSON string
+	if str, ok := pattern.(string); ok {
+		if err := json.Unmarshal([]byte(str), &pattern); err != nil {
+			return fmt.Errorf("Pattern must be valid JSON %v", err.Error())
+		}
+	}
+
+	obj, ok := pattern.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return fmt.Errorf("Pattern must be an object that matches specific events")
+	}
+
+	sources, ok := obj["source"].([]interface{})
+	if !ok || len(sources) == 0 {
+		return fmt.Errorf("Pattern must match a list of source values")
+	}
+
+	for _, raw := range sources {
+		// Content filters like prefix or anything-but can match every source
+		source, ok := raw.(string)
+		if !ok || source == "" {
+			return fmt.Errorf("Pattern source values must be exact strings")
+		}
+
+		if sharedBus && limits.Sources != nil && !inSlice(source, limits.Sources) {
+			return fmt.Errorf("Pattern source %q is not allowed for the project", source)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,8 @@ package template
 
 import (
 	"io/ioutil"
+	"time"
 
-	goformation "github.com/awslabs/goformation/v4"
 	"github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/intrinsics"
 	"github.com/coinbase/fenrir/aws/mocks"
@@ -19,21 +19,22 @@ func MockTemplate(fileName string) (*cloudformation.Template, error) {
 		return nil, err
 	}
 
-	template, err := goformation.ParseYAMLWithOptions([]byte(string(basicSAM)), &intrinsics.ProcessorOptions{
-		IntrinsicHandlerOverrides: cloudformation.EncoderIntrinsics,
-	})
-
+	rawJSON, err := intrinsics.ProcessYAML(basicSAM, &intrinsics.ProcessorOptions{NoProcess: true})
 	if err != nil {
 		return nil, err
 	}
 
-	return template, nil
+	return ParseTemplate(rawJSON)
 }
 
 func MockS3SHA() string {
 	return "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 }
 
+func MockLimits() Limits {
+	return Limits{MinScheduleRate: time.Minute, Sources: []string{"aws.ec2"}, LogRetentionInDays: 30, Domains: []string{"example.com"}}
+}
+
 func MockAwsClients() *mocks.MockClients {
 
 	awsc := mocks.MockAWS()
@@ -48,6 +49,7 @@ func MockAwsClients() *mocks.MockClients {
 	// Event Resources
 	tags := map[string]string{"ProjectName": "project", "ConfigName": "development"}
 	awsc.S3Client.SetBucketTags("bucket", tags, nil)
+	awsc.EBClient.AddEventBus("team", "arn:aws:events:us-east-1:000000000000:event-bus/team", tags)
 
 	// Bad Resources
 	awsc.EC2Client.AddSecurityGroup("sg_bad", "bad", "development", "rn", nil)
@@ -62,3 +62,24 @@ func decodeGetAtt(value string) ([]string, error) {
 
 	return intrinsic["Fn::GetAtt"], nil
 }
+
+// isIntrinsic returns true if the value is any intrinsic function
+func isIntrinsic(value string) bool {
+	_, ok := decodeIntrinsic(value)
+	return ok
+}
+
+// decodeIntrinsic returns the object of a base64 encoded intrinsic function
+func decodeIntrinsic(value string) (map[string]interface{}, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+
+	var intrinsic map[string]interface{}
+	if err := json.Unmarshal(decoded, &intrinsic); err != nil {
+		return nil, false
+	}
+
+	return intrinsic, true
+}
@@ -0,0 +1,192 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+)
+
+// ValidateParameters checks the template Parameters are supported and the
+// values, or their defaults, satisfy their constraints
+func ValidateParameters(parameters cloudformation.Parameters, values map[string]string) error {
+	errs := ValidationErrors{}
+
+	names := []string{}
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateParameter(name, parameters[name], values); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	valueNames := []string{}
+	for name := range values {
+		valueNames = append(valueNames, name)
+	}
+	sort.Strings(valueNames)
+
+	for _, name := range valueNames {
+		if _, ok := parameters[name]; !ok {
+			errs = append(errs, parameterError(name, "", "UnknownParameter", "value given for a parameter not in the template"))
+		}
+	}
+
+	return errs.OrNil()
+}
+
+func validateParameter(name string, param cloudformation.Parameter, values map[string]string) error {
+	if param.NoEcho {
+		return parameterError(name, "NoEcho", "UnsupportedProperty", "NoEcho parameters are not supported, values are stored in the release")
+	}
+
+	if strings.HasPrefix(param.Type, "AWS::SSM::") {
+		return parameterError(name, "Type", "UnsupportedValue", fmt.Sprintf("SSM parameter Type %q is not supported", param.Type))
+	}
+
+	value, ok := values[name]
+	if !ok {
+		if param.Default == nil {
+			return parameterError(name, "", "RequiredProperty", "no value or Default")
+		}
+		value = fmt.Sprintf("%v", param.Default)
+	}
+
+	switch param.Type {
+	case "String":
+		if err := validateParameterString(param, value); err != nil {
+			return parameterError(name, "", "InvalidValue", err.Error())
+		}
+	case "Number":
+		if err := validateParameterNumber(param, value); err != nil {
+			return parameterError(name, "", "InvalidValue", err.Error())
+		}
+	case "CommaDelimitedList":
+		for _, item := range strings.Split(value, ",") {
+			if err := validateParameterString(param, strings.TrimSpace(item)); err != nil {
+				return parameterError(name, "", "InvalidValue", err.Error())
+			}
+		}
+	default:
+		return parameterError(name, "Type", "UnsupportedValue", fmt.Sprintf("Type %q must be String Number or CommaDelimitedList", param.Type))
+	}
+
+	return nil
+}
+
+// SchemaParameters returns the parameters as goformations SAM schema expects them, with
+// Default and the constraints as strings, as its Parameter type unmarshals them as numbers
+func SchemaParameters(parameters cloudformation.Parameters) map[string]interface{} {
+	schemaParams := map[string]interface{}{}
+	for name, param := range parameters {
+		raw := map[string]interface{}{"Type": param.Type}
+
+		if param.Default != nil {
+			raw["Default"] = fmt.Sprintf("%v", param.Default)
+		}
+
+		for key, value := range map[string]string{
+			"Description":           param.Description,
+			"AllowedPattern":        param.AllowedPattern,
+			"ConstraintDescription": param.ConstraintDescription,
+		} {
+			if value != "" {
+				raw[key] = value
+			}
+		}
+
+		for key, value := range map[string]float64{
+			"MinLength": float64(param.MinLength),
+			"MaxLength": float64(param.MaxLength),
+			"MinValue":  param.MinValue,
+			"MaxValue":  param.MaxValue,
+		} {
+			if value != 0 {
+				raw[key] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+
+		if len(param.AllowedValues) > 0 {
+			raw["AllowedValues"] = param.AllowedValues
+		}
+
+		if param.NoEcho {
+			raw["NoEcho"] = true
+		}
+
+		schemaParams[name] = raw
+	}
+
+	return schemaParams
+}
+
+func validateParameterString(param cloudformation.Parameter, value string) error {
+	if param.MinLength > 0 && len(value) < param.MinLength {
+		return fmt.Errorf("%q shorter than MinLength %v", value, param.MinLength)
+	}
+
+	if param.MaxLength > 0 && len(value) > param.MaxLength {
+		return fmt.Errorf("%q longer than MaxLength %v", value, param.MaxLength)
+	}
+
+	if param.AllowedPattern != "" {
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%v)$", param.AllowedPattern))
+		if err != nil {
+			return fmt.Errorf("AllowedPattern %v", err.Error())
+		}
+
+		if !re.MatchString(value) {
+			return fmt.Errorf("%q does not match AllowedPattern %q", value, param.AllowedPattern)
+		}
+	}
+
+	return validateAllowedValues(param, value)
+}
+
+func validateParameterNumber(param cloudformation.Parameter, value string) error {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a Number", value)
+	}
+
+	if param.MinValue != 0 && number < param.MinValue {
+		return fmt.Errorf("%v less than MinValue %v", value, param.MinValue)
+	}
+
+	if param.MaxValue != 0 && number > param.MaxValue {
+		return fmt.Errorf("%v greater than MaxValue %v", value, param.MaxValue)
+	}
+
+	return validateAllowedValues(param, value)
+}
+
+func validateAllowedValues(param cloudformation.Parameter, value string) error {
+	if len(param.AllowedValues) == 0 {
+		return nil
+	}
+
+	for _, allowed := range param.AllowedValues {
+		if allowed == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q not in AllowedValues %v", value, strings.Join(param.AllowedValues, ", "))
+}
+
+func parameterError(name, path, rule, errStr string) *ValidationError {
+	return &ValidationError{
+		ResourceName: name,
+		ResourceType: "Parameter",
+		Path:         path,
+		Rule:         rule,
+		Message:      errStr,
+	}
+}
@@ -0,0 +1,525 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	goformation "github.com/awslabs/goformation/v4"
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+	"github.com/awslabs/goformation/v4/intrinsics"
+)
+
+// PassthroughKey is the resource Metadata key that carries the SAM the goformation types drop
+// from the client to the deployer, where it is validated and restored into the deployed template
+const PassthroughKey = "FenrirPassthrough"
+
+// HttpApiType is the custom resource type AWS::Serverless::HttpApi is parsed as
+const HttpApiType = "Custom::FenrirHttpApi"
+
+// passthroughTypes are the resource types goformation does not have, they are parsed as custom resources
+var passthroughTypes = map[string]string{
+	"AWS::Serverless::HttpApi": HttpApiType,
+}
+
+// passthroughPolicies are the SAM policy templates goformation does not have, with their property
+var passthroughPolicies = map[string]string{
+	"SSMParameterReadPolicy":             "ParameterName",
+	"SecretsManagerGetSecretValuePolicy": "SecretArn",
+}
+
+// stateMachinePolicies are the SAM policy templates goformation has for state machines,
+// the other function policy templates are passed through so state machines get the same checks
+var stateMachinePolicies = map[string]bool{
+	"LambdaInvokePolicy":           true,
+	"StepFunctionsExecutionPolicy": true,
+}
+
+// passthroughEvents are the function event types goformation does not have, with their properties
+var passthroughEvents = map[string][]string{
+	"HttpApi": {"ApiId", "Method", "Path", "PayloadFormatVersion", "TimeoutInMillis", "Auth"},
+}
+
+// passthroughProperties are the properties goformation does not have by resource type, e.g. for container images
+var passthroughProperties = map[string]map[string][]string{
+	"AWS::Serverless::Function": {
+		"PackageType":   nil,
+		"ImageUri":      nil,
+		"ImageConfig":   {"Command", "EntryPoint", "WorkingDirectory"},
+		"Architectures": nil,
+	},
+	"AWS::Serverless::StateMachine": {
+		"PermissionsBoundary": nil,
+	},
+}
+
+// Passthrough is the FenrirPassthrough Metadata of a resource
+type Passthrough struct {
+	Policies []PassthroughPolicy     `json:",omitempty"`
+	Events   map[string]HttpApiEvent `json:",omitempty"`
+
+	PackageType   string       `json:",omitempty"`
+	ImageUri      string       `json:",omitempty"`
+	ImageConfig   *ImageConfig `json:",omitempty"`
+	Architectures []string     `json:",omitempty"`
+
+	PermissionsBoundary string `json:",omitempty"`
+}
+
+// ImageConfig overrides the container image settings of a function
+type ImageConfig struct {
+	Command          []string `json:",omitempty"`
+	EntryPoint       []string `json:",omitempty"`
+	WorkingDirectory string   `json:",omitempty"`
+}
+
+// PassthroughPolicy is a SAM policy template goformation does not have,
+// or for state machines a function policy template goformation only has for functions
+type PassthroughPolicy struct {
+	serverless.Function_SAMPolicyTemplate
+
+	SSMParameterReadPolicy *struct {
+		ParameterName string
+	} `json:",omitempty"`
+
+	SecretsManagerGetSecretValuePolicy *struct {
+		SecretArn string
+	} `json:",omitempty"`
+}
+
+// HttpApiEvent is a function HttpApi event
+type HttpApiEvent struct {
+	Type       string
+	Properties struct {
+		ApiId                string
+		Method               string `json:",omitempty"`
+		Path                 string `json:",omitempty"`
+		PayloadFormatVersion string `json:",omitempty"`
+		TimeoutInMillis      int    `json:",omitempty"`
+		Auth                 *struct {
+			Authorizer          string
+			AuthorizationScopes []string `json:",omitempty"`
+		} `json:",omitempty"`
+	}
+}
+
+// ParseTemplate parses a JSON SAM template with its intrinsics base64 encoded,
+// stashing what goformation would drop in the FenrirPassthrough Metadata
+func ParseTemplate(rawJSON []byte) (*cloudformation.Template, error) {
+	stashed, err := StashPassthrough(rawJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// process Globals
+	// Dont process intrinsics
+	return goformation.ParseJSONWithOptions(stashed, &intrinsics.ProcessorOptions{
+		IntrinsicHandlerOverrides: cloudformation.EncoderIntrinsics,
+	})
+}
+
+// IsPassthroughType is true for resource types that are parsed as custom resources
+func IsPassthroughType(resourceType string) bool {
+	_, ok := passthroughTypes[resourceType]
+	return ok
+}
+
+// SchemaPassthroughTypes removes the resources of passthrough types that the schema does not have
+func SchemaPassthroughTypes(resources map[string]interface{}) {
+	for name, raw := range resources {
+		res, _ := raw.(map[string]interface{})
+		if _, ok := passthroughResourceType(res["Type"]); ok {
+			delete(resources, name)
+		}
+	}
+}
+
+// SchemaImageFunctions sets a placeholder for the properties the schema requires that container image functions do not have
+func SchemaImageFunctions(resources map[string]interface{}) {
+	for _, raw := range resources {
+		res, _ := raw.(map[string]interface{})
+		if res["Type"] != "AWS::Serverless::Function" {
+			continue
+		}
+
+		metadata, _ := res["Metadata"].(map[string]interface{})
+		passthrough, _ := metadata[PassthroughKey].(map[string]interface{})
+		properties, _ := res["Properties"].(map[string]interface{})
+		if passthrough["PackageType"] != "Image" || properties == nil {
+			continue
+		}
+
+		for _, property := range []string{"CodeUri", "Handler", "Runtime"} {
+			if _, ok := properties[property]; !ok {
+				properties[property] = "image"
+			}
+		}
+	}
+}
+
+// passthroughResourceType returns the resource type a custom resource type is passed through as
+func passthroughResourceType(custom interface{}) (string, bool) {
+	for resourceType, c := range passthroughTypes {
+		if custom == c {
+			return resourceType, true
+		}
+	}
+
+	return "", false
+}
+
+// StashPassthrough renames the resource types goformation does not have to custom resources,
+// and moves the function and state machine properties, policy templates and events it does not have into the FenrirPassthrough Metadata
+func StashPassthrough(rawJSON []byte) ([]byte, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(rawJSON, &document); err != nil {
+		return nil, err
+	}
+
+	resources, _ := document["Resources"].(map[string]interface{})
+	for _, name := range sortedKeys(resources) {
+		resource, ok := resources[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		metadata, _ := resource["Metadata"].(map[string]interface{})
+		if _, ok := metadata[PassthroughKey]; ok {
+			return nil, fmt.Errorf("Resource %v: Metadata %v is reserved", name, PassthroughKey)
+		}
+
+		resourceType, _ := resource["Type"].(string)
+		if strings.HasPrefix(resourceType, "Custom::Fenrir") {
+			return nil, fmt.Errorf("Resource %v: Type %q is reserved", name, resourceType)
+		}
+
+		if custom, ok := passthroughTypes[resourceType]; ok {
+			resource["Type"] = custom
+			continue
+		}
+
+		resourceProperties, ok := passthroughProperties[resourceType]
+		if !ok {
+			continue
+		}
+
+		properties, _ := resource["Properties"].(map[string]interface{})
+		passthrough := map[string]interface{}{}
+
+		for property := range resourceProperties {
+			if value, ok := properties[property]; ok {
+				passthrough[property] = value
+				delete(properties, property)
+			}
+		}
+
+		if policies, ok := properties["Policies"].([]interface{}); ok {
+			kept, stashed := []interface{}{}, []interface{}{}
+			for _, policy := range policies {
+				if isPassthroughPolicy(policy) || resourceType == "AWS::Serverless::StateMachine" && isFunctionOnlyPolicy(policy) {
+					stashed = append(stashed, policy)
+				} else {
+					kept = append(kept, policy)
+				}
+			}
+
+			if len(stashed) > 0 {
+				passthrough["Policies"] = stashed
+				if len(kept) == 0 {
+					delete(properties, "Policies")
+				} else {
+					properties["Policies"] = kept
+				}
+			}
+		}
+
+		if events, ok := properties["Events"].(map[string]interface{}); ok && resourceType == "AWS::Serverless::Function" {
+			stashed := map[string]interface{}{}
+			for eventName, event := range events {
+				e, _ := event.(map[string]interface{})
+				eventType, _ := e["Type"].(string)
+				if _, ok := passthroughEvents[eventType]; ok {
+					stashed[eventName] = event
+					delete(events, eventName)
+				}
+			}
+
+			if len(stashed) > 0 {
+				passthrough["Events"] = stashed
+				if len(events) == 0 {
+					delete(properties, "Events")
+				}
+			}
+		}
+
+		if len(passthrough) == 0 {
+			continue
+		}
+
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+			resource["Metadata"] = metadata
+		}
+
+		metadata[PassthroughKey] = passthrough
+	}
+
+	return json.Marshal(document)
+}
+
+// RestorePassthrough renames the custom resources back and adds the FenrirPassthrough Metadata into
+// the resources of a template body, only restoring what GetPassthrough decodes as only that is validated
+func RestorePassthrough(templateBody []byte) ([]byte, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(templateBody, &document); err != nil {
+		return nil, err
+	}
+
+	resources, _ := document["Resources"].(map[string]interface{})
+	for _, name := range sortedKeys(resources) {
+		resource, ok := resources[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if resourceType, ok := passthroughResourceType(resource["Type"]); ok {
+			resource["Type"] = resourceType
+		}
+
+		metadata, _ := resource["Metadata"].(map[string]interface{})
+		raw, ok := metadata[PassthroughKey].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		delete(metadata, PassthroughKey)
+		if len(metadata) == 0 {
+			delete(resource, "Metadata")
+		}
+
+		resourceType, _ := resource["Type"].(string)
+		for key := range raw {
+			if key != "Policies" && (key != "Events" || resourceType != "AWS::Serverless::Function") && !isPassthroughProperty(resourceType, key, raw[key]) {
+				return nil, fmt.Errorf("Resource %v: %v %v not supported", name, PassthroughKey, key)
+			}
+		}
+
+		properties, _ := resource["Properties"].(map[string]interface{})
+		if properties == nil {
+			properties = map[string]interface{}{}
+			resource["Properties"] = properties
+		}
+
+		for property := range passthroughProperties[resourceType] {
+			if value, ok := raw[property]; ok {
+				properties[property] = value
+			}
+		}
+
+		stashed, _ := raw["Policies"].([]interface{})
+		for _, policy := range stashed {
+			if !isPassthroughPolicy(policy) && (resourceType != "AWS::Serverless::StateMachine" || !isFunctionOnlyPolicy(policy)) {
+				return nil, fmt.Errorf("Resource %v: %v policy %v not supported", name, PassthroughKey, policy)
+			}
+		}
+
+		if len(stashed) > 0 {
+			policies, _ := properties["Policies"].([]interface{})
+			properties["Policies"] = append(policies, stashed...)
+		}
+
+		stashedEvents, _ := raw["Events"].(map[string]interface{})
+		if len(stashedEvents) == 0 {
+			continue
+		}
+
+		events, _ := properties["Events"].(map[string]interface{})
+		if events == nil {
+			events = map[string]interface{}{}
+			properties["Events"] = events
+		}
+
+		for _, eventName := range sortedKeys(stashedEvents) {
+			if !isPassthroughEvent(stashedEvents[eventName]) {
+				return nil, fmt.Errorf("Resource %v: %v event %v not supported", name, PassthroughKey, eventName)
+			}
+
+			if _, ok := events[eventName]; ok {
+				return nil, fmt.Errorf("Resource %v: %v event %v is duplicated", name, PassthroughKey, eventName)
+			}
+
+			events[eventName] = stashedEvents[eventName]
+		}
+	}
+
+	return json.Marshal(document)
+}
+
+// GetPassthrough decodes the FenrirPassthrough Metadata of a resource, nil if it has none.
+// Unknown keys are errors so nothing is deployed without being validated
+func GetPassthrough(metadata map[string]interface{}) (*Passthrough, error) {
+	raw, ok := metadata[PassthroughKey]
+	if !ok {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var passthrough Passthrough
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&passthrough); err != nil {
+		return nil, fmt.Errorf("Metadata %v %v", PassthroughKey, err.Error())
+	}
+
+	return &passthrough, nil
+}
+
+// SetPassthroughProperty replaces a property in the FenrirPassthrough Metadata, e.g. to pin a functions ImageUri
+func SetPassthroughProperty(metadata map[string]interface{}, property string, value interface{}) error {
+	raw, ok := metadata[PassthroughKey].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Metadata %v not found", PassthroughKey)
+	}
+
+	if _, ok := passthroughProperties["AWS::Serverless::Function"][property]; !ok {
+		return fmt.Errorf("Metadata %v %v not supported", PassthroughKey, property)
+	}
+
+	raw[property] = value
+	return nil
+}
+
+// isPassthroughProperty is a property of the resource type goformation does not have, a string or list of strings,
+// or an object with only its properties
+func isPassthroughProperty(resourceType, property string, value interface{}) bool {
+	keys, ok := passthroughProperties[resourceType][property]
+	if !ok {
+		return false
+	}
+
+	if keys == nil {
+		return isStringOrStrings(value)
+	}
+
+	return hasOnlyKeys(value, keys...)
+}
+
+// isStringOrStrings is true for a string or a list of only strings
+func isStringOrStrings(value interface{}) bool {
+	if _, ok := value.(string); ok {
+		return true
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, v := range values {
+		if _, ok := v.(string); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPassthroughPolicy is a policy template goformation does not have with only its property
+func isPassthroughPolicy(policy interface{}) bool {
+	template, ok := policy.(map[string]interface{})
+	if !ok || len(template) != 1 {
+		return false
+	}
+
+	for key, value := range template {
+		property, ok := passthroughPolicies[key]
+		if !ok {
+			return false
+		}
+
+		properties, ok := value.(map[string]interface{})
+		if !ok || len(properties) != 1 {
+			return false
+		}
+
+		_, ok = properties[property]
+		return ok
+	}
+
+	return false
+}
+
+// isFunctionOnlyPolicy is a function SAM policy template goformation does not have for state machines
+func isFunctionOnlyPolicy(policy interface{}) bool {
+	template, ok := policy.(map[string]interface{})
+	if !ok || len(template) != 1 {
+		return false
+	}
+
+	for key := range template {
+		if stateMachinePolicies[key] {
+			return false
+		}
+
+		// Only the name is checked as its properties can be intrinsics, GetPassthrough decodes them
+		b, err := json.Marshal(map[string]interface{}{key: map[string]interface{}{}})
+		if err != nil {
+			return false
+		}
+
+		var functionPolicy serverless.Function_SAMPolicyTemplate
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		return dec.Decode(&functionPolicy) == nil
+	}
+
+	return false
+}
+
+// isPassthroughEvent is an event type goformation does not have with only its properties
+func isPassthroughEvent(event interface{}) bool {
+	if !hasOnlyKeys(event, "Type", "Properties") {
+		return false
+	}
+
+	e := event.(map[string]interface{})
+	eventType, _ := e["Type"].(string)
+	properties, ok := passthroughEvents[eventType]
+	if !ok {
+		return false
+	}
+
+	if !hasOnlyKeys(e["Properties"], properties...) {
+		return false
+	}
+
+	auth, ok := e["Properties"].(map[string]interface{})["Auth"]
+	return !ok || hasOnlyKeys(auth, "Authorizer", "AuthorizationScopes")
+}
+
+// hasOnlyKeys is true if value is an object whose keys are all in keys
+func hasOnlyKeys(value interface{}, keys ...string) bool {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	for key := range m {
+		found := false
+		for _, k := range keys {
+			found = found || k == key
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,106 @@
+package template
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+)
+
+// RuntimeWarning is how long before end of support a runtime is warned about
+const RuntimeWarning = 90 * 24 * time.Hour
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Runtime is when AWS ends support of a Lambda runtime, a zero date means none has been announced.
+// Functions can still be updated after end of support until AWS blocks the runtime
+type Runtime struct {
+	EndOfSupport time.Time
+	Blocked      bool
+}
+
+// Runtimes are the allowed Lambda runtimes,
+// from https://docs.aws.amazon.com/lambda/latest/dg/lambda-runtimes.html
+var Runtimes = map[string]Runtime{
+	"go1.x":           {EndOfSupport: date(2024, time.January, 8), Blocked: true},
+	"provided":        {EndOfSupport: date(2024, time.January, 8), Blocked: true},
+	"provided.al2":    {EndOfSupport: date(2026, time.June, 30)},
+	"provided.al2023": {},
+
+	"nodejs18.x": {EndOfSupport: date(2025, time.September, 1)},
+	"nodejs20.x": {EndOfSupport: date(2026, time.April, 30)},
+	"nodejs22.x": {EndOfSupport: date(2027, time.April, 30)},
+
+	"python3.9":  {EndOfSupport: date(2025, time.December, 15)},
+	"python3.10": {EndOfSupport: date(2026, time.June, 30)},
+	"python3.11": {EndOfSupport: date(2026, time.June, 30)},
+	"python3.12": {EndOfSupport: date(2028, time.October, 31)},
+	"python3.13": {EndOfSupport: date(2029, time.June, 30)},
+
+	"java8.al2": {EndOfSupport: date(2026, time.June, 30)},
+	"java11":    {EndOfSupport: date(2026, time.June, 30)},
+	"java17":    {EndOfSupport: date(2026, time.June, 30)},
+	"java21":    {EndOfSupport: date(2029, time.June, 30)},
+
+	"ruby3.2": {EndOfSupport: date(2026, time.March, 31)},
+	"ruby3.3": {EndOfSupport: date(2027, time.March, 31)},
+
+	"dotnet8": {EndOfSupport: date(2026, time.November, 10)},
+}
+
+// ValidateRuntime errors if runtime is not allowed or is blocked,
+// a warning is returned from RuntimeWarning before its end of support at now
+func ValidateRuntime(runtime string, now time.Time) (string, error) {
+	r, ok := Runtimes[runtime]
+	if !ok {
+		return "", fmt.Errorf("Runtime %q is not an allowed runtime", runtime)
+	}
+
+	if r.Blocked {
+		return "", fmt.Errorf("Runtime %q support ended %v", runtime, r.EndOfSupport.Format("2006-01-02"))
+	}
+
+	if r.EndOfSupport.IsZero() {
+		return "", nil
+	}
+
+	if !now.Before(r.EndOfSupport) {
+		return fmt.Sprintf("Runtime %q support ended %v, functions cannot be updated once AWS blocks it", runtime, r.EndOfSupport.Format("2006-01-02")), nil
+	}
+
+	if r.EndOfSupport.Sub(now) < RuntimeWarning {
+		return fmt.Sprintf("Runtime %q support ends %v", runtime, r.EndOfSupport.Format("2006-01-02")), nil
+	}
+
+	return "", nil
+}
+
+// Architectures are the allowed Lambda instruction set architectures
+var Architectures = []string{"x86_64", "arm64"}
+
+// ValidateArchitectures errors unless architectures is one allowed architecture, Lambda only supports one
+func ValidateArchitectures(architectures []string) error {
+	if len(architectures) != 1 {
+		return fmt.Errorf("Architectures must have one of %v", Architectures)
+	}
+
+	for _, a := range Architectures {
+		if architectures[0] == a {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Architecture %q must be one of %v", architectures[0], Architectures)
+}
+
+// FunctionArchitecture is the functions architecture, x86_64 unless its Architectures are passed through
+func FunctionArchitecture(fun *serverless.Function) string {
+	passthrough, _ := GetPassthrough(fun.AWSCloudFormationMetadata)
+	if passthrough == nil || len(passthrough.Architectures) == 0 {
+		return "x86_64"
+	}
+
+	return passthrough.Architectures[0]
+}
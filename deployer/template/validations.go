@@ -2,19 +2,43 @@ package template
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/tags"
 	"github.com/coinbase/fenrir/aws"
 	"github.com/coinbase/fenrir/aws/subnet"
 	"github.com/coinbase/step/utils/to"
 )
 
+// Limits are the Fenrir settings that limit the resources a project can deploy
+type Limits struct {
+	// MinScheduleRate is the shortest interval a schedule can run at
+	MinScheduleRate time.Duration
+
+	// Sources are the event sources patterns on shared buses can match, nil allows any source
+	Sources []string
+
+	// LogRetentionInDays is the retention of log groups that do not set one
+	LogRetentionInDays int
+
+	// Domains are the custom domains, and their subdomains, APIs can use
+	Domains []string
+
+	// SNSEndpoints are the external endpoints, e.g. email addresses, SNS topics can subscribe
+	SNSEndpoints []string
+}
+
 func ValidateTemplateResources(
 	projectName, configName, region, accountId string,
 	template *cloudformation.Template,
 	s3shas map[string]string,
+	imageDigests map[string]string,
+	limits Limits,
 	iamc aws.IAMAPI,
 	ec2c aws.EC2API,
 	s3c aws.S3API,
@@ -25,138 +49,382 @@ func ValidateTemplateResources(
 	kmsc aws.KMSAPI,
 	lambdac aws.LambdaAPI,
 	cwlc aws.CWLAPI,
+	ebc aws.EBAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+	ecrc aws.ECRAPI,
 ) error {
 
-	for name, a := range template.Resources {
-		switch a.AWSCloudFormationType() {
-		case "AWS::Serverless::Function":
-			res, err := template.GetServerlessFunctionWithName(name)
-			if err != nil {
-				return err
-			}
+	// Validate every resource so all errors are reported together
+	names := []string{}
+	for name := range template.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := ValidationErrors{}
+	for _, name := range names {
+		a := template.Resources[name]
+		err := validateTemplateResource(
+			projectName, configName, region, accountId, name,
+			template, a, s3shas, imageDigests, limits,
+			iamc, ec2c, s3c, kinc, ddbc, sqsc, snsc, kmsc, lambdac, cwlc, ebc, ssmc, smc, sfnc, ecrc)
+
+		switch err := err.(type) {
+		case nil:
+		case ValidationErrors:
+			errs = append(errs, err...)
+		case *ValidationError:
+			errs = append(errs, err)
+		default:
+			errs = append(errs, resourceError(a, name, "", "Invalid", err.Error()))
+		}
+	}
 
-			if err := ValidateAWSServerlessFunction(
-				projectName, configName, region, accountId, name,
-				template, res, s3shas,
-				iamc, ec2c, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc); err != nil {
-				return err
-			}
-		case "AWS::Serverless::Api":
-			res, err := template.GetServerlessApiWithName(name)
-			if err != nil {
-				return err
-			}
+	return errs.OrNil()
+}
 
-			if err := ValidateAWSServerlessApi(projectName, configName, name, template, res, s3shas); err != nil {
-				return err
-			}
+func validateTemplateResource(
+	projectName, configName, region, accountId, name string,
+	template *cloudformation.Template,
+	a cloudformation.Resource,
+	s3shas map[string]string,
+	imageDigests map[string]string,
+	limits Limits,
+	iamc aws.IAMAPI,
+	ec2c aws.EC2API,
+	s3c aws.S3API,
+	kinc aws.KINAPI,
+	ddbc aws.DDBAPI,
+	sqsc aws.SQSAPI,
+	snsc aws.SNSAPI,
+	kmsc aws.KMSAPI,
+	lambdac aws.LambdaAPI,
+	cwlc aws.CWLAPI,
+	ebc aws.EBAPI,
+	ssmc aws.SSMAPI,
+	smc aws.SMAPI,
+	sfnc aws.SFNAPI,
+	ecrc aws.ECRAPI,
+) error {
+	switch a.AWSCloudFormationType() {
+	case "AWS::Serverless::Function":
+		res, err := template.GetServerlessFunctionWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::Serverless::LayerVersion":
-			res, err := template.GetServerlessLayerVersionWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessFunction(
+			projectName, configName, region, accountId, name,
+			template, res, s3shas, imageDigests, limits,
+			iamc, ec2c, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ebc, ssmc, smc, sfnc, ecrc); err != nil {
+			return err
+		}
+	case "AWS::Serverless::StateMachine":
+		res, err := template.GetServerlessStateMachineWithName(name)
+		if err != nil {
+			return err
+		}
 
-			if err := ValidateAWSServerlessLayerVersion(projectName, configName, name, template, res, s3shas); err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessStateMachine(
+			projectName, configName, region, accountId, name,
+			template, res, s3shas,
+			iamc, s3c, kinc, ddbc, sqsc, snsc, kmsc, cwlc, ssmc, smc, sfnc, lambdac); err != nil {
+			return err
+		}
+	case "AWS::Serverless::Api":
+		res, err := template.GetServerlessApiWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::Serverless::SimpleTable":
-			res, err := template.GetServerlessSimpleTableWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessApi(projectName, configName, name, template, res, s3shas); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSServerlessSimpleTable(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case HttpApiType:
+		res, ok := a.(*cloudformation.CustomResource)
+		if !ok {
+			return resourceError(a, name, "Type", "UnsupportedType", fmt.Sprintf("%q is not an AWS::Serverless::HttpApi", name))
+		}
 
-		case "AWS::SQS::Queue":
-			res, err := template.GetSQSQueueWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessHttpApi(projectName, configName, region, accountId, name, template, res, s3shas, limits); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSSQSQueue(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case "AWS::Serverless::LayerVersion":
+		res, err := template.GetServerlessLayerVersionWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::CloudFront::Distribution":
-			res, err := template.GetCloudFrontDistributionWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessLayerVersion(projectName, configName, name, template, res, s3shas); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSCloudFrontDistribution(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case "AWS::Serverless::SimpleTable":
+		res, err := template.GetServerlessSimpleTableWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::CloudWatch::Alarm":
-			res, err := template.GetCloudWatchAlarmWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSServerlessSimpleTable(projectName, configName, name, template, res); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSCloudWatchAlarm(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case "AWS::DynamoDB::Table":
+		res, err := template.GetDynamoDBTableWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::ElasticLoadBalancingV2::LoadBalancer":
-			res, err := template.GetElasticLoadBalancingV2LoadBalancerWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSDynamoDBTable(projectName, configName, name, template, res, kmsc); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSElasticLoadBalancingV2LoadBalancer(projectName, configName, name, template, ec2c, res); err != nil {
-				return err
-			}
+	case "AWS::Logs::LogGroup":
+		res, err := template.GetLogsLogGroupWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::ElasticLoadBalancingV2::TargetGroup":
-			res, err := template.GetElasticLoadBalancingV2TargetGroupWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSLogsLogGroup(projectName, configName, name, template, res, limits, kmsc); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSElasticLoadBalancingV2TargetGroup(projectName, configName, name, template, lambdac, res); err != nil {
-				return err
-			}
+	case "AWS::SNS::Topic":
+		res, err := template.GetSNSTopicWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::ElasticLoadBalancingV2::Listener":
-			res, err := template.GetElasticLoadBalancingV2ListenerWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSSNSTopic(projectName, configName, name, template, res, limits, kmsc); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSElasticLoadBalancingV2Listener(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case "AWS::SNS::Subscription":
+		res, err := template.GetSNSSubscriptionWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::ElasticLoadBalancingV2::ListenerRule":
-			res, err := template.GetElasticLoadBalancingV2ListenerRuleWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSSNSSubscription(projectName, configName, name, template, res, limits); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSElasticLoadBalancingV2ListenerRule(projectName, configName, name, template, res); err != nil {
-				return err
-			}
+	case "AWS::S3::Bucket":
+		res, err := template.GetS3BucketWithName(name)
+		if err != nil {
+			return err
+		}
 
-		case "AWS::Lambda::Permission":
-			res, err := template.GetLambdaPermissionWithName(name)
-			if err != nil {
-				return err
-			}
+		if err := ValidateAWSS3Bucket(projectName, configName, region, accountId, name, template, res, iamc, s3c, sqsc, snsc, kmsc); err != nil {
+			return err
+		}
+
+	case "AWS::Events::EventBus":
+		res, err := template.GetEventsEventBusWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSEventsEventBus(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	case "AWS::Events::Rule":
+		res, err := template.GetEventsRuleWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSEventsRule(projectName, configName, accountId, name, template, res, limits, iamc, ebc); err != nil {
+			return err
+		}
+
+	case "AWS::SQS::Queue":
+		res, err := template.GetSQSQueueWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSSQSQueue(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	case "AWS::CloudFront::Distribution":
+		res, err := template.GetCloudFrontDistributionWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSCloudFrontDistribution(projectName, configName, name, template, res); err != nil {
+			return err
+		}
 
-			if err := ValidateAWSLambdaPermission(projectName, configName, name, template, res); err != nil {
-				return err
+	case "AWS::CloudWatch::Alarm":
+		res, err := template.GetCloudWatchAlarmWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSCloudWatchAlarm(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	case "AWS::ElasticLoadBalancingV2::LoadBalancer":
+		res, err := template.GetElasticLoadBalancingV2LoadBalancerWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSElasticLoadBalancingV2LoadBalancer(projectName, configName, name, template, ec2c, res); err != nil {
+			return err
+		}
+
+	case "AWS::ElasticLoadBalancingV2::TargetGroup":
+		res, err := template.GetElasticLoadBalancingV2TargetGroupWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSElasticLoadBalancingV2TargetGroup(projectName, configName, name, template, lambdac, res); err != nil {
+			return err
+		}
+
+	case "AWS::ElasticLoadBalancingV2::Listener":
+		res, err := template.GetElasticLoadBalancingV2ListenerWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSElasticLoadBalancingV2Listener(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	case "AWS::ElasticLoadBalancingV2::ListenerRule":
+		res, err := template.GetElasticLoadBalancingV2ListenerRuleWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSElasticLoadBalancingV2ListenerRule(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	case "AWS::Lambda::Permission":
+		res, err := template.GetLambdaPermissionWithName(name)
+		if err != nil {
+			return err
+		}
+
+		if err := ValidateAWSLambdaPermission(projectName, configName, name, template, res); err != nil {
+			return err
+		}
+
+	default:
+		return resourceError(a, name, "Type", "UnsupportedType", fmt.Sprintf("Unsupported type %q for %q", a.AWSCloudFormationType(), name))
+	}
+
+	return nil
+}
+
+// ValidationError is a single finding about a template resource
+type ValidationError struct {
+	ResourceName string `json:"resource_name"`
+	ResourceType string `json:"resource_type"`
+	Path         string `json:"path"`
+	Rule         string `json:"rule"`
+	Message      string `json:"message"`
+
+	// Warning findings are reported but do not fail validation
+	Warning bool `json:"warning,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.ResourceName == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%v#%v: %v (rule: %v, path: %v)", e.ResourceType, e.ResourceName, e.Message, e.Rule, e.Path)
+}
+
+// ValidationErrors holds every error found validating a template
+type ValidationErrors []error
+
+func (errs ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// OrNil returns nil if there are no errors
+func (errs ValidationErrors) OrNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// SplitWarnings separates the warnings, which do not fail validation, from the errors
+func (errs ValidationErrors) SplitWarnings() (ValidationErrors, []*ValidationError) {
+	remaining, warnings := ValidationErrors{}, []*ValidationError{}
+	for _, err := range errs {
+		switch err := err.(type) {
+		case *ValidationError:
+			if err.Warning {
+				warnings = append(warnings, err)
+			} else {
+				remaining = append(remaining, err)
 			}
+		case ValidationErrors:
+			e, w := err.SplitWarnings()
+			remaining = append(remaining, e...)
+			warnings = append(warnings, w...)
+		default:
+			remaining = append(remaining, err)
+		}
+	}
 
+	return remaining, warnings
+}
+
+// List returns every error as a ValidationError, errors without a resource only have a Message
+func (errs ValidationErrors) List() []*ValidationError {
+	verrs := []*ValidationError{}
+	for _, err := range errs {
+		switch err := err.(type) {
+		case *ValidationError:
+			verrs = append(verrs, err)
+		case ValidationErrors:
+			verrs = append(verrs, err.List()...)
 		default:
-			return fmt.Errorf("Unsupported type %q for %q", a.AWSCloudFormationType(), name)
+			verrs = append(verrs, &ValidationError{Message: err.Error()})
 		}
 	}
 
-	return nil
+	return verrs
+}
+
+// MarshalJSON writes the errors as a list so a BadReleaseError Cause can be decoded by the client
+func (errs ValidationErrors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(errs.List())
+}
+
+// DecodeValidationErrors returns the ValidationErrors in a BadReleaseError Cause,
+// or nil if the Cause is not a JSON list of errors
+func DecodeValidationErrors(cause string) []*ValidationError {
+	verrs := []*ValidationError{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(cause, "BadReleaseError: ")), &verrs); err != nil {
+		return nil
+	}
+
+	return verrs
 }
 
 func ValidateSubnet(sub *subnet.Subnet) error {
@@ -204,6 +472,16 @@ func hasCorrectTags(projectName, configName string, tags map[string]string) erro
 	return fmt.Errorf("ProjectName (%v != %v) OR ConfigName (%v != %v) tags incorrect", tags["ProjectName"], projectName, tags["ConfigName"], configName)
 }
 
+// isType is true if the resource is one of the types
+func isType(res cloudformation.Resource, types ...string) bool {
+	for _, t := range types {
+		if res.AWSCloudFormationType() == t {
+			return true
+		}
+	}
+	return false
+}
+
 func strA(strl []string) []*string {
 	stra := []*string{}
 	for _, s := range strl {
@@ -212,6 +490,35 @@ func strA(strl []string) []*string {
 	return stra
 }
 
+// schemaRequiredNames are the generated name properties the SAM schema requires
+var schemaRequiredNames = map[string]string{
+	"AWS::Events::EventBus": "Name",
+}
+
+// SchemaGeneratedNames sets a placeholder for generated names the schema requires
+// so a template without them can be validated against it
+func SchemaGeneratedNames(resources map[string]interface{}) {
+	for _, raw := range resources {
+		res, _ := raw.(map[string]interface{})
+		resourceType, _ := res["Type"].(string)
+
+		property, ok := schemaRequiredNames[resourceType]
+		if !ok {
+			continue
+		}
+
+		properties, ok := res["Properties"].(map[string]interface{})
+		if !ok {
+			properties = map[string]interface{}{}
+			res["Properties"] = properties
+		}
+
+		if _, ok := properties[property]; !ok {
+			properties[property] = "generated"
+		}
+	}
+}
+
 func normalizeName(prefix, projectName, configName, resourceName string, maxLength int) string {
 	str := fmt.Sprintf("%v-%v-%v-%v", prefix, projectName, configName, resourceName)
 	str = strings.Replace(str, "/", "-", -1)
@@ -227,6 +534,43 @@ func normalizeName(prefix, projectName, configName, resourceName string, maxLeng
 	return str
 }
 
-func resourceError(resource cloudformation.Resource, name, errStr string) error {
-	return fmt.Errorf("%v#%v: %v", resource.AWSCloudFormationType(), name, errStr)
+// forceTags replaces any ProjectName, ConfigName and ServiceName tags with the release values
+func forceTags(resTags []tags.Tag, projectName, configName, resourceName string) []tags.Tag {
+	forced := []tags.Tag{}
+	for _, tag := range resTags {
+		switch tag.Key {
+		case "ProjectName", "ConfigName", "ServiceName":
+		default:
+			forced = append(forced, tag)
+		}
+	}
+
+	return append(forced,
+		tags.Tag{Key: "ProjectName", Value: projectName},
+		tags.Tag{Key: "ConfigName", Value: configName},
+		tags.Tag{Key: "ServiceName", Value: resourceName},
+	)
+}
+
+func resourceError(resource cloudformation.Resource, name, path, rule, errStr string) *ValidationError {
+	// Passthrough types are reported as the type in the template
+	resourceType, ok := passthroughResourceType(resource.AWSCloudFormationType())
+	if !ok {
+		resourceType = resource.AWSCloudFormationType()
+	}
+
+	return &ValidationError{
+		ResourceName: name,
+		ResourceType: resourceType,
+		Path:         path,
+		Rule:         rule,
+		Message:      errStr,
+	}
+}
+
+// resourceWarning is a resourceError that does not fail validation
+func resourceWarning(resource cloudformation.Resource, name, path, rule, errStr string) *ValidationError {
+	warning := resourceError(resource, name, path, rule, errStr)
+	warning.Warning = true
+	return warning
 }
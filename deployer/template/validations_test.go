@@ -1,9 +1,18 @@
 package template
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/events"
+	"github.com/awslabs/goformation/v4/cloudformation/logs"
+	"github.com/awslabs/goformation/v4/cloudformation/policies"
+	"github.com/awslabs/goformation/v4/cloudformation/s3"
 	"github.com/awslabs/goformation/v4/cloudformation/serverless"
+	"github.com/awslabs/goformation/v4/cloudformation/sns"
+	"github.com/awslabs/goformation/v4/cloudformation/tags"
+	"github.com/coinbase/step/utils/to"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,12 +40,270 @@ func TestValidateAWSServerlessSimpleTableWorks(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateAWSDynamoDBTable(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/dynamodb_table.yml")
+	assert.NoError(t, err)
+
+	res, err := template.GetDynamoDBTableWithName("table")
+	assert.NoError(t, err)
+
+	res.Tags = []tags.Tag{{Key: "ProjectName", Value: "other"}, {Key: "Team", Value: "t"}}
+
+	awsc := MockAwsClients()
+	err = ValidateAWSDynamoDBTable("project", "development", "table", template, res, awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "fenrir-project-development-table", res.TableName)
+	assert.Equal(t, policies.DeletionPolicy("Retain"), res.AWSCloudFormationDeletionPolicy)
+	assert.Equal(t, policies.UpdateReplacePolicy("Retain"), res.AWSCloudFormationUpdateReplacePolicy)
+	assert.True(t, res.PointInTimeRecoverySpecification.PointInTimeRecoveryEnabled)
+	assert.True(t, res.SSESpecification.SSEEnabled)
+	assert.Equal(t, []tags.Tag{
+		{Key: "Team", Value: "t"},
+		{Key: "ProjectName", Value: "project"},
+		{Key: "ConfigName", Value: "development"},
+		{Key: "ServiceName", Value: "table"},
+	}, res.Tags)
+
+	res.TableName = ""
+	res.SSESpecification.KMSMasterKeyId = "alias/key"
+	err = ValidateAWSDynamoDBTable("project", "development", "table", template, res, awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	err = ValidateAWSDynamoDBTable("project", "development", "table", template, res, awsc.KMS(nil, nil, nil))
+	assert.Error(t, err)
+}
+
+func TestValidateAWSSNSTopic(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/sns_topic.yml")
+	assert.NoError(t, err)
+
+	res, err := template.GetSNSTopicWithName("topic")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	limits := MockLimits()
+	err = ValidateAWSSNSTopic("project", "development", "topic", template, res, limits, awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "fenrir-project-development-topic", res.TopicName)
+
+	// External endpoints must be allowed
+	res.TopicName = ""
+	res.Subscription = append(res.Subscription, sns.Topic_Subscription{Protocol: "email", Endpoint: "alerts@example.com"})
+	limits.SNSEndpoints = []string{"alerts@example.com"}
+	err = ValidateAWSSNSTopic("project", "development", "topic", template, res, limits, awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	res.TopicName = ""
+	err = ValidateAWSSNSTopic("project", "development", "topic", template, res, MockLimits(), awsc.KMS(nil, nil, nil))
+	assert.Regexp(t, "alerts@example.com is not an allowed SNS endpoint", err)
+
+	fifo := &sns.Topic{FifoTopic: true}
+	err = ValidateAWSSNSTopic("project", "development", "fifo", template, fifo, MockLimits(), awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "fenrir-project-development-fifo.fifo", fifo.TopicName)
+}
+
+func TestValidateAWSSNSSubscription(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/sns_topic.yml")
+	assert.NoError(t, err)
+
+	res, err := template.GetSNSSubscriptionWithName("queueSubscription")
+	assert.NoError(t, err)
+
+	err = ValidateAWSSNSSubscription("project", "development", "queueSubscription", template, res, MockLimits())
+	assert.NoError(t, err)
+
+	// lambda endpoints must be functions
+	res.Protocol = "lambda"
+	err = ValidateAWSSNSSubscription("project", "development", "queueSubscription", template, res, MockLimits())
+	assert.Regexp(t, "queue is not an AWS::Serverless::Function in the template", err)
+
+	res.Protocol = "sqs"
+	res.TopicArn = "arn:aws:sns:us-east-1:000000000000:external"
+	err = ValidateAWSSNSSubscription("project", "development", "queueSubscription", template, res, MockLimits())
+	assert.Regexp(t, "TopicArn must be !Ref", err)
+}
+
+func TestValidateAWSS3Bucket(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/s3_bucket.yml")
+	assert.NoError(t, err)
+
+	res, err := template.GetS3BucketWithName("Uploads")
+	assert.NoError(t, err)
+
+	res.PublicAccessBlockConfiguration = &s3.Bucket_PublicAccessBlockConfiguration{BlockPublicAcls: true}
+
+	awsc := MockAwsClients()
+	err = ValidateAWSS3Bucket("project/name", "development", "us-east-1", "000000000000", "Uploads", template, res, awsc.IAM(nil, nil, nil), awsc.S3(nil, nil, nil), awsc.SQS(nil, nil, nil), awsc.SNS(nil, nil, nil), awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "fenrir-000000000000-project-name-development-uploads", res.BucketName)
+	assert.Equal(t, policies.DeletionPolicy("Retain"), res.AWSCloudFormationDeletionPolicy)
+	assert.Equal(t, policies.UpdateReplacePolicy("Retain"), res.AWSCloudFormationUpdateReplacePolicy)
+	assert.True(t, res.PublicAccessBlockConfiguration.BlockPublicPolicy)
+	assert.True(t, res.PublicAccessBlockConfiguration.RestrictPublicBuckets)
+	assert.Equal(t, "AES256", res.BucketEncryption.ServerSideEncryptionConfiguration[0].ServerSideEncryptionByDefault.SSEAlgorithm)
+
+	res.BucketName = ""
+	res.BucketEncryption.ServerSideEncryptionConfiguration[0].ServerSideEncryptionByDefault.SSEAlgorithm = "none"
+	err = ValidateAWSS3Bucket("project", "development", "us-east-1", "000000000000", "Uploads", template, res, awsc.IAM(nil, nil, nil), awsc.S3(nil, nil, nil), awsc.SQS(nil, nil, nil), awsc.SNS(nil, nil, nil), awsc.KMS(nil, nil, nil))
+	assert.Regexp(t, "SSEAlgorithm must be AES256 or aws:kms", err)
+
+	res.BucketName = ""
+	res.BucketEncryption.ServerSideEncryptionConfiguration[0].ServerSideEncryptionByDefault.SSEAlgorithm = "AES256"
+	res.ReplicationConfiguration = &s3.Bucket_ReplicationConfiguration{
+		Role: "arn:aws:iam::000000000000:role/role_correct",
+		Rules: []s3.Bucket_ReplicationRule{
+			{Status: "Enabled", Destination: &s3.Bucket_ReplicationDestination{Bucket: "arn:aws:s3:::bucket"}},
+			{Status: "Enabled", Destination: &s3.Bucket_ReplicationDestination{Bucket: cloudformation.GetAtt("Uploads", "Arn")}},
+		},
+	}
+	res.NotificationConfiguration = &s3.Bucket_NotificationConfiguration{
+		LambdaConfigurations: []s3.Bucket_LambdaConfiguration{{Event: "s3:ObjectCreated:*", Function: cloudformation.GetAtt("hello", "Arn")}},
+		QueueConfigurations:  []s3.Bucket_QueueConfiguration{{Event: "s3:ObjectCreated:*", Queue: "queue"}},
+		TopicConfigurations:  []s3.Bucket_TopicConfiguration{{Event: "s3:ObjectCreated:*", Topic: "arn:aws:sns:us-east-1:000000000000:topic"}},
+	}
+	res.LoggingConfiguration = &s3.Bucket_LoggingConfiguration{DestinationBucketName: "bucket"}
+	err = ValidateAWSS3Bucket("project", "development", "us-east-1", "000000000000", "Uploads", template, res, awsc.IAM(nil, nil, nil), awsc.S3(nil, nil, nil), awsc.SQS(nil, nil, nil), awsc.SNS(nil, nil, nil), awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+
+	res.BucketName = ""
+	res.ReplicationConfiguration.Role = "role_bad"
+	res.ReplicationConfiguration.Rules[0].Destination = &s3.Bucket_ReplicationDestination{Bucket: "arn:aws:s3:::other", Account: "111111111111"}
+	res.NotificationConfiguration.LambdaConfigurations[0].Function = "arn:aws:lambda:us-east-1:000000000000:function:other"
+	res.NotificationConfiguration.QueueConfigurations[0].Queue = cloudformation.GetAtt("hello", "Arn")
+	res.LoggingConfiguration.DestinationBucketName = cloudformation.Ref("Missing")
+	err = ValidateAWSS3Bucket("project", "development", "us-east-1", "000000000000", "Uploads", template, res, awsc.IAM(nil, nil, nil), awsc.S3(nil, nil, nil), awsc.SQS(nil, nil, nil), awsc.SNS(nil, nil, nil), awsc.KMS(nil, nil, nil))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+
+	findings := []string{}
+	for _, verr := range errs.List() {
+		findings = append(findings, verr.Path+" "+verr.Rule)
+	}
+
+	assert.Equal(t, []string{
+		"ReplicationConfiguration.Role IncorrectTags",
+		"ReplicationConfiguration.Rules[0].Destination UnsupportedProperty",
+		"ReplicationConfiguration.Rules[0].Destination.Bucket ResourceNotFound",
+		"NotificationConfiguration.LambdaConfigurations[0].Function MustBeRef",
+		"NotificationConfiguration.QueueConfigurations[0].Queue ResourceNotFound",
+		"LoggingConfiguration.DestinationBucketName ResourceNotFound",
+	}, findings)
+}
+
+func TestValidateAWSEventsRule(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/events_rule.yml")
+	assert.NoError(t, err)
+
+	res, err := template.GetEventsRuleWithName("rule")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	err = ValidateAWSEventsRule("project", "development", "000000000000", "rule", template, res, MockLimits(), awsc.IAM(nil, nil, nil), awsc.EB(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "fenrir-project-development-rule", res.Name)
+
+	res.Name = ""
+	res.Targets = append(res.Targets,
+		events.Rule_Target{Id: "topic", Arn: cloudformation.GetAtt("queue", "Arn"), RoleArn: "role_bad"},
+		events.Rule_Target{Id: "bus", Arn: cloudformation.Ref("bus"), InputPath: "detail"},
+	)
+	res.ScheduleExpression = "rate(30 seconds)"
+	err = ValidateAWSEventsRule("project", "development", "000000000000", "rule", template, res, MockLimits(), awsc.IAM(nil, nil, nil), awsc.EB(nil, nil, nil))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+
+	findings := []string{}
+	for _, verr := range errs.List() {
+		findings = append(findings, verr.Path+" "+verr.Rule)
+	}
+
+	assert.Equal(t, []string{
+		"ScheduleExpression InvalidSchedule",
+		"Targets[2].RoleArn IncorrectTags",
+		"Targets[3].Arn UnsupportedTarget",
+		"Targets[3].Input InvalidInput",
+	}, findings)
+}
+
+func TestAddFunctionLogGroups(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/cloudwatch.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	err = AddFunctionLogGroups("project", "development", template, map[string]bool{}, awsc.CWL(nil, nil, nil))
+	assert.NoError(t, err)
+
+	group, err := template.GetLogsLogGroupWithName("helloLogGroup")
+	assert.NoError(t, err)
+	assert.Equal(t, "/aws/lambda/fenrir-project-development-hello", group.LogGroupName)
+
+	err = ValidateAWSLogsLogGroup("project", "development", "helloLogGroup", template, group, MockLimits(), awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 30, group.RetentionInDays)
+
+	// A log group Lambda already created is skipped unless the stack manages it
+	awsc.CWLClient.AddLogGroup("/aws/lambda/fenrir-project-development-hello")
+
+	delete(template.Resources, "helloLogGroup")
+	err = AddFunctionLogGroups("project", "development", template, map[string]bool{}, awsc.CWL(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.NotContains(t, template.Resources, "helloLogGroup")
+
+	err = AddFunctionLogGroups("project", "development", template, map[string]bool{
+		"/aws/lambda/fenrir-project-development-hello": true,
+	}, awsc.CWL(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Contains(t, template.Resources, "helloLogGroup")
+}
+
+func TestAddFunctionLogGroupsDeclared(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/log_group.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	err = AddFunctionLogGroups("project", "development", template, map[string]bool{}, awsc.CWL(nil, nil, nil))
+	assert.NoError(t, err)
+
+	// hello declares its own log group
+	assert.NotContains(t, template.Resources, "helloLogGroup")
+	assert.Contains(t, template.Resources, "worldLogGroup")
+}
+
+func TestValidateAWSLogsLogGroup(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/log_group.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	group, err := template.GetLogsLogGroupWithName("helloLogs")
+	assert.NoError(t, err)
+
+	err = ValidateAWSLogsLogGroup("project", "development", "helloLogs", template, group, MockLimits(), awsc.KMS(nil, nil, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 90, group.RetentionInDays)
+
+	for _, group := range []*logs.LogGroup{
+		{LogGroupName: ""},
+		{LogGroupName: "/aws/lambda/hello"},
+		{LogGroupName: "/aws/lambda/fenrir-project-development-unknown"},
+		{LogGroupName: "/aws/lambda/fenrir-project-development-hello", RetentionInDays: 2},
+	} {
+		err = ValidateAWSLogsLogGroup("project", "development", "logs", template, group, MockLimits(), awsc.KMS(nil, nil, nil))
+		assert.Error(t, err, group.LogGroupName)
+	}
+}
+
 func TestValidateAWSServerlessLayerVersionWorks(t *testing.T) {
 	template, err := MockTemplate("../../examples/tests/allowed/function.yml")
 	assert.NoError(t, err)
 
 	err = ValidateAWSServerlessLayerVersion("pn", "cn", "rn", template, &serverless.LayerVersion{
-		ContentUri: "s3://bucket/path.zip",
+		ContentUri: &serverless.LayerVersion_ContentUri{String: to.Strp("s3://bucket/path.zip")},
 	}, map[string]string{
 		"s3://bucket/path.zip": MockS3SHA(),
 	})
@@ -52,6 +319,214 @@ func TestValidateAWSServerlessApiWorks(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateAWSServerlessHttpApi(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/http_api.yml")
+	assert.NoError(t, err)
+
+	res, ok := template.Resources["helloHttpApi"].(*cloudformation.CustomResource)
+	assert.True(t, ok)
+
+	// The generated name is removed so the API can be validated again
+	validate := func() error {
+		delete(res.Properties, "Name")
+		return ValidateAWSServerlessHttpApi("project", "development", "region", "account", "helloHttpApi", template, res, map[string]string{
+			"s3://bucket/api.yml": MockS3SHA(),
+		}, MockLimits())
+	}
+
+	assert.NoError(t, validate())
+	assert.Equal(t, "fenrir-project-development-helloHttpApi", res.Properties["Name"])
+	assert.Equal(t, map[string]string{
+		"ProjectName": "project",
+		"ConfigName":  "development",
+		"ServiceName": "helloHttpApi",
+	}, res.Properties["Tags"])
+
+	// Only the supported properties are deployed
+	res.Properties["AccessLogSettings"] = map[string]interface{}{"DestinationArn": "arn"}
+	assert.Regexp(t, `unknown field "AccessLogSettings"`, validate())
+	delete(res.Properties, "AccessLogSettings")
+
+	res.Properties["DefinitionUri"] = "s3://bucket/other.yml"
+	assert.Regexp(t, "DefinitionUri s3://bucket/other.yml not included in the SHA256s map", validate())
+	res.Properties["DefinitionUri"] = "s3://bucket/api.yml"
+	assert.NoError(t, validate())
+
+	// Authorizers
+	auth := res.Properties["Auth"].(map[string]interface{})
+	authorizers := auth["Authorizers"].(map[string]interface{})
+	jwt := authorizers["jwt"].(map[string]interface{})["JwtConfiguration"].(map[string]interface{})
+	jwt["issuer"] = "http://auth.example.com"
+	assert.Regexp(t, `Authorizer "jwt" JwtConfiguration issuer must be an https URL`, validate())
+	jwt["issuer"] = "https://auth.example.com"
+
+	authorizers["lambda"].(map[string]interface{})["FunctionArn"] = "arn:aws:lambda:region:account:function:other"
+	assert.Regexp(t, `Authorizer "lambda" FunctionArn must be !GetAtt <function>.Arn`, validate())
+	authorizers["lambda"].(map[string]interface{})["FunctionArn"] = cloudformation.GetAtt("helloHttpApi", "Arn")
+	assert.Regexp(t, `FunctionArn !GetAtt helloHttpApi is not an AWS::Serverless::Function in the template`, validate())
+	authorizers["lambda"].(map[string]interface{})["FunctionArn"] = cloudformation.GetAtt("authorizer", "Arn")
+
+	auth["DefaultAuthorizer"] = "missing"
+	assert.Regexp(t, `DefaultAuthorizer "missing" is not in Authorizers`, validate())
+	auth["DefaultAuthorizer"] = "AWS_IAM"
+	assert.NoError(t, validate())
+
+	// Custom domains must be allowed by the settings
+	res.Properties["Domain"] = map[string]interface{}{
+		"DomainName":     "api.example.com",
+		"CertificateArn": "arn:aws:acm:region:account:certificate/id",
+	}
+	assert.NoError(t, validate())
+
+	res.Properties["Domain"].(map[string]interface{})["DomainName"] = "api.other.com"
+	assert.Regexp(t, `DomainName "api.other.com" is not an allowed domain`, validate())
+	res.Properties["Domain"].(map[string]interface{})["DomainName"] = "api.example.com"
+
+	res.Properties["Domain"].(map[string]interface{})["CertificateArn"] = "arn:aws:acm:region:other:certificate/id"
+	assert.Regexp(t, `CertificateArn must be an ACM certificate ARN in region account`, validate())
+
+	res.Properties["Domain"].(map[string]interface{})["Route53"] = map[string]interface{}{"HostedZoneId": "Z1"}
+	assert.Regexp(t, `unknown field "Route53"`, validate())
+
+	// Errors are reported as the template type
+	delete(res.Properties, "Domain")
+	res.Properties["Name"] = "other"
+	err = ValidateAWSServerlessHttpApi("project", "development", "region", "account", "helloHttpApi", template, res, map[string]string{}, MockLimits())
+	assert.Regexp(t, `AWS::Serverless::HttpApi#helloHttpApi: Names are overwritten`, err)
+}
+
+func TestValidateAWSServerlessStateMachine(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/state_machine.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+	awsc.S3Client.AddGetObject("definition.yml", `
+StartAt: Each
+States:
+  Each:
+    Type: Map
+    End: true
+    Iterator:
+      StartAt: Hello
+      States:
+        Hello:
+          Type: Task
+          Resource: ${Hello}
+          Next: Publish
+        Publish:
+          Type: Task
+          Resource: arn:aws:states:::sns:publish
+          Next: Dynamic
+        Dynamic:
+          Type: Task
+          Resource: arn:aws:states:::lambda:invoke
+          Parameters:
+            FunctionName.$: $.name
+          End: true
+`, nil)
+
+	res := &serverless.StateMachine{
+		DefinitionUri:           &serverless.StateMachine_DefinitionUri{String: to.Strp("s3://bucket/definition.yml")},
+		DefinitionSubstitutions: map[string]string{"Hello": template.Resources["helloStateMachine"].(*serverless.StateMachine).DefinitionSubstitutions["HelloArn"]},
+		Role:                    "role_correct",
+	}
+
+	err = ValidateAWSServerlessStateMachine(
+		"project", "development", "region", "account", "sm",
+		template, res,
+		map[string]string{"s3://bucket/definition.yml": MockS3SHA()},
+		awsc.IAM(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+	)
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+
+	paths := []string{}
+	for _, verr := range errs.List() {
+		paths = append(paths, verr.Path)
+	}
+
+	assert.Equal(t, []string{
+		"Definition.States.Each.Iterator.States.Dynamic.Parameters.FunctionName",
+		"Definition.States.Each.Iterator.States.Publish.Resource",
+	}, paths)
+
+	assert.Equal(t, "fenrir-project-development-sm", res.Name)
+	assert.Equal(t, "sm", res.Tags["ServiceName"])
+}
+
+func TestValidateStateMachineIAM(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/state_machine_w_policies.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	validate := func(res *serverless.StateMachine) error {
+		return ValidateStateMachineIAM(
+			template,
+			"project", "development", "region", "account", "sm",
+			res,
+			awsc.IAM(nil, nil, nil),
+			awsc.S3(nil, nil, nil),
+			awsc.KIN(nil, nil, nil),
+			awsc.DDB(nil, nil, nil),
+			awsc.SQS(nil, nil, nil),
+			awsc.SNS(nil, nil, nil),
+			awsc.KMS(nil, nil, nil),
+			awsc.CWL(nil, nil, nil),
+			awsc.SSM(nil, nil, nil),
+			awsc.SM(nil, nil, nil),
+			awsc.SFN(nil, nil, nil),
+		)
+	}
+
+	res := &serverless.StateMachine{Role: "role_correct"}
+	assert.NoError(t, validate(res))
+
+	res = &serverless.StateMachine{Role: "role_bad"}
+	assert.Regexp(t, "Incorrect ProjectName for Role", validate(res))
+
+	res = &serverless.StateMachine{}
+	assert.Regexp(t, "Must define either Role or Policies, not both", validate(res))
+
+	// Policies get the function checks and the role SAM creates the permissions boundary
+	res = template.Resources["helloStateMachine"].(*serverless.StateMachine)
+	assert.NoError(t, validate(res))
+
+	passthrough, err := GetPassthrough(res.AWSCloudFormationMetadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::account:policy/fenrir-permissions-boundary", passthrough.PermissionsBoundary)
+	assert.Len(t, passthrough.Policies, 2)
+
+	res.Role = "role_correct"
+	assert.Regexp(t, "Must define either Role or Policies, not both", validate(res))
+
+	res = &serverless.StateMachine{Policies: &serverless.StateMachine_Policies{
+		SAMPolicyTemplateArray: &[]serverless.StateMachine_SAMPolicyTemplate{
+			{LambdaInvokePolicy: &serverless.StateMachine_FunctionSAMPT{FunctionName: "external"}},
+		},
+	}}
+	assert.Regexp(t, "Policies.LambdaInvokePolicy.FunctionName must be !Ref", validate(res))
+
+	res = &serverless.StateMachine{Policies: &serverless.StateMachine_Policies{
+		IAMPolicyDocument: &serverless.StateMachine_IAMPolicyDocument{Statement: []interface{}{
+			map[string]interface{}{"Effect": "Allow", "Action": "iam:*", "Resource": "*"},
+		}},
+	}}
+	assert.Regexp(t, `Action "iam:\*" not allowed`, validate(res))
+}
+
 func TestValidateAWSLambdaPermission(t *testing.T) {
 	template, err := MockTemplate("../../examples/tests/allowed/good_principal.yml")
 	assert.NoError(t, err)
@@ -61,3 +536,226 @@ func TestValidateAWSLambdaPermission(t *testing.T) {
 	err = ValidateAWSLambdaPermission("pn", "cn", "rn", template, res)
 	assert.NoError(t, err)
 }
+
+func TestValidateTemplateResourcesCollectsErrors(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/not/multiple_errors.yml")
+	assert.NoError(t, err)
+
+	awsc := MockAwsClients()
+
+	err = ValidateTemplateResources(
+		"project", "development", "region", "account",
+		template,
+		map[string]string{},
+		map[string]string{},
+		MockLimits(),
+		awsc.IAM(nil, nil, nil),
+		awsc.EC2(nil, nil, nil),
+		awsc.S3(nil, nil, nil),
+		awsc.KIN(nil, nil, nil),
+		awsc.DDB(nil, nil, nil),
+		awsc.SQS(nil, nil, nil),
+		awsc.SNS(nil, nil, nil),
+		awsc.KMS(nil, nil, nil),
+		awsc.Lambda(nil, nil, nil),
+		awsc.CWL(nil, nil, nil),
+		awsc.EB(nil, nil, nil),
+		awsc.SSM(nil, nil, nil),
+		awsc.SM(nil, nil, nil),
+		awsc.SFN(nil, nil, nil),
+		awsc.ECR(nil, nil, nil),
+	)
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+
+	verrs := errs.List()
+	assert.Equal(t, len(errs), len(verrs))
+
+	findings := []string{}
+	for _, verr := range verrs {
+		findings = append(findings, verr.ResourceName+" "+verr.Path+" "+verr.Rule)
+	}
+
+	assert.Equal(t, []string{
+		"alpha VpcConfig IncorrectTags",
+		"alpha CodeUri MissingArtifact",
+		"hello Role IncorrectTags",
+		"hello Events.BadEvent UnsupportedEvent",
+		"hello CodeUri MissingArtifact",
+	}, findings)
+
+	assert.Equal(t, &ValidationError{
+		ResourceName: "hello",
+		ResourceType: "AWS::Serverless::Function",
+		Path:         "Events.BadEvent",
+		Rule:         "UnsupportedEvent",
+		Message:      `Event "BadEvent" Unsupported Event type "IoTRule"`,
+	}, verrs[3])
+
+	// The errors round trip through a BadReleaseError Cause
+	raw, err := json.Marshal(errs)
+	assert.NoError(t, err)
+	assert.Equal(t, verrs, DecodeValidationErrors("BadReleaseError: "+string(raw)))
+
+	assert.Nil(t, DecodeValidationErrors("BadReleaseError: stack: broke"))
+}
+
+func TestValidateParameters(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/parameters.yml")
+	assert.NoError(t, err)
+
+	// Defaults are valid
+	assert.NoError(t, ValidateParameters(template.Parameters, nil))
+
+	assert.NoError(t, ValidateParameters(template.Parameters, map[string]string{
+		"Stage":     "prod",
+		"Threshold": "1.5",
+		"Domains":   "c.example.com",
+	}))
+
+	err = ValidateParameters(template.Parameters, map[string]string{
+		"Stage":     "test",
+		"Threshold": "0",
+		"Domains":   "a.example.com,B.example.com",
+		"Unknown":   "value",
+	})
+
+	verrs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 4, len(verrs))
+	assert.Regexp(t, `Parameter#Domains: "B.example.com" does not match AllowedPattern`, verrs[0].Error())
+	assert.Regexp(t, `Parameter#Stage: "test" not in AllowedValues dev, prod`, verrs[1].Error())
+	assert.Regexp(t, `Parameter#Threshold: 0 less than MinValue 1`, verrs[2].Error())
+	assert.Regexp(t, `Parameter#Unknown: value given for a parameter not in the template`, verrs[3].Error())
+
+	for _, param := range []cloudformation.Parameter{
+		{Type: "String", NoEcho: true, Default: "a"},
+		{Type: "AWS::SSM::Parameter::Value<String>", Default: "/path"},
+		{Type: "List<AWS::EC2::Subnet::Id>", Default: "subnet-1"},
+		{Type: "String"},
+		{Type: "Number", Default: "ten"},
+	} {
+		assert.Error(t, ValidateParameters(cloudformation.Parameters{"P": param}, nil), to.CompactJSONStr(param))
+	}
+}
+
+func TestResolveConditions(t *testing.T) {
+	template, err := MockTemplate("../../examples/tests/allowed/conditions.yml")
+	assert.NoError(t, err)
+
+	// goformation's base64 encoded intrinsics
+	templateBody, err := json.Marshal(template)
+	assert.NoError(t, err)
+
+	values := StaticValues{ProjectName: "project", ConfigName: "development", Region: "us-east-1", AccountID: "000000000000"}
+
+	resolved, err := ResolveConditions(templateBody, values)
+	assert.NoError(t, err)
+
+	dev := &cloudformation.Template{}
+	assert.NoError(t, json.Unmarshal(resolved, dev))
+	assert.Nil(t, dev.Conditions)
+	assert.Nil(t, dev.Mappings)
+	assert.Nil(t, dev.Outputs)
+	assert.NotContains(t, dev.Resources, "prodHello")
+
+	hello := dev.Resources["hello"].(*serverless.Function)
+	assert.Equal(t, "hello-world", hello.Handler)
+	assert.Equal(t, "role_correct", hello.Role)
+	assert.Equal(t, "", hello.Description)
+
+	values.ConfigName = "production"
+	values.Parameters = map[string]string{"Stage": "prod"}
+
+	resolved, err = ResolveConditions(templateBody, values)
+	assert.NoError(t, err)
+
+	prod := &cloudformation.Template{}
+	assert.NoError(t, json.Unmarshal(resolved, prod))
+	assert.Contains(t, prod.Resources, "prodHello")
+	assert.Contains(t, prod.Outputs, "ProdHello")
+
+	hello = prod.Resources["hello"].(*serverless.Function)
+	assert.Equal(t, "hello-world-prod", hello.Handler)
+	assert.Equal(t, "role_incorrect", hello.Role)
+	assert.Equal(t, "production", hello.Description)
+}
+
+func TestResolveConditionsObjects(t *testing.T) {
+	resolved, err := ResolveConditions([]byte(`{
+		"Mappings": {"Sizes": {"us-east-1": {"Memory": 512}}},
+		"Conditions": {
+			"IsEast": {"Fn::Equals": [{"Ref": "AWS::Region"}, "us-east-1"]},
+			"IsProject": {"Fn::Equals": [{"Ref": "Fenrir::ProjectName"}, "project"]},
+			"Both": {"Fn::And": [{"Condition": "IsEast"}, {"Fn::Not": [{"Condition": "IsProject"}]}]}
+		},
+		"Resources": {
+			"fn": {"Type": "AWS::Serverless::Function", "Properties": {
+				"MemorySize": {"Fn::If": ["IsEast", {"Fn::FindInMap": ["Sizes", {"Ref": "AWS::Region"}, "Memory"]}, 128]},
+				"Layers": [{"Fn::If": ["Both", "layer", {"Ref": "AWS::NoValue"}]}],
+				"Tags": {"Project": {"Ref": "Fenrir::ProjectName"}}
+			}},
+			"both": {"Type": "AWS::SQS::Queue", "Condition": "Both"}
+		}
+	}`), StaticValues{ProjectName: "project", Region: "us-east-1"})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Resources": {"fn": {"Type": "AWS::Serverless::Function", "Properties": {
+		"MemorySize": 512,
+		"Layers": [],
+		"Tags": {"Project": "project"}
+	}}}}`, string(resolved))
+
+	for _, tmpl := range []string{
+		`{"Conditions": {"A": {"Condition": "B"}, "B": {"Condition": "A"}}}`,
+		`{"Conditions": {"A": {"Fn::Equals": [{"Ref": "queue"}, ""]}}}`,
+		`{"Conditions": {"A": {"Fn::Equals": [{"Fn::FindInMap": ["None", "a", "b"]}, ""]}}}`,
+		`{"Resources": {"q": {"Type": "AWS::SQS::Queue", "Condition": "Missing"}}}`,
+		`{"Resources": {"q": {"Type": "AWS::SQS::Queue", "Properties": {"QueueName": {"Fn::If": ["Missing", "a", "b"]}}}}}`,
+	} {
+		_, err := ResolveConditions([]byte(tmpl), StaticValues{})
+		assert.Error(t, err, tmpl)
+	}
+}
+
+func TestResolveConditionsParameters(t *testing.T) {
+	tmpl := []byte(`{
+		"Parameters": {
+			"Stage": {"Type": "String", "Default": "dev"},
+			"Memory": {"Type": "Number", "Default": 128},
+			"Domains": {"Type": "CommaDelimitedList"},
+			"Unset": {"Type": "String"}
+		},
+		"Resources": {
+			"fn": {"Type": "AWS::Serverless::Function", "Properties": {
+				"MemorySize": {"Ref": "Memory"},
+				"Description": {"Ref": "Stage"},
+				"Layers": {"Ref": "Domains"},
+				"Handler": {"Ref": "Unset"},
+				"Role": {"Fn::Sub": "${Stage}-role"}
+			}}
+		}
+	}`)
+
+	resolved, err := ResolveConditions(tmpl, StaticValues{Parameters: map[string]string{"Memory": "1024", "Domains": "a, b"}})
+	assert.NoError(t, err)
+
+	var document struct {
+		Resources map[string]struct {
+			Properties map[string]interface{}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(resolved, &document))
+
+	// Numbers are substituted as numbers, and Refs without a value or other intrinsics are kept
+	properties := document.Resources["fn"].Properties
+	assert.Equal(t, 1024.0, properties["MemorySize"])
+	assert.Equal(t, "dev", properties["Description"])
+	assert.Equal(t, []interface{}{"a", "b"}, properties["Layers"])
+	assert.Equal(t, map[string]interface{}{"Ref": "Unset"}, properties["Handler"])
+	assert.Equal(t, map[string]interface{}{"Fn::Sub": "${Stage}-role"}, properties["Role"])
+
+	_, err = ResolveConditions(tmpl, StaticValues{Parameters: map[string]string{"Memory": "large"}})
+	assert.Regexp(t, `Parameter Memory value "large" is not a Number`, err)
+}
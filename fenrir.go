@@ -12,21 +12,34 @@ import (
 )
 
 func main() {
-	var arg, command string
-	switch len(os.Args) {
-	case 1:
+	if len(os.Args) == 1 {
 		fmt.Println("Starting Lambda")
 		run.LambdaTasks(deployer.TaskHandlers())
-	case 2:
-		command = os.Args[1]
-		arg = ""
-	case 3:
-		command = os.Args[1]
-		arg = os.Args[2]
-	default:
+	}
+
+	command := os.Args[1]
+	args := os.Args[2:]
+
+	if len(args) > 3 {
 		printUsage() // Print how to use and exit
 	}
 
+	// releaseFile is the argument at index i defaulting to ./template.yml
+	releaseFile := func(i int) *string {
+		if len(args) <= i || is.EmptyStr(&args[i]) {
+			return to.Strp("./template.yml")
+		}
+		return &args[i]
+	}
+
+	// arg is the argument at index i defaulting to empty string
+	arg := func(i int) *string {
+		if len(args) <= i {
+			return to.Strp("")
+		}
+		return &args[i]
+	}
+
 	step_fn := to.Strp("coinbase-fenrir")
 
 	switch command {
@@ -34,23 +47,66 @@ func main() {
 		// This is required to use the step to deploy
 		run.JSON(deployer.StateMachine())
 	case "deploy":
-		releaseFile := &arg
-		if is.EmptyStr(releaseFile) {
-			releaseFile = to.Strp("./template.yml")
+		err := client.Deploy(step_fn, releaseFile(0))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "plan":
+		err := client.Plan(step_fn, releaseFile(0))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "validate":
+		err := client.Validate(releaseFile(0))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "approve", "reject":
+		var err error
+		if command == "approve" {
+			err = client.Approve(arg(0), releaseFile(1))
+		} else {
+			err = client.Reject(arg(0), releaseFile(1))
 		}
 
-		err := client.Deploy(step_fn, releaseFile)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "rollback":
+		err := client.Rollback(step_fn, arg(0), arg(1), arg(2))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "destroy":
+		err := client.Destroy(step_fn, arg(0), arg(1))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "history":
+		err := client.History(arg(0), arg(1))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	case "status":
+		err := client.Status(arg(0), arg(1))
 		if err != nil {
 			fmt.Println(err.Error())
 			os.Exit(1)
 		}
 	case "package":
-		releaseFile := &arg
-		if is.EmptyStr(releaseFile) {
-			releaseFile = to.Strp("./template.yml")
+		docker := len(args) > 0 && args[0] == "--docker"
+		if docker {
+			args = args[1:]
 		}
 
-		err := client.Package(releaseFile)
+		err := client.Package(releaseFile(0), docker)
 		if err != nil {
 			fmt.Println(err.Error())
 			os.Exit(1)
@@ -61,6 +117,10 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Println("Usage: fenrir json|deploy|package <release_file> (No args starts Lambda)")
+	fmt.Println("Usage: fenrir json|deploy|plan|validate|package <release_file> (No args starts Lambda)")
+	fmt.Println("       fenrir package --docker <release_file> (builds the Dockerfile instead of go build)")
+	fmt.Println("       fenrir approve|reject <release_id> <release_file>")
+	fmt.Println("       fenrir history|status|destroy <project_name> <config_name>")
+	fmt.Println("       fenrir rollback <project_name> <config_name> <release_id> (No release_id rolls back to the previous deployed release)")
 	os.Exit(0)
 }